@@ -0,0 +1,47 @@
+package smshandler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Size limits for a binary message sent with SendBinary
+const (
+	// MaxBinaryLength is the payload size of a binary message without a port
+	MaxBinaryLength = maxUDOctets
+	// MaxBinaryPortLength is the payload size when a port is given, which
+	// costs 7 octets of user data header
+	MaxBinaryPortLength = maxUDOctets - 7
+)
+
+// SendBinary sends data as a single 8-bit (binary) SMS, as used for WAP
+// push and device configuration. A non-zero port adds an application port
+// header addressing that 16-bit port on the recipient. data may hold at most
+// MaxBinaryLength bytes, or MaxBinaryPortLength with a port. Binary messages
+// need PDU mode (WithMode(ModePDU)); in text mode ErrNotSupported is
+// returned.
+func (s *SMSHandler) SendBinary(number string, data []byte, port uint16) error {
+	if s.cfg.mode != ModePDU {
+		return fmt.Errorf("binary messages require PDU mode: %w", ErrNotSupported)
+	}
+
+	if err := s.beginSend(); err != nil {
+		return err
+	}
+	defer s.endSend()
+
+	pdu, length, err := encodeBinaryPDU(number, data, port, AddressTypeAuto)
+	if err != nil {
+		return fmt.Errorf("failed to encode PDU: %v", err)
+	}
+
+	s.waitForSendSlot()
+
+	if _, err := s.composeMessage(context.Background(), fmt.Sprintf("AT+CMGS=%d", length), pdu, "+CMGS:", 30*time.Second, nil); err != nil {
+		s.metricsRecorder().IncSendError(errorClass(err))
+		return err
+	}
+	s.metricsRecorder().IncSent()
+	return nil
+}
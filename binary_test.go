@@ -0,0 +1,61 @@
+package smshandler
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncodeBinaryPDURoundTrip(t *testing.T) {
+	payload := []byte{0x00, 0x01, 0xFF, 0x1A, 0x7F}
+
+	pdu, length, err := encodeBinaryPDU("+46708251358", payload, 2948, AddressTypeAuto)
+	if err != nil {
+		t.Fatalf("encodeBinaryPDU failed: %v", err)
+	}
+	if length != len(pdu)/2-1 {
+		t.Errorf("length: got %d for a %d-octet PDU", length, len(pdu)/2)
+	}
+
+	sms, _, err := decodePDU(pdu)
+	if err != nil {
+		t.Fatalf("decodePDU failed: %v", err)
+	}
+	if sms.Encoding != Encoding8Bit {
+		t.Errorf("Encoding: got %q", sms.Encoding)
+	}
+	if !bytes.Equal(sms.Data, payload) {
+		t.Errorf("Data: got % X, want % X", sms.Data, payload)
+	}
+	if sms.Port != 2948 {
+		t.Errorf("Port: got %d, want 2948", sms.Port)
+	}
+}
+
+func TestEncodeBinaryPDULimits(t *testing.T) {
+	if _, _, err := encodeBinaryPDU("+46708251358", make([]byte, MaxBinaryLength), 0, AddressTypeAuto); err != nil {
+		t.Errorf("full payload without a port rejected: %v", err)
+	}
+	if _, _, err := encodeBinaryPDU("+46708251358", make([]byte, MaxBinaryPortLength+1), 80, AddressTypeAuto); err == nil {
+		t.Error("expected an error for a payload too long to fit beside the port header")
+	}
+}
+
+func TestSendBinaryRequiresPDUMode(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	if err := handler.SendBinary("+1234567890", []byte{0x01}, 0); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported in text mode, got %v", err)
+	}
+}
+
+func TestSendBinary(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.mode = ModePDU
+	mockPort.AddResponse("AT+CMGS=23", "\r\n> ")
+	mockPort.AddResponse("0051000B916407281553F80004A7090605040B840B84CAFE\x1A", "\r\n+CMGS: 5\r\nOK\r\n")
+
+	if err := handler.SendBinary("+46708251358", []byte{0xCA, 0xFE}, 2948); err != nil {
+		t.Fatalf("SendBinary failed: %v", err)
+	}
+}
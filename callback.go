@@ -0,0 +1,27 @@
+package smshandler
+
+import "runtime/debug"
+
+// invokeCallback runs the application's SMS callback, recovering a panic so
+// one bad message or callback bug does not stop the listener. The panic is
+// logged with the message details and passed to the handler from
+// WithCallbackPanicHandler; with WithListenerPanics it is re-raised instead.
+func (s *SMSHandler) invokeCallback(callback func(SMS), sms SMS) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		s.logger().Printf("SMS callback panicked on message %d from %s (%q): %v\n%s",
+			sms.Index, sms.Sender, sms.Date, r, debug.Stack())
+		if s.cfg.listenerPanics {
+			panic(r)
+		}
+		if s.cfg.callbackPanicHandler != nil {
+			s.cfg.callbackPanicHandler(sms, r)
+		}
+	}()
+
+	callback(sms)
+}
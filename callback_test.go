@@ -0,0 +1,41 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCallbackPanicIsolated(t *testing.T) {
+	logger := &recordingLogger{}
+	handler := newMockHandler(NewMockSerialPort())
+	handler.cfg.logger = logger
+
+	var panicked []SMS
+	handler.cfg.callbackPanicHandler = func(sms SMS, recovered interface{}) {
+		if recovered != "bad message" {
+			t.Errorf("recovered value: got %v", recovered)
+		}
+		panicked = append(panicked, sms)
+	}
+
+	var delivered []string
+	callback := func(sms SMS) {
+		if sms.Message == "boom" {
+			panic("bad message")
+		}
+		delivered = append(delivered, sms.Message)
+	}
+
+	handler.deliver(SMS{Index: 1, Sender: "+1234567890", Message: "boom"}, callback)
+	handler.deliver(SMS{Index: 2, Sender: "+1234567890", Message: "fine"}, callback)
+
+	if len(panicked) != 1 || panicked[0].Index != 1 {
+		t.Errorf("panic handler got %+v", panicked)
+	}
+	if len(delivered) != 1 || delivered[0] != "fine" {
+		t.Errorf("later message not delivered: %v", delivered)
+	}
+	if len(logger.warns) == 0 || !strings.Contains(logger.warns[0], "+1234567890") {
+		t.Errorf("panic not logged with the message details: %v", logger.warns)
+	}
+}
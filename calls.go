@@ -0,0 +1,91 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrModemBusy is returned, wrapped, when a send fails because the modem
+// is busy with a voice call (it answered BUSY or NO CARRIER). Retry once
+// the call has ended; CallState shows whether one is in progress.
+var ErrModemBusy = errors.New("modem busy with a voice call")
+
+// hasBusyResult reports whether a send response contains a line saying
+// the modem is tied up by a call
+func hasBusyResult(response string) bool {
+	for _, line := range strings.Split(response, "\n") {
+		switch strings.TrimSpace(line) {
+		case "BUSY", "NO CARRIER":
+			return true
+		}
+	}
+	return false
+}
+
+// CallStatus is the state of a call as reported by AT+CLCC
+type CallStatus int
+
+const (
+	// CallActive is a call in progress
+	CallActive CallStatus = iota
+	// CallHeld is a call on hold
+	CallHeld
+	// CallDialing is an outgoing call being set up
+	CallDialing
+	// CallAlerting is an outgoing call ringing at the other end
+	CallAlerting
+	// CallIncoming is an incoming call ringing
+	CallIncoming
+	// CallWaiting is an incoming call waiting behind another call
+	CallWaiting
+)
+
+// Call is one entry of the modem's current call list
+type Call struct {
+	Index int
+	// Incoming is set for mobile-terminated calls
+	Incoming bool
+	Status   CallStatus
+	// Number is the other party's number, empty if withheld
+	Number string
+}
+
+// CallState lists the modem's current calls (AT+CLCC); an empty list means
+// no call is in progress, so sends should not fail with ErrModemBusy.
+func (s *SMSHandler) CallState() ([]Call, error) {
+	response, err := s.queryDevice("AT+CLCC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calls: %w", err)
+	}
+
+	var calls []Call
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "+CLCC:") {
+			continue
+		}
+
+		// +CLCC: <id>,<dir>,<stat>,<mode>,<mpty>[,<number>,<type>]
+		fields, _ := resultFields(line, "+CLCC:")
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("failed to list calls: invalid line %q", line)
+		}
+		var call Call
+		var stat int
+		if call.Index, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, fmt.Errorf("failed to list calls: invalid line %q", line)
+		}
+		if stat, err = strconv.Atoi(fields[2]); err != nil {
+			return nil, fmt.Errorf("failed to list calls: invalid line %q", line)
+		}
+		call.Incoming = fields[1] == "1"
+		call.Status = CallStatus(stat)
+		if len(fields) > 5 {
+			call.Number = fields[5]
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
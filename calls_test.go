@@ -0,0 +1,47 @@
+package smshandler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSendSMSModemBusy(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\nBUSY\r\n")
+
+	err := handler.SendSMS("+1234567890", "During a call")
+	if !errors.Is(err, ErrModemBusy) {
+		t.Fatalf("expected ErrModemBusy, got %v", err)
+	}
+	if errorClass(err) != ErrorClassBusy {
+		t.Errorf("error class: got %q", errorClass(err))
+	}
+}
+
+func TestCallState(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CLCC", "+CLCC: 1,1,0,0,0,\"+1234567890\",145\r\n+CLCC: 2,1,5,0,0,\"\",128\r\nOK\r\n")
+
+	calls, err := handler.CallState()
+	if err != nil {
+		t.Fatalf("CallState failed: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	if want := (Call{Index: 1, Incoming: true, Status: CallActive, Number: "+1234567890"}); calls[0] != want {
+		t.Errorf("first call: got %+v, want %+v", calls[0], want)
+	}
+	if calls[1].Status != CallWaiting || calls[1].Number != "" {
+		t.Errorf("second call: got %+v", calls[1])
+	}
+
+	mockPort = NewMockSerialPort()
+	handler = newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CLCC", "OK\r\n")
+	if calls, err := handler.CallState(); err != nil || len(calls) != 0 {
+		t.Errorf("idle modem: got %v, %v", calls, err)
+	}
+}
@@ -0,0 +1,123 @@
+package smshandler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SupportedCommands lists the AT commands the modem implements, as reported
+// by AT+CLAC (for example "AT+CMGF"). Modems without AT+CLAC return an
+// error wrapping ErrNotSupported.
+func (s *SMSHandler) SupportedCommands() ([]string, error) {
+	response, err := s.queryDevice("AT+CLAC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list supported commands: %w", err)
+	}
+
+	var commands []string
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "OK" {
+			continue
+		}
+		commands = append(commands, line)
+	}
+	return commands, nil
+}
+
+// SupportsPDUMode reports whether the modem accepts PDU mode, by checking
+// that AT+CMGF=? lists format 0. Modems that cannot answer the query
+// return an error wrapping ErrNotSupported.
+func (s *SMSHandler) SupportsPDUMode() (bool, error) {
+	response, err := s.queryDevice("AT+CMGF=?")
+	if err != nil {
+		return false, fmt.Errorf("failed to query message formats: %w", err)
+	}
+
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "+CMGF:") {
+			continue
+		}
+		return rangeListContains(strings.TrimPrefix(line, "+CMGF:"), 0), nil
+	}
+	return false, fmt.Errorf("failed to query message formats: unexpected response %q", response)
+}
+
+// rangeListContains reports whether a test-command value list such as
+// "(0,1)" or "(0-1)" includes v
+func rangeListContains(list string, v int) bool {
+	list = strings.Trim(strings.TrimSpace(list), "()")
+	for _, item := range strings.Split(list, ",") {
+		lo, hi := item, item
+		if i := strings.Index(item, "-"); i > 0 {
+			lo, hi = item[:i], item[i+1:]
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		to, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 == nil && err2 == nil && from <= v && v <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// StorageSupport lists the message storages ("SM", "ME", "MT", ...) the
+// modem accepts for each of the three AT+CPMS memories
+type StorageSupport struct {
+	Read    []string // mem1, where messages are read and deleted
+	Write   []string // mem2, where WriteSMS stores and SendStoredSMS sends from
+	Receive []string // mem3, where incoming messages are stored
+}
+
+// AvailableStorage reports the storages the modem supports for reading,
+// writing and receiving, from AT+CPMS=?. Modems that list fewer than three
+// memories repeat the last list for the rest.
+func (s *SMSHandler) AvailableStorage() (StorageSupport, error) {
+	response, err := s.queryDevice("AT+CPMS=?")
+	if err != nil {
+		return StorageSupport{}, fmt.Errorf("failed to query supported storage: %w", err)
+	}
+
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "+CPMS:") {
+			continue
+		}
+		groups := parseValueGroups(strings.TrimPrefix(line, "+CPMS:"))
+		if len(groups) == 0 {
+			break
+		}
+		for len(groups) < 3 {
+			groups = append(groups, groups[len(groups)-1])
+		}
+		return StorageSupport{Read: groups[0], Write: groups[1], Receive: groups[2]}, nil
+	}
+	return StorageSupport{}, fmt.Errorf("failed to query supported storage: unexpected response %q", response)
+}
+
+// parseValueGroups splits a test-command response such as
+// ("SM","ME"),("SM") into its parenthesized lists of unquoted values
+func parseValueGroups(list string) [][]string {
+	var groups [][]string
+	for {
+		open := strings.Index(list, "(")
+		if open < 0 {
+			return groups
+		}
+		end := strings.Index(list[open:], ")")
+		if end < 0 {
+			return groups
+		}
+
+		var values []string
+		for _, v := range splitRespectingQuotes(list[open+1:open+end], ',') {
+			if v = unquote(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		groups = append(groups, values)
+		list = list[open+end+1:]
+	}
+}
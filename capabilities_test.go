@@ -0,0 +1,107 @@
+package smshandler
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSupportedCommands(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CLAC", "AT+CMGF\r\nAT+CMGS\r\nAT+CSQ\r\n\r\nOK\r\n")
+
+	commands, err := handler.SupportedCommands()
+	if err != nil {
+		t.Fatalf("SupportedCommands failed: %v", err)
+	}
+	if want := []string{"AT+CMGF", "AT+CMGS", "AT+CSQ"}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("got %v, want %v", commands, want)
+	}
+
+	mockPort = NewMockSerialPort()
+	handler = newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CLAC", "ERROR\r\n")
+	if _, err := handler.SupportedCommands(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestSupportsPDUMode(t *testing.T) {
+	tests := []struct {
+		response string
+		want     bool
+	}{
+		{"+CMGF: (0,1)\r\nOK\r\n", true},
+		{"+CMGF: (0-1)\r\nOK\r\n", true},
+		{"+CMGF: (1)\r\nOK\r\n", false},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		mockPort.AddResponse("AT+CMGF=?", tt.response)
+
+		got, err := handler.SupportsPDUMode()
+		if err != nil {
+			t.Errorf("%q: %v", tt.response, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.response, got, tt.want)
+		}
+	}
+
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CMGF=?", "+CME ERROR: 4\r\n")
+	if _, err := handler.SupportsPDUMode(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestAvailableStorage(t *testing.T) {
+	tests := []struct {
+		response string
+		want     StorageSupport
+	}{
+		{
+			"+CPMS: (\"SM\",\"ME\",\"MT\"),(\"SM\",\"ME\"),(\"SM\",\"MT\")\r\nOK\r\n",
+			StorageSupport{
+				Read:    []string{"SM", "ME", "MT"},
+				Write:   []string{"SM", "ME"},
+				Receive: []string{"SM", "MT"},
+			},
+		},
+		{
+			"+CPMS: (\"SM\",\"ME\")\r\nOK\r\n",
+			StorageSupport{
+				Read:    []string{"SM", "ME"},
+				Write:   []string{"SM", "ME"},
+				Receive: []string{"SM", "ME"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		mockPort.AddResponse("AT+CPMS=?", tt.response)
+
+		got, err := handler.AvailableStorage()
+		if err != nil {
+			t.Errorf("%q: AvailableStorage failed: %v", tt.response, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.response, got, tt.want)
+		}
+	}
+
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CPMS=?", "OK\r\n")
+	if _, err := handler.AvailableStorage(); err == nil {
+		t.Error("expected an error without a +CPMS line")
+	}
+}
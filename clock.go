@@ -0,0 +1,32 @@
+package smshandler
+
+import "time"
+
+// clock is the time source behind the handler's timeouts and delays, so
+// tests can substitute a fake and advance time deterministically
+type clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock returns the handler's time source, the wall clock unless a test
+// installed another
+func (s *SMSHandler) clock() clock {
+	if s.clk == nil {
+		return realClock{}
+	}
+	return s.clk
+}
+
+// since is time.Since on the handler's clock
+func (s *SMSHandler) since(t time.Time) time.Duration {
+	return s.clock().Now().Sub(t)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -9,21 +10,18 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/brooksmcmillin/sms-handler"
 )
 
 type ChatUI struct {
-	mu          sync.Mutex
-	phoneNumber string
-	smsHandler  *smshandler.SMSHandler
+	mu   sync.Mutex
+	conv *smshandler.Conversation
 }
 
-func NewChatUI(phoneNumber string, smsHandler *smshandler.SMSHandler) *ChatUI {
-	return &ChatUI{
-		phoneNumber: phoneNumber,
-		smsHandler:  smsHandler,
-	}
+func NewChatUI(conv *smshandler.Conversation) *ChatUI {
+	return &ChatUI{conv: conv}
 }
 
 func (c *ChatUI) displayMessage(sender, message, timestamp string) {
@@ -40,9 +38,9 @@ func (c *ChatUI) displayMessage(sender, message, timestamp string) {
 	fmt.Print("> ")
 }
 
-func (c *ChatUI) handleIncomingMessage(sms smshandler.SMS) {
-	// Only show messages from our target phone number
-	if sms.Sender == c.phoneNumber {
+// receiveMessages shows incoming messages until the conversation is closed
+func (c *ChatUI) receiveMessages() {
+	for sms := range c.conv.Incoming() {
 		c.displayMessage(sms.Sender, sms.Message, sms.Date)
 	}
 }
@@ -52,7 +50,7 @@ func (c *ChatUI) sendMessage(message string) error {
 		return nil
 	}
 
-	err := c.smsHandler.SendSMS(c.phoneNumber, message)
+	err := c.conv.Send(message, smshandler.WithProgress(c.showProgress))
 	if err != nil {
 		return fmt.Errorf("failed to send message: %v", err)
 	}
@@ -60,15 +58,53 @@ func (c *ChatUI) sendMessage(message string) error {
 	return nil
 }
 
+// showProgress reports each part of a long message as it is sent
+func (c *ChatUI) showProgress(p smshandler.SendProgress) {
+	if p.Total > 1 && p.Stage == smshandler.StagePartSent {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		fmt.Printf("\r\033[K(part %d of %d sent)\n", p.Part, p.Total)
+	}
+}
+
+// listPorts prints the serial ports that could host the modem
+func listPorts() {
+	ports, err := smshandler.ListPortsDetailed()
+	if err != nil {
+		log.Fatalf("Failed to list serial ports: %v", err)
+	}
+	if len(ports) == 0 {
+		fmt.Println("No serial ports found")
+		return
+	}
+
+	for _, p := range ports {
+		if p.IsUSB {
+			fmt.Printf("%s\tUSB %s:%s %s\n", p.Name, p.VID, p.PID, p.Product)
+		} else {
+			fmt.Println(p.Name)
+		}
+	}
+}
+
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run main.go sms.go <phone_number>")
-		fmt.Println("Example: go run main.go sms.go +1234567890")
+		fmt.Println("Usage: go run main.go sms.go <phone_number> [serial_port]")
+		fmt.Println("       go run main.go sms.go --list-ports")
+		fmt.Println("Example: go run main.go sms.go +1234567890 /dev/ttyUSB2")
 		os.Exit(1)
 	}
 
+	if os.Args[1] == "--list-ports" {
+		listPorts()
+		return
+	}
+
 	phoneNumber := os.Args[1]
 	portName := "/dev/ttyUSB2"
+	if len(os.Args) > 2 {
+		portName = os.Args[2]
+	}
 	baudRate := 115200
 
 	// Initialize SMS handler
@@ -87,11 +123,10 @@ func main() {
 	fmt.Printf("Type your messages and press Enter to send. Ctrl+C to exit.\n")
 	fmt.Println(strings.Repeat("-", 50))
 
-	// Create chat UI
-	chat := NewChatUI(phoneNumber, smsHandler)
-
-	// Start listening for incoming SMS messages
-	smsHandler.ListenForIncomingSMS(chat.handleIncomingMessage)
+	// Create chat UI; the conversation starts the listener and only
+	// receives messages from our target phone number
+	chat := NewChatUI(smsHandler.ForNumber(phoneNumber))
+	go chat.receiveMessages()
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -100,9 +135,11 @@ func main() {
 	go func() {
 		<-sigChan
 		fmt.Println("\nShutting down...")
-		if err := smsHandler.Close(); err != nil {
-			log.Printf("Error closing SMS handler: %v", err)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := smsHandler.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down SMS handler: %v", err)
 		}
+		cancel()
 		os.Exit(0)
 	}()
 
@@ -0,0 +1,140 @@
+package smshandler
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// concatTimeout is how long the listener holds the parts of an incomplete
+// concatenated message before delivering what arrived as separate messages
+const concatTimeout = 10 * time.Minute
+
+// concatKey identifies the parts of one concatenated message
+type concatKey struct {
+	sender string
+	ref    int
+	total  int
+}
+
+// partialMessage collects the parts received so far, indexed by sequence
+type partialMessage struct {
+	parts    []*SMS
+	received int
+	started  time.Time
+}
+
+// reassembler joins the parts of concatenated messages
+type reassembler struct {
+	pending map[concatKey]*partialMessage
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{pending: make(map[concatKey]*partialMessage)}
+}
+
+// add stores one part and returns the joined message once every part has
+// arrived. A repeated part replaces the earlier copy.
+func (r *reassembler) add(sms SMS, info concatInfo, now time.Time) (SMS, bool) {
+	key := concatKey{sender: sms.Sender, ref: info.ref, total: info.total}
+	p, ok := r.pending[key]
+	if !ok {
+		p = &partialMessage{parts: make([]*SMS, info.total), started: now}
+		r.pending[key] = p
+	}
+
+	if p.parts[info.seq-1] == nil {
+		p.received++
+	}
+	part := sms
+	p.parts[info.seq-1] = &part
+
+	if p.received < info.total {
+		return SMS{}, false
+	}
+	delete(r.pending, key)
+	return joinParts(p.parts), true
+}
+
+// expire removes groups started before now-maxAge and returns their parts
+// as individual messages so nothing that arrived is lost
+func (r *reassembler) expire(now time.Time, maxAge time.Duration) []SMS {
+	var out []SMS
+	for key, p := range r.pending {
+		if now.Sub(p.started) < maxAge {
+			continue
+		}
+		out = append(out, p.receivedParts()...)
+		delete(r.pending, key)
+	}
+	sortByIndex(out)
+	return out
+}
+
+// flush removes every pending group and returns its parts individually
+func (r *reassembler) flush() []SMS {
+	var out []SMS
+	for key, p := range r.pending {
+		out = append(out, p.receivedParts()...)
+		delete(r.pending, key)
+	}
+	sortByIndex(out)
+	return out
+}
+
+func (p *partialMessage) receivedParts() []SMS {
+	var out []SMS
+	for _, part := range p.parts {
+		if part != nil {
+			out = append(out, *part)
+		}
+	}
+	return out
+}
+
+// joinParts combines the parts of a complete message in sequence order. The
+// result carries the first part's index and header fields and lists every
+// part's storage index in PartIndexes.
+func joinParts(parts []*SMS) SMS {
+	joined := *parts[0]
+	var body strings.Builder
+	var data []byte
+	for _, part := range parts {
+		body.WriteString(part.Message)
+		data = append(data, part.Data...)
+		joined.PartIndexes = append(joined.PartIndexes, part.Index)
+	}
+	joined.Message = body.String()
+	joined.Data = data
+	return joined
+}
+
+func sortByIndex(messages []SMS) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Index < messages[j].Index
+	})
+}
+
+// assemble passes a received part through the handler's reassembler and
+// delivers the joined message once complete. Parts of groups that never
+// completed within concatTimeout are delivered on their own.
+func (s *SMSHandler) assemble(sms SMS, info concatInfo, callback func(SMS)) {
+	if !info.valid() {
+		s.deliver(sms, callback)
+		return
+	}
+
+	if s.concat == nil {
+		s.concat = newReassembler()
+	}
+
+	now := s.clock().Now()
+	for _, stale := range s.concat.expire(now, concatTimeout) {
+		s.logger().Printf("Delivering part of incomplete concatenated message from %s", stale.Sender)
+		s.deliver(stale, callback)
+	}
+
+	if joined, ok := s.concat.add(sms, info, now); ok {
+		s.deliver(joined, callback)
+	}
+}
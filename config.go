@@ -0,0 +1,95 @@
+package smshandler
+
+import "time"
+
+// HandlerConfig is a snapshot of a handler's effective settings, for
+// logging at startup or showing on a status page. Defaults are filled in
+// for options that were not given.
+type HandlerConfig struct {
+	PortName string
+	BaudRate int
+	Mode     Mode
+
+	PollInterval    time.Duration
+	CommandTimeout  time.Duration
+	ReceiveTimeout  time.Duration
+	MinSendInterval time.Duration
+	// DedupWindow is the WithDeduplication window, 0 when disabled
+	DedupWindow     time.Duration
+	StartupWait     time.Duration
+	ReadyAttempts   int
+	ReadyDelay      time.Duration
+	ResetWait       time.Duration
+	StatusRefresh   time.Duration
+	CommandDelay    time.Duration
+	PromptDelay     time.Duration
+	WriteChunkSize  int
+	WriteChunkDelay time.Duration
+	MaxSegments     int
+	ErrorReporting  ErrorReporting
+
+	SkipInit             bool
+	DiscardFlashMessages bool
+	ShowTextHeaders      bool
+	OverwriteWhenFull    bool
+	// AutoReconnect is the WithAutoReconnect policy, nil when not set
+	AutoReconnect *ReconnectPolicy
+
+	// Charset, Storage and CNMI are the character set, message storage and
+	// AT+CNMI arguments the init sequence set on the modem. They are empty
+	// when init was skipped or did not get that far.
+	Charset string
+	Storage string
+	CNMI    string
+	// ActiveStorage is the read, write and receive storage the modem
+	// reported after the last storage change, nil if it was never confirmed
+	ActiveStorage []string
+	// ModemModel is the model reported during init and Quirks the profile
+	// matched to it; both are zero when quirk detection did not run or
+	// the model is unknown
+	ModemModel string
+	Quirks     QuirkProfile
+}
+
+// Config returns the handler's effective settings. The result is a copy;
+// changing it does not affect the handler.
+func (s *SMSHandler) Config() HandlerConfig {
+	c := HandlerConfig{
+		PortName:             s.portName,
+		BaudRate:             s.baudRate,
+		Mode:                 s.cfg.mode,
+		PollInterval:         s.pollInterval(),
+		CommandTimeout:       s.commandTimeout(),
+		ReceiveTimeout:       s.receiveTimeout(),
+		MinSendInterval:      s.cfg.minSendInterval,
+		StartupWait:          s.cfg.startupWait,
+		ReadyAttempts:        s.readyAttempts(),
+		ReadyDelay:           s.readyDelay(),
+		ResetWait:            s.resetWait(),
+		StatusRefresh:        s.cfg.statusRefresh,
+		CommandDelay:         s.cfg.commandDelay,
+		PromptDelay:          s.cfg.promptDelay,
+		WriteChunkSize:       s.cfg.writeChunkSize,
+		WriteChunkDelay:      s.cfg.writeChunkDelay,
+		MaxSegments:          s.maxSegments(),
+		ErrorReporting:       s.errorReporting(),
+		SkipInit:             s.cfg.skipInit,
+		DiscardFlashMessages: s.cfg.discardFlash,
+		ShowTextHeaders:      s.cfg.showTextHeaders,
+		OverwriteWhenFull:    s.cfg.overwriteWhenFull,
+		Charset:              s.charset,
+		Storage:              s.storage,
+		CNMI:                 s.cnmi,
+		ActiveStorage:        append([]string(nil), s.activeStorage...),
+		ModemModel:           s.ModemModel(),
+		Quirks:               s.modemQuirks(),
+	}
+	if s.cfg.dedupEnabled {
+		c.DedupWindow = s.cfg.dedupWindow
+	}
+	if s.cfg.reconnect != nil {
+		policy := *s.cfg.reconnect
+		c.AutoReconnect = &policy
+	}
+	return c
+}
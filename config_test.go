@@ -0,0 +1,67 @@
+package smshandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	RegisterQuirkProfile("TestModem-C1", QuirkProfile{CNMI: "2,2,0,0,0", Storage: "ME"})
+	defer func() {
+		quirkMu.Lock()
+		delete(quirkProfiles, "TESTMODEM-C1")
+		quirkMu.Unlock()
+	}()
+
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	for _, opt := range []Option{
+		WithPollInterval(250 * time.Millisecond),
+		WithDeduplication(time.Minute),
+		WithAutoReconnect(ReconnectPolicy{MaxAttempts: 3}),
+	} {
+		opt(&handler.cfg)
+	}
+	mockPort.AddResponse("AT+CGMM", "TESTMODEM-C1\r\nOK\r\n")
+	mockPort.AddResponse("AT+CNMI=2,2,0,0,0", "OK\r\n")
+
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+
+	c := handler.Config()
+	if c.PollInterval != 250*time.Millisecond || c.DedupWindow != time.Minute {
+		t.Errorf("options not reported: %+v", c)
+	}
+	if c.ReceiveTimeout != DefaultReceiveTimeout || c.MaxSegments != DefaultMaxSegments {
+		t.Errorf("defaults not reported: %+v", c)
+	}
+	if c.Charset != "GSM" || c.Storage != "ME" || c.CNMI != "2,2,0,0,0" {
+		t.Errorf("init settings: got charset %q, storage %q, CNMI %q", c.Charset, c.Storage, c.CNMI)
+	}
+	if c.ModemModel != "TESTMODEM-C1" || c.Quirks.Storage != "ME" {
+		t.Errorf("model: got %q with quirks %+v", c.ModemModel, c.Quirks)
+	}
+	if c.AutoReconnect == nil || c.AutoReconnect.MaxAttempts != 3 {
+		t.Fatalf("reconnect policy: got %+v", c.AutoReconnect)
+	}
+
+	// The snapshot is a copy
+	c.AutoReconnect.MaxAttempts = 10
+	if handler.cfg.reconnect.MaxAttempts != 3 {
+		t.Error("changing the snapshot changed the handler")
+	}
+}
+
+func TestConfigGenericCNMI(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CGMM", "SOMETHING-ELSE\r\nOK\r\n")
+
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+	if c := handler.Config(); c.CNMI != "1,2,0,1,0" || c.Storage != "SM" {
+		t.Errorf("got CNMI %q, storage %q", c.CNMI, c.Storage)
+	}
+}
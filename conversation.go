@@ -0,0 +1,45 @@
+package smshandler
+
+// Conversation is a handler bound to one phone number, for chat-style use:
+// it sends to that number and receives only the messages from it. Any
+// number of conversations, along with Subscribe channels and the
+// ListenForIncomingSMS callback, share the handler's modem and listener.
+type Conversation struct {
+	handler  *SMSHandler
+	number   string
+	id       int
+	incoming <-chan SMS
+}
+
+// ForNumber returns a Conversation with number. Incoming messages are
+// matched to it as in SendSMSAndWaitForReply. Like Subscribe it starts the
+// listener if it is not already running, and its channel buffers
+// SubscriberBuffer messages. Close it when done; closing the handler also
+// closes its channel.
+func (s *SMSHandler) ForNumber(number string) *Conversation {
+	id, incoming := s.subscribe(func(sms SMS) bool {
+		return s.isFrom(sms, number)
+	})
+	return &Conversation{handler: s, number: number, id: id, incoming: incoming}
+}
+
+// Number returns the phone number the conversation is bound to
+func (c *Conversation) Number() string {
+	return c.number
+}
+
+// Send sends message to the conversation's number, like SendSMS
+func (c *Conversation) Send(message string, opts ...SendOption) error {
+	return c.handler.SendSMS(c.number, message, opts...)
+}
+
+// Incoming returns the channel of messages received from the number
+func (c *Conversation) Incoming() <-chan SMS {
+	return c.incoming
+}
+
+// Close stops delivery to the conversation and closes its Incoming
+// channel. The handler and its listener keep running.
+func (c *Conversation) Close() {
+	c.handler.Unsubscribe(c.id)
+}
@@ -0,0 +1,54 @@
+package smshandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForNumber(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	alice := handler.ForNumber("+1234567890")
+	bob := handler.ForNumber("+1987654321")
+	defer handler.Close()
+	if !handler.isListening() {
+		t.Fatal("ForNumber did not start the listener")
+	}
+
+	mockPort.SimulateIncoming("+CMT: \"+1987654321\",\"\",\"24/01/15,10:30:40+00\"\r\nFrom Bob\r\n\r\n" +
+		"+CMT: \"+1234567890\",\"\",\"24/01/15,10:30:45+00\"\r\nFrom Alice\r\n\r\n")
+
+	for name, tt := range map[string]struct {
+		conv *Conversation
+		want string
+	}{"alice": {alice, "From Alice"}, "bob": {bob, "From Bob"}} {
+		select {
+		case sms := <-tt.conv.Incoming():
+			if sms.Message != tt.want {
+				t.Errorf("%s got %q, want %q", name, sms.Message, tt.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out", name)
+		}
+	}
+
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Hi Alice\x1A", "\r\n+CMGS: 4\r\nOK\r\n")
+	if err := alice.Send("Hi Alice"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	alice.Close()
+	if _, ok := <-alice.Incoming(); ok {
+		t.Error("channel still open after Close")
+	}
+	select {
+	case sms := <-bob.Incoming():
+		t.Errorf("bob got another message %+v", sms)
+	default:
+	}
+	if handler.isClosed() {
+		t.Error("closing a conversation closed the handler")
+	}
+}
@@ -0,0 +1,167 @@
+package smshandler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// First-octet flags for SMS-SUBMIT as used by AT+CSMP
+const (
+	// FirstOctetSubmit marks the message as an SMS-SUBMIT
+	FirstOctetSubmit = 0x01
+	// FirstOctetRelativeVP indicates the validity period is in relative format
+	FirstOctetRelativeVP = 0x10
+	// FirstOctetStatusReport requests a delivery report from the SMSC
+	FirstOctetStatusReport = 0x20
+)
+
+// defaultValidityPeriod is the relative VP most modems ship with (24 hours)
+const defaultValidityPeriod = 167
+
+// TextModeParams holds the text-mode send parameters configured by AT+CSMP
+type TextModeParams struct {
+	// FirstOctet carries the SMS-SUBMIT flags (see the FirstOctet* constants)
+	FirstOctet int
+	// ValidityPeriod is how long the SMSC keeps trying to deliver the
+	// message. Zero selects the usual default of 24 hours.
+	ValidityPeriod time.Duration
+	// PID is the TP-Protocol-Identifier, normally 0
+	PID int
+	// DCS is the TP-Data-Coding-Scheme, 0 for GSM 7-bit and 8 for UCS2
+	DCS int
+}
+
+// SetTextModeParams configures the parameters applied to subsequent text-mode
+// sends. When a validity period is set the relative-VP flag is forced on in
+// the first octet so the modem actually honors it.
+func (s *SMSHandler) SetTextModeParams(params TextModeParams) error {
+	return s.setTextModeParams(context.Background(), params)
+}
+
+// setTextModeParams does the work of SetTextModeParams
+func (s *SMSHandler) setTextModeParams(ctx context.Context, params TextModeParams) error {
+	fo := params.FirstOctet
+	if fo == 0 {
+		fo = FirstOctetSubmit | FirstOctetRelativeVP
+	}
+
+	vp := defaultValidityPeriod
+	if params.ValidityPeriod > 0 {
+		vp = encodeValidityPeriod(params.ValidityPeriod)
+		fo = (fo &^ 0x18) | FirstOctetRelativeVP
+	}
+
+	cmd := fmt.Sprintf("AT+CSMP=%d,%d,%d,%d", fo, vp, params.PID, params.DCS)
+	if _, err := s.sendATCommandExpectOKContext(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to set text mode parameters: %v", err)
+	}
+	return nil
+}
+
+// GetTextModeParams reads the current AT+CSMP settings from the modem
+func (s *SMSHandler) GetTextModeParams() (TextModeParams, error) {
+	return s.getTextModeParams(context.Background())
+}
+
+// getTextModeParams does the work of GetTextModeParams
+func (s *SMSHandler) getTextModeParams(ctx context.Context) (TextModeParams, error) {
+	response, err := s.sendATCommandExpectOKContext(ctx, "AT+CSMP?")
+	if err != nil {
+		return TextModeParams{}, fmt.Errorf("failed to read text mode parameters: %v", err)
+	}
+
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "+CSMP:") {
+			continue
+		}
+
+		var params TextModeParams
+		var vp int
+		if _, err := fmt.Sscanf(line, "+CSMP: %d,%d,%d,%d", &params.FirstOctet, &vp, &params.PID, &params.DCS); err != nil {
+			return TextModeParams{}, fmt.Errorf("failed to parse CSMP response %q: %v", line, err)
+		}
+		params.ValidityPeriod = decodeValidityPeriod(vp)
+		return params, nil
+	}
+
+	return TextModeParams{}, fmt.Errorf("no +CSMP line in response: %q", response)
+}
+
+// applyValidityPeriod sets the text-mode validity period for one send and
+// returns a function that restores the previous AT+CSMP settings. ctx is the
+// send's exclusive claim on the modem; see beginExclusive.
+func (s *SMSHandler) applyValidityPeriod(ctx context.Context, d time.Duration) (restore func(), err error) {
+	previous, err := s.getTextModeParams(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply validity period: %v", err)
+	}
+
+	params := previous
+	params.ValidityPeriod = d
+	if err := s.setTextModeParams(ctx, params); err != nil {
+		return nil, fmt.Errorf("failed to apply validity period: %v", err)
+	}
+
+	return func() {
+		if err := s.setTextModeParams(ctx, previous); err != nil {
+			s.logger().Printf("Failed to restore text mode parameters: %v", err)
+		}
+	}, nil
+}
+
+// encodeValidityPeriod converts a duration to the relative TP-VP value from
+// GSM 03.40, rounding up to the next representable step and clamping to the
+// range 5 minutes to 63 weeks.
+func encodeValidityPeriod(d time.Duration) int {
+	const day = 24 * time.Hour
+	const week = 7 * day
+
+	switch {
+	case d <= 12*time.Hour:
+		// 0-143: (VP + 1) * 5 minutes
+		steps := int((d + 5*time.Minute - 1) / (5 * time.Minute))
+		if steps < 1 {
+			steps = 1
+		}
+		return steps - 1
+	case d <= day:
+		// 144-167: 12 hours + (VP - 143) * 30 minutes
+		steps := int((d - 12*time.Hour + 30*time.Minute - 1) / (30 * time.Minute))
+		return 143 + steps
+	case d <= 30*day:
+		// 168-196: (VP - 166) days
+		days := int((d + day - 1) / day)
+		return 166 + days
+	default:
+		// 197-255: (VP - 192) weeks
+		weeks := int((d + week - 1) / week)
+		if weeks < 5 {
+			weeks = 5
+		}
+		if weeks > 63 {
+			weeks = 63
+		}
+		return 192 + weeks
+	}
+}
+
+// decodeValidityPeriod converts a relative TP-VP value back to a duration
+func decodeValidityPeriod(vp int) time.Duration {
+	switch {
+	case vp < 0:
+		return 0
+	case vp <= 143:
+		return time.Duration(vp+1) * 5 * time.Minute
+	case vp <= 167:
+		return 12*time.Hour + time.Duration(vp-143)*30*time.Minute
+	case vp <= 196:
+		return time.Duration(vp-166) * 24 * time.Hour
+	case vp <= 255:
+		return time.Duration(vp-192) * 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,110 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidityPeriodEncoding(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		vp       int
+	}{
+		{1 * time.Minute, 0},
+		{5 * time.Minute, 0},
+		{6 * time.Minute, 1},
+		{12 * time.Hour, 143},
+		{13 * time.Hour, 145},
+		{24 * time.Hour, 167},
+		{3 * 24 * time.Hour, 169},
+		{30 * 24 * time.Hour, 196},
+		{31 * 24 * time.Hour, 197},
+		{10 * 365 * 24 * time.Hour, 255},
+	}
+
+	for _, tt := range tests {
+		if got := encodeValidityPeriod(tt.duration); got != tt.vp {
+			t.Errorf("encodeValidityPeriod(%v): got %d, want %d", tt.duration, got, tt.vp)
+		}
+	}
+
+	// Every encoded value must cover at least the requested duration
+	for _, tt := range tests[:len(tests)-1] {
+		if got := decodeValidityPeriod(encodeValidityPeriod(tt.duration)); got < tt.duration {
+			t.Errorf("round trip of %v shortened validity to %v", tt.duration, got)
+		}
+	}
+}
+
+func TestSetTextModeParams(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CSMP=49,11,0,0", "OK\r\n")
+
+	err := handler.SetTextModeParams(TextModeParams{
+		FirstOctet:     FirstOctetSubmit | FirstOctetStatusReport,
+		ValidityPeriod: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("SetTextModeParams failed: %v", err)
+	}
+
+	if !strings.Contains(mockPort.GetWrittenData(), "AT+CSMP=49,11,0,0") {
+		t.Errorf("unexpected command written: %q", mockPort.GetWrittenData())
+	}
+}
+
+func TestGetTextModeParams(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CSMP?", "+CSMP: 17,167,0,8\r\nOK\r\n")
+
+	params, err := handler.GetTextModeParams()
+	if err != nil {
+		t.Fatalf("GetTextModeParams failed: %v", err)
+	}
+
+	want := TextModeParams{FirstOctet: 17, ValidityPeriod: 24 * time.Hour, PID: 0, DCS: 8}
+	if params != want {
+		t.Errorf("got %+v, want %+v", params, want)
+	}
+}
+
+func TestSendSMSValidityPeriodTextMode(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CSMP?", "+CSMP: 17,167,0,0\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSMP=17,0,0,0", "OK\r\n")
+	mockPort.AddResponse("AT+CSMP=17,167,0,0", "OK\r\n")
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Code 1234\x1A", "\r\n+CMGS: 7\r\nOK\r\n")
+
+	if err := handler.SendSMS("+1234567890", "Code 1234", WithValidityPeriod(5*time.Minute)); err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+
+	written := mockPort.GetWrittenData()
+	set := strings.Index(written, "AT+CSMP=17,0,0,0")
+	send := strings.Index(written, "AT+CMGS")
+	restore := strings.Index(written, "AT+CSMP=17,167,0,0")
+	if set < 0 || send < set || restore < send {
+		t.Errorf("expected CSMP set, send, then restore; wrote %q", written)
+	}
+}
+
+func TestValidityPeriodPDU(t *testing.T) {
+	o := applySendOptions([]SendOption{WithValidityPeriod(90 * time.Minute)})
+	pdu, _, err := encodeSubmitPDU("+46708251358", "hellohello", AddressTypeAuto, "", o.relativeValidity())
+	if err != nil {
+		t.Fatalf("encodeSubmitPDU failed: %v", err)
+	}
+	// TP-PID, TP-DCS, then TP-VP 17: 18 steps of 5 minutes
+	if want := "0011000B916407281553F8000011"; !strings.HasPrefix(pdu, want) {
+		t.Errorf("got %s, want prefix %s", pdu, want)
+	}
+
+	if got := applySendOptions(nil).relativeValidity(); got != defaultValidityPeriod {
+		t.Errorf("default validity: got %d, want %d", got, defaultValidityPeriod)
+	}
+}
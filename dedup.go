@@ -0,0 +1,78 @@
+package smshandler
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow is how long a delivered message is remembered when
+// de-duplication is enabled without an explicit window
+const DefaultDedupWindow = 30 * time.Second
+
+// deduplicator remembers recently delivered messages so a message reported
+// twice (for example via both +CMT and +CMTI) reaches the callback once
+type deduplicator struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	if window <= 0 {
+		window = DefaultDedupWindow
+	}
+	return &deduplicator{
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// seenRecently records the message and reports whether an identical one was
+// already delivered within the window
+func (d *deduplicator) seenRecently(sms SMS, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop entries that have slid out of the window
+	for key, at := range d.seen {
+		if now.Sub(at) > d.window {
+			delete(d.seen, key)
+		}
+	}
+
+	key := dedupKey(sms)
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
+
+// dedupKey identifies a message by sender, modem timestamp and body hash.
+// The normalized sender is used when there is one, so the same number
+// reported in two formats still matches.
+func dedupKey(sms SMS) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(sms.Message))
+	sender := sms.SenderNormalized
+	if sender == "" {
+		sender = sms.Sender
+	}
+	return sender + "\x00" + sms.Date + "\x00" + string(h.Sum(nil))
+}
+
+// deliver hands an incoming message to the callback, dropping it if it
+// duplicates one delivered within the de-duplication window. Messages the
+// incoming filter rejects skip the callback only.
+func (s *SMSHandler) deliver(sms SMS, callback func(SMS)) {
+	s.normalizeSender(&sms)
+	if s.dedup != nil && s.dedup.seenRecently(sms, s.clock().Now()) {
+		return
+	}
+	s.metricsRecorder().IncReceived()
+	s.runIncomingHooks(sms)
+	if s.passesFilter(sms) {
+		s.invokeCallback(callback, sms)
+	}
+}
@@ -0,0 +1,67 @@
+package smshandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduplicatorWindow(t *testing.T) {
+	d := newDeduplicator(time.Minute)
+	sms := SMS{Sender: "+15551234567", Date: "24/01/15,10:30:45+00", Message: "Hello"}
+	now := time.Now()
+
+	if d.seenRecently(sms, now) {
+		t.Fatal("first delivery reported as duplicate")
+	}
+	if !d.seenRecently(sms, now.Add(10*time.Second)) {
+		t.Error("repeat within window not reported as duplicate")
+	}
+
+	other := sms
+	other.Message = "Hello again"
+	if d.seenRecently(other, now.Add(10*time.Second)) {
+		t.Error("different body reported as duplicate")
+	}
+
+	if d.seenRecently(sms, now.Add(2*time.Minute)) {
+		t.Error("repeat after window reported as duplicate")
+	}
+}
+
+// Simulates a modem that reports the same message via +CMT and +CMTI
+func TestDeduplicationDropsDoubleDelivery(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.dedup = newDeduplicator(time.Minute)
+
+	var received []SMS
+	callback := func(sms SMS) { received = append(received, sms) }
+
+	// Direct delivery: header followed by the body
+	mockPort.SimulateIncoming("Your code is 1234\r\n\r\n")
+	handler.handleCMTMessage(`+CMT: "+15551234567","","24/01/15,10:30:45+00"`, callback)
+
+	// Stored-index notification for the same message
+	mockPort.AddResponse("AT+CMGR=3",
+		"+CMGR: \"REC UNREAD\",\"+15551234567\",\"24/01/15,10:30:45+00\"\r\nYour code is 1234\r\nOK\r\n")
+	handler.handleCMTIMessage(`+CMTI: "SM",3`, callback)
+
+	if len(received) != 1 {
+		t.Fatalf("expected 1 delivery, got %d: %+v", len(received), received)
+	}
+
+	// Without de-duplication both deliveries reach the callback
+	mockPort = NewMockSerialPort()
+	handler = newMockHandler(mockPort)
+	received = nil
+
+	mockPort.SimulateIncoming("Your code is 1234\r\n\r\n")
+	handler.handleCMTMessage(`+CMT: "+15551234567","","24/01/15,10:30:45+00"`, callback)
+	mockPort.AddResponse("AT+CMGR=3",
+		"+CMGR: \"REC UNREAD\",\"+15551234567\",\"24/01/15,10:30:45+00\"\r\nYour code is 1234\r\nOK\r\n")
+	handler.handleCMTIMessage(`+CMTI: "SM",3`, callback)
+
+	if len(received) != 2 {
+		t.Fatalf("expected 2 deliveries without dedup, got %d", len(received))
+	}
+}
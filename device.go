@@ -0,0 +1,108 @@
+package smshandler
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ChargeState is the <bcs> field of AT+CBC
+type ChargeState int
+
+// Charge states as defined by GSM 07.07. Some vendors reuse the field
+// differently (Quectel reports 0 not charging, 1 charging, 2 charged), so
+// interpret it together with the modem model.
+const (
+	ChargeOnBattery    ChargeState = 0
+	ChargeExternal     ChargeState = 1
+	ChargeNoBattery    ChargeState = 2
+	ChargePowerFault   ChargeState = 3
+	ChargeStateUnknown ChargeState = -1
+)
+
+// BatteryInfo is the battery status reported by AT+CBC
+type BatteryInfo struct {
+	State ChargeState
+	// Percent is the remaining charge from 0 to 100
+	Percent int
+	// VoltageMV is the supply voltage in millivolts, or 0 when the modem
+	// does not report it
+	VoltageMV int
+}
+
+// BatteryStatus reads the battery charge state and level with AT+CBC. It
+// returns an error wrapping ErrNotSupported when the modem has no battery
+// reporting.
+func (s *SMSHandler) BatteryStatus() (BatteryInfo, error) {
+	response, err := s.queryDevice("AT+CBC")
+	if err != nil {
+		return BatteryInfo{}, fmt.Errorf("failed to read battery status: %w", err)
+	}
+
+	fields, ok := resultFields(response, "+CBC:")
+	if !ok || len(fields) < 2 {
+		return BatteryInfo{}, fmt.Errorf("unexpected battery status response %q", response)
+	}
+
+	info := BatteryInfo{State: ChargeStateUnknown}
+	if state, err := strconv.Atoi(fields[0]); err == nil {
+		info.State = ChargeState(state)
+	}
+	if info.Percent, err = strconv.Atoi(fields[1]); err != nil {
+		return BatteryInfo{}, fmt.Errorf("invalid battery level %q: %v", fields[1], err)
+	}
+	if len(fields) > 2 {
+		info.VoltageMV, _ = strconv.Atoi(fields[2])
+	}
+	return info, nil
+}
+
+// temperatureCommands are the vendor commands tried by Temperature, with
+// the position of the temperature among the result fields
+var temperatureCommands = []struct {
+	command string
+	prefix  string
+	field   int
+}{
+	{"AT+QTEMP", "+QTEMP:", 0},       // Quectel: +QTEMP: <pmic>,<xo>,<pa>
+	{"AT+CPMUTEMP", "+CPMUTEMP:", 0}, // SIMCom: +CPMUTEMP: <temp>
+	{"AT+CMTE?", "+CMTE:", 1},        // SIM800: +CMTE: <mode>,<temp>
+}
+
+// Temperature returns the modem's chip temperature in degrees Celsius. There
+// is no standard command, so the common vendor commands are tried in turn;
+// if none is implemented the error wraps ErrNotSupported.
+func (s *SMSHandler) Temperature() (float64, error) {
+	for _, tc := range temperatureCommands {
+		response, err := s.queryDevice(tc.command)
+		if err != nil {
+			continue
+		}
+
+		fields, ok := resultFields(response, tc.prefix)
+		if !ok || len(fields) <= tc.field {
+			continue
+		}
+		temp, err := strconv.ParseFloat(fields[tc.field], 64)
+		if err != nil {
+			continue
+		}
+		return temp, nil
+	}
+	return 0, fmt.Errorf("failed to read temperature: %w", ErrNotSupported)
+}
+
+// queryDevice sends a status query, reporting commands the modem does not
+// implement as ErrNotSupported rather than a generic failure
+func (s *SMSHandler) queryDevice(command string) (string, error) {
+	response, err := s.sendATCommand(command)
+	if err != nil {
+		return response, err
+	}
+	if modemErr := parseModemError(response); modemErr != nil {
+		if modemErr.unsupported() {
+			return response, fmt.Errorf("%s: %w", command, ErrNotSupported)
+		}
+		return response, fmt.Errorf("modem returned error: %w", modemErr)
+	}
+	return response, nil
+}
@@ -0,0 +1,58 @@
+package smshandler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBatteryStatus(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CBC", "+CBC: 0,85,4012\r\nOK\r\n")
+
+	info, err := handler.BatteryStatus()
+	if err != nil {
+		t.Fatalf("BatteryStatus failed: %v", err)
+	}
+	want := BatteryInfo{State: ChargeOnBattery, Percent: 85, VoltageMV: 4012}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestBatteryStatusNotSupported(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CBC", "+CME ERROR: 4\r\n")
+
+	if _, err := handler.BatteryStatus(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestTemperature(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+QTEMP", "ERROR\r\n")
+	mockPort.AddResponse("AT+CPMUTEMP", "+CPMUTEMP: 38\r\nOK\r\n")
+
+	temp, err := handler.Temperature()
+	if err != nil {
+		t.Fatalf("Temperature failed: %v", err)
+	}
+	if temp != 38 {
+		t.Errorf("got %v, want 38", temp)
+	}
+}
+
+func TestTemperatureNotSupported(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	for _, tc := range temperatureCommands {
+		mockPort.AddResponse(tc.command, "ERROR\r\n")
+	}
+
+	if _, err := handler.Temperature(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
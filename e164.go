@@ -0,0 +1,74 @@
+package smshandler
+
+import "strings"
+
+// maxE164Digits is the longest an E.164 number can be, country code included
+const maxE164Digits = 15
+
+// minE164Digits is the shortest number treated as a phone number rather
+// than a short code, which has no E.164 form
+const minE164Digits = 7
+
+// toE164 converts a phone number to E.164 ("+" and digits only), or returns
+// "" when it cannot. International numbers keep their country code; a
+// national number gets countryCode after any trunk prefix "0" is dropped.
+// A number without '+' that already starts with countryCode and is at
+// least 11 digits long is taken to be international with the '+' missing,
+// as some modems report it. Alphanumeric senders and short codes have no
+// E.164 form.
+func toE164(number, countryCode string) string {
+	n := NormalizePhoneNumber(number)
+	digits := strings.TrimPrefix(n, "+")
+	if !allDigits(digits) {
+		return ""
+	}
+
+	if !strings.HasPrefix(n, "+") {
+		switch {
+		case countryCode == "" || !allDigits(countryCode):
+			return ""
+		case strings.HasPrefix(digits, "0"):
+			digits = countryCode + strings.TrimLeft(digits, "0")
+		case strings.HasPrefix(digits, countryCode) && len(digits) >= 11:
+		default:
+			digits = countryCode + digits
+		}
+	}
+
+	if len(digits) < minE164Digits || len(digits) > maxE164Digits {
+		return ""
+	}
+	return "+" + digits
+}
+
+// allDigits reports whether s is a non-empty string of ASCII digits
+func allDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeSender fills in SenderNormalized when WithSenderNormalization
+// is set
+func (s *SMSHandler) normalizeSender(sms *SMS) {
+	if s.cfg.normalizeSenders && sms.SenderNormalized == "" {
+		sms.SenderNormalized = toE164(sms.Sender, s.cfg.countryCode)
+	}
+}
+
+// isFrom reports whether sms came from number, comparing E.164 forms when
+// sender normalization is on and both numbers have one
+func (s *SMSHandler) isFrom(sms SMS, number string) bool {
+	if sms.SenderNormalized != "" {
+		if n := toE164(number, s.cfg.countryCode); n != "" {
+			return sms.SenderNormalized == n
+		}
+	}
+	return sameNumber(sms.Sender, number)
+}
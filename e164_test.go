@@ -0,0 +1,58 @@
+package smshandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToE164(t *testing.T) {
+	tests := []struct {
+		number      string
+		countryCode string
+		want        string
+	}{
+		{"+1 (555) 123-4567", "", "+15551234567"},
+		{"0044 20 7946 0958", "", "+442079460958"},
+		{"5551234567", "1", "+15551234567"},
+		{"15551234567", "1", "+15551234567"},
+		{"07700 900123", "44", "+447700900123"},
+		{"447700900123", "44", "+447700900123"},
+		{"5551234567", "", ""},
+		{"VERIFY", "1", ""},
+		{"12345", "1", ""},
+		{"+1234567890123456", "", ""},
+		{"", "1", ""},
+	}
+
+	for _, tt := range tests {
+		if got := toE164(tt.number, tt.countryCode); got != tt.want {
+			t.Errorf("toE164(%q, %q) = %q, want %q", tt.number, tt.countryCode, got, tt.want)
+		}
+	}
+}
+
+func TestSenderNormalization(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	WithSenderNormalization("+1")(&handler.cfg)
+	handler.dedup = newDeduplicator(time.Minute)
+
+	var got []SMS
+	callback := func(sms SMS) { got = append(got, sms) }
+	date := "24/01/15,10:30:45+00"
+	handler.deliver(SMS{Sender: "+15551234567", Date: date, Message: "hi"}, callback)
+	handler.deliver(SMS{Sender: "5551234567", Date: date, Message: "hi"}, callback)
+	handler.deliver(SMS{Sender: "VERIFY", Date: date, Message: "code 1234"}, callback)
+
+	if len(got) != 2 {
+		t.Fatalf("delivered %d messages, want the duplicate dropped: %+v", len(got), got)
+	}
+	if got[0].Sender != "+15551234567" || got[0].SenderNormalized != "+15551234567" {
+		t.Errorf("first message %+v", got[0])
+	}
+	if got[1].Sender != "VERIFY" || got[1].SenderNormalized != "" {
+		t.Errorf("alphanumeric sender normalized: %+v", got[1])
+	}
+	if !handler.isFrom(got[0], "(555) 123-4567") {
+		t.Error("national number does not match the normalized sender")
+	}
+}
@@ -0,0 +1,107 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ModemError is an error result reported by the modem: a plain ERROR, or a
+// +CMS ERROR (message service) or +CME ERROR (equipment) with a code or
+// verbose text depending on the modem's AT+CMEE setting
+type ModemError struct {
+	// Kind is "CMS", "CME", or empty for a plain ERROR
+	Kind string
+	// Code is the numeric error code, or -1 when none was reported
+	Code int
+	// Text is the verbose error text, if the modem reported one
+	Text string
+}
+
+func (e *ModemError) Error() string {
+	switch {
+	case e.Kind == "":
+		return "modem error"
+	case e.Code >= 0:
+		return fmt.Sprintf("+%s ERROR: %d", e.Kind, e.Code)
+	default:
+		return fmt.Sprintf("+%s ERROR: %s", e.Kind, e.Text)
+	}
+}
+
+// class returns the metrics class for the error
+func (e *ModemError) class() string {
+	switch e.Kind {
+	case "CMS":
+		return ErrorClassCMS
+	case "CME":
+		return ErrorClassCME
+	}
+	return ErrorClassError
+}
+
+// ErrNotSupported is returned, wrapped, by queries the modem does not
+// implement
+var ErrNotSupported = errors.New("not supported by modem")
+
+// ErrNoResponse is returned, wrapped, when a command times out without the
+// modem answering at all
+var ErrNoResponse = errors.New("no response from modem")
+
+// ErrIncompleteResponse is returned, wrapped, when the modem started
+// answering a command but never sent its final result, even after a grace
+// period. The partial response is returned alongside it.
+var ErrIncompleteResponse = errors.New("modem response incomplete")
+
+// unsupported reports whether the error means the modem does not know the
+// command: a plain ERROR, or +CME ERROR 4 (operation not supported)
+func (e *ModemError) unsupported() bool {
+	switch e.Kind {
+	case "":
+		return true
+	case "CME":
+		return e.Code == 4 || strings.EqualFold(e.Text, "operation not supported")
+	}
+	return false
+}
+
+// parseModemError finds the first error result line in a response and
+// returns it as a ModemError, or nil if the response contains none
+func parseModemError(response string) *ModemError {
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "ERROR" {
+			return &ModemError{Code: -1}
+		}
+
+		for _, kind := range []string{"CMS", "CME"} {
+			prefix := "+" + kind + " ERROR:"
+			if !strings.HasPrefix(line, prefix) {
+				continue
+			}
+
+			detail := strings.TrimSpace(strings.TrimPrefix(line, prefix))
+			if code, err := strconv.Atoi(detail); err == nil {
+				return &ModemError{Kind: kind, Code: code}
+			}
+			return &ModemError{Kind: kind, Code: -1, Text: detail}
+		}
+	}
+	return nil
+}
+
+// finalResultError parses only the last line of a response, so a listing
+// whose message bodies happen to read "ERROR" is not mistaken for a failure
+func finalResultError(response string) *ModemError {
+	response = strings.TrimSpace(response)
+	return parseModemError(response[strings.LastIndex(response, "\n")+1:])
+}
+
+// isFinalResult reports whether a response line is a final result code
+// that ends a command's response
+func isFinalResult(line string) bool {
+	return line == "OK" || line == "ERROR" ||
+		strings.HasPrefix(line, "+CME ERROR:") || strings.HasPrefix(line, "+CMS ERROR:")
+}
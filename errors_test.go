@@ -0,0 +1,226 @@
+package smshandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseModemError(t *testing.T) {
+	tests := []struct {
+		response string
+		want     *ModemError
+	}{
+		{"OK", nil},
+		{"+CMGS: 5\r\nOK", nil},
+		{"\r\nERROR\r\n", &ModemError{Code: -1}},
+		{"+CMS ERROR: 500", &ModemError{Kind: "CMS", Code: 500}},
+		{"\r\n+CME ERROR: 10\r\n", &ModemError{Kind: "CME", Code: 10}},
+		{"+CME ERROR: SIM not inserted", &ModemError{Kind: "CME", Code: -1, Text: "SIM not inserted"}},
+	}
+
+	for _, tt := range tests {
+		got := parseModemError(tt.response)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("parseModemError(%q): got %+v, want %+v", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestSendSMSErrorBeforePrompt(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n+CMS ERROR: 304\r\n")
+	}()
+
+	start := time.Now()
+	err := handler.SendSMS("bad-number", "Hello")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("error took %v, should not wait for the prompt timeout", elapsed)
+	}
+
+	var modemErr *ModemError
+	if !errors.As(err, &modemErr) {
+		t.Fatalf("expected ModemError, got %T: %v", err, err)
+	}
+	if modemErr.Kind != "CMS" || modemErr.Code != 304 {
+		t.Errorf("unexpected modem error: %+v", modemErr)
+	}
+	if errorClass(err) != ErrorClassCMS {
+		t.Errorf("error class: got %q", errorClass(err))
+	}
+}
+
+// bodyFailPort fails writes of a message body (anything ending in Ctrl+Z)
+type bodyFailPort struct {
+	*MockSerialPort
+}
+
+func (b *bodyFailPort) Write(p []byte) (int, error) {
+	if strings.HasSuffix(string(p), "\x1A") {
+		return 0, errors.New("write failed")
+	}
+	return b.MockSerialPort.Write(p)
+}
+
+func TestSendSMSCancelsCompositionOnBodyWriteFailure(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.port = &bodyFailPort{mockPort}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n> ")
+	}()
+
+	if err := handler.SendSMS("+1234567890", "Hello"); err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.HasSuffix(mockPort.GetWrittenData(), "\x1B") {
+		t.Errorf("ESC not written after failed body write: %q", mockPort.GetWrittenData())
+	}
+}
+
+func TestCancelComposition(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("\x1B", "\r\nOK\r\n")
+	handler.composeOpen = true
+
+	if err := handler.CancelComposition(); err != nil {
+		t.Fatalf("CancelComposition failed: %v", err)
+	}
+	if mockPort.GetWrittenData() != "\x1B" {
+		t.Errorf("got %q, want ESC", mockPort.GetWrittenData())
+	}
+	if handler.composeOpen {
+		t.Error("composition still marked open after CancelComposition")
+	}
+	if handler.reader.Buffered() > 0 {
+		t.Error("modem's OK left for the next command")
+	}
+}
+
+// A cancel issued while a send owns the port waits for it to finish
+func TestCancelCompositionWaitsForSend(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("\x1B", "\r\nOK\r\n")
+
+	handler.readerMu.Lock() // a send in progress
+	done := make(chan error, 1)
+	go func() { done <- handler.CancelComposition() }()
+
+	time.Sleep(20 * time.Millisecond)
+	if mockPort.GetWrittenData() != "" {
+		t.Errorf("ESC written during a send: %q", mockPort.GetWrittenData())
+	}
+	handler.readerMu.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatalf("CancelComposition failed: %v", err)
+	}
+	if mockPort.GetWrittenData() != "\x1B" {
+		t.Errorf("got %q, want ESC", mockPort.GetWrittenData())
+	}
+}
+
+func TestCommandRecoversStuckPrompt(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Hello\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+	mockPort.AddResponse("\x1B", "\r\nOK\r\n")
+	mockPort.AddResponse("AT", "OK\r\n")
+
+	if err := handler.SendSMS("+1234567890", "Hello"); err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+	if handler.composeOpen {
+		t.Fatal("composition still open after a completed send")
+	}
+
+	// An interrupted send left the modem at the '>' prompt
+	handler.composeOpen = true
+	before := len(mockPort.GetWrittenData())
+
+	response, err := handler.sendATCommand("AT")
+	if err != nil || response != "OK" {
+		t.Fatalf("command after stuck prompt: %q, %v", response, err)
+	}
+	if written := mockPort.GetWrittenData()[before:]; written != "\x1BAT\r\n" {
+		t.Errorf("written %q, want ESC before the command", written)
+	}
+	if handler.composeOpen {
+		t.Error("composition still marked open after recovery")
+	}
+}
+
+func TestComposeLineEndingAndCancelTerminator(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	WithCommandLineEnding("\r\n")(&handler.cfg)
+	WithComposeTerminator(ComposeCancel)(&handler.cfg)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n> ")
+		time.Sleep(150 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\nOK\r\n")
+	}()
+
+	err := handler.SendSMS("+15551234567", "Dry run")
+	if !errors.Is(err, ErrCompositionCancelled) {
+		t.Fatalf("expected ErrCompositionCancelled, got %v", err)
+	}
+
+	written := mockPort.GetWrittenData()
+	if !strings.Contains(written, "AT+CMGS=\"+15551234567\",145\r\n") {
+		t.Errorf("configured line ending not used: %q", written)
+	}
+	if !strings.HasSuffix(written, "Dry run"+ComposeCancel) || strings.Contains(written, ComposeSubmit) {
+		t.Errorf("body not terminated with ESC: %q", written)
+	}
+}
+
+func TestTextModeRejectsControlCharacters(t *testing.T) {
+	tests := []struct {
+		message string
+		unsafe  bool
+	}{
+		{"Plain text", false},
+		{"Say \"hi\"\non two lines\r\n", false},
+		{"Cut\x1Ahere", true},
+		{"Cancel\x1B", true},
+		{"Bell\x07", true},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		handler.clk = newFakeClock()
+		mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+		mockPort.AddResponse(tt.message+"\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+
+		err := handler.SendSMS("+1234567890", tt.message)
+		var unsafe *UnsafeTextError
+		if got := errors.As(err, &unsafe); got != tt.unsafe {
+			t.Errorf("%q: got error %v, want unsafe=%v", tt.message, err, tt.unsafe)
+		}
+		if tt.unsafe && mockPort.GetWrittenData() != "" {
+			t.Errorf("%q: nothing should be sent, got %q", tt.message, mockPort.GetWrittenData())
+		}
+	}
+
+	// PDU mode encodes the same characters safely
+	if _, _, err := encodeSubmitPDU("+1234567890", "Cut\x1Ahere", AddressTypeAuto, "", defaultValidityPeriod); err != nil {
+		t.Errorf("PDU encoding failed: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package smshandler
+
+// SetIncomingFilter makes the listener pass only messages for which keep
+// returns true to the ListenForIncomingSMS callback, for example to follow
+// a single conversation. Other messages are still received, counted and
+// handed to Subscribe channels. It runs on the listener goroutine, so it
+// should return quickly. Passing nil delivers every message again.
+func (s *SMSHandler) SetIncomingFilter(keep func(SMS) bool) {
+	s.filterMu.Lock()
+	defer s.filterMu.Unlock()
+	s.incomingFilter = keep
+}
+
+// passesFilter reports whether sms should reach the listener callback
+func (s *SMSHandler) passesFilter(sms SMS) bool {
+	s.filterMu.RLock()
+	keep := s.incomingFilter
+	s.filterMu.RUnlock()
+
+	return keep == nil || keep(sms)
+}
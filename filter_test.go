@@ -0,0 +1,28 @@
+package smshandler
+
+import "testing"
+
+func TestSetIncomingFilter(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	handler.SetIncomingFilter(func(sms SMS) bool { return sms.Sender == "+1234567890" })
+
+	var hooked, delivered []string
+	handler.addIncomingHook(func(sms SMS) { hooked = append(hooked, sms.Sender) })
+	callback := func(sms SMS) { delivered = append(delivered, sms.Sender) }
+
+	handler.deliver(SMS{Sender: "+1234567890", Message: "hi"}, callback)
+	handler.deliver(SMS{Sender: "+1987654321", Message: "spam"}, callback)
+
+	if len(delivered) != 1 || delivered[0] != "+1234567890" {
+		t.Errorf("callback got %q, want only the matching sender", delivered)
+	}
+	if len(hooked) != 2 {
+		t.Errorf("hooks got %q, want every message", hooked)
+	}
+
+	handler.SetIncomingFilter(nil)
+	handler.deliver(SMS{Sender: "+1987654321", Message: "again"}, callback)
+	if len(delivered) != 2 {
+		t.Errorf("nil filter should deliver everything, got %q", delivered)
+	}
+}
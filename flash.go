@@ -0,0 +1,58 @@
+package smshandler
+
+import (
+	"fmt"
+)
+
+// MessageClass is the message class from a message's data coding scheme,
+// which tells the receiving phone how to handle it. Most messages carry no
+// class.
+type MessageClass string
+
+const (
+	// ClassFlash (class 0) messages are meant to be shown immediately and
+	// not stored, and are often used for urgent carrier notices
+	ClassFlash MessageClass = "flash"
+	// ClassME (class 1) messages are stored in the modem
+	ClassME MessageClass = "me"
+	// ClassSIM (class 2) messages are stored on the SIM
+	ClassSIM MessageClass = "sim"
+	// ClassTE (class 3) messages are meant for the attached terminal
+	ClassTE MessageClass = "te"
+)
+
+// messageClasses maps the two class bits of a TP-DCS octet to a class
+var messageClasses = [4]MessageClass{ClassFlash, ClassME, ClassSIM, ClassTE}
+
+// IsFlash reports whether the message is a class 0 (flash) message
+func (m SMS) IsFlash() bool {
+	return m.Class == ClassFlash
+}
+
+// dcsClass extracts the message class from a TP-DCS octet, or "" when the
+// coding group does not carry one
+func dcsClass(dcs byte) MessageClass {
+	switch {
+	case dcs&0x80 == 0:
+		// General data coding: the class bits only count when bit 4 is set
+		if dcs&0x10 == 0 {
+			return ""
+		}
+	case dcs&0xF0 == 0xF0:
+		// Data coding/message class group always has a class
+	default:
+		return ""
+	}
+	return messageClasses[dcs&0x03]
+}
+
+// discardFlash deletes a flash message the modem stored, once it has been
+// handed to the listener, when WithDiscardFlashMessages is set
+func (s *SMSHandler) discardFlash(sms SMS, index int) {
+	if !s.cfg.discardFlash || !sms.IsFlash() {
+		return
+	}
+	if _, err := s.sendATCommandExpectOK(fmt.Sprintf("AT+CMGD=%d", index)); err != nil {
+		s.logger().Printf("Failed to delete flash SMS %d: %v", index, err)
+	}
+}
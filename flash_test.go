@@ -0,0 +1,102 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+)
+
+// flashPDU is a class 0 SMS-DELIVER: the PDU from TestDecodePDU with a
+// TP-DCS of 0x10
+const flashPDU = "07911326040000F0040B911346610089F60010208062917314800CC8F71D14969741F977FD07"
+
+func TestDCSClass(t *testing.T) {
+	tests := []struct {
+		dcs  byte
+		want MessageClass
+	}{
+		{0x00, ""},
+		{0x10, ClassFlash},
+		{0x11, ClassME},
+		{0x12, ClassSIM},
+		{0x13, ClassTE},
+		{0x18, ClassFlash}, // UCS2 flash
+		{0x50, ClassFlash}, // automatic deletion group
+		{0x08, ""},
+		{0xF0, ClassFlash},
+		{0xF5, ClassME},
+		{0xC0, ""}, // message waiting indication group
+	}
+
+	for _, tt := range tests {
+		if got := dcsClass(tt.dcs); got != tt.want {
+			t.Errorf("dcsClass(%#02x): got %q, want %q", tt.dcs, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeFlashPDU(t *testing.T) {
+	sms, _, err := decodePDU(flashPDU)
+	if err != nil {
+		t.Fatalf("decodePDU failed: %v", err)
+	}
+	if !sms.IsFlash() {
+		t.Errorf("Class: got %q, want %q", sms.Class, ClassFlash)
+	}
+	if sms.Message != "How are you?" {
+		t.Errorf("Message: got %q", sms.Message)
+	}
+}
+
+func TestCMTHeaderClass(t *testing.T) {
+	sms, _, err := parseCMTHeader(`+CMT: "+1234567890",,"24/01/15,10:30:45+00",145,4,0,16,"+1555000",145,5`)
+	if err != nil {
+		t.Fatalf("parseCMTHeader failed: %v", err)
+	}
+	if !sms.IsFlash() {
+		t.Errorf("Class: got %q, want %q", sms.Class, ClassFlash)
+	}
+
+	sms, _, _ = parseCMTHeader(`+CMT: "+1234567890","","24/01/15,10:30:45+00"`)
+	if sms.Class != "" {
+		t.Errorf("basic header: got class %q, want none", sms.Class)
+	}
+}
+
+func TestDiscardFlashMessages(t *testing.T) {
+	for _, discard := range []bool{false, true} {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		handler.cfg.mode = ModePDU
+		handler.cfg.discardFlash = discard
+		mockPort.AddResponse("AT+CMGR=3", "+CMGR: 0,,38\r\n"+flashPDU+"\r\nOK\r\n")
+		mockPort.AddResponse("AT+CMGD=3", "OK\r\n")
+
+		var received []SMS
+		handler.handleCMTIMessage(`+CMTI: "SM",3`, func(sms SMS) {
+			received = append(received, sms)
+		})
+
+		if len(received) != 1 || !received[0].IsFlash() {
+			t.Errorf("discard=%v: flash message not delivered: %+v", discard, received)
+		}
+		deleted := strings.Contains(mockPort.GetWrittenData(), "AT+CMGD=3")
+		if deleted != discard {
+			t.Errorf("discard=%v: deleted = %v", discard, deleted)
+		}
+	}
+}
+
+func TestListenerDiscardsFlash(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	defer handler.Close()
+	handler.cfg.mode = ModePDU
+	handler.cfg.discardFlash = true
+	mockPort.AddResponse("AT+CMGR=3", "+CMGR: 0,,38\r\n"+flashPDU+"\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMGD=3", "OK\r\n")
+
+	if sms := listenOnce(t, handler, mockPort, "+CMTI: \"SM\",3\r\n"); !sms.IsFlash() {
+		t.Errorf("got %+v, want the flash message", sms)
+	}
+	waitForWritten(t, mockPort, "AT+CMGD=3")
+}
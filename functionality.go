@@ -0,0 +1,70 @@
+package smshandler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Functionality levels for SetFunctionality (AT+CFUN)
+const (
+	// FunctionalityMinimum turns off the radio and SIM
+	FunctionalityMinimum = 0
+	// FunctionalityFull is normal operation
+	FunctionalityFull = 1
+	// FunctionalityAirplane turns off the radio but keeps the SIM available
+	FunctionalityAirplane = 4
+)
+
+// DefaultResetWait is how long ResetModem waits for the modem to reboot
+// when no WithResetWait option is supplied
+const DefaultResetWait = 10 * time.Second
+
+// SetFunctionality sets the modem's functionality level with AT+CFUN, for
+// example FunctionalityAirplane to drop off the network and
+// FunctionalityFull to come back. Toggling the radio this way often
+// recovers a modem that stopped sending or receiving.
+func (s *SMSHandler) SetFunctionality(level int) error {
+	if level < 0 {
+		return fmt.Errorf("invalid functionality level %d", level)
+	}
+	if _, err := s.sendATCommandExpectOK(fmt.Sprintf("AT+CFUN=%d", level)); err != nil {
+		return fmt.Errorf("failed to set functionality level %d: %w", level, err)
+	}
+	return nil
+}
+
+// ResetModem reboots the modem with AT+CFUN=1,1, waits for it to come back
+// (see WithResetWait), reopens the port and runs the init sequence again. A
+// running listener is stopped for the reset and restarted with the same
+// callback afterwards.
+func (s *SMSHandler) ResetModem() error {
+	// The modem may reboot before it answers, so only an explicit error
+	// means the reset was refused
+	response, err := s.sendATCommand("AT+CFUN=1,1")
+	if modemErr := parseModemError(response); modemErr != nil {
+		return fmt.Errorf("modem refused reset: %w", modemErr)
+	}
+	if err != nil {
+		s.logger().Debugf("no reply to AT+CFUN=1,1, assuming the modem is rebooting: %v", err)
+	}
+
+	// Wait for the listener to stop so it is not still reading the port
+	// when it is reopened
+	stopped, _ := s.stopListener(context.Background())
+	s.clock().Sleep(s.resetWait())
+
+	if s.portName != "" {
+		err = s.Reconnect()
+	} else if !s.cfg.skipInit {
+		err = s.initModem()
+	}
+	if err != nil {
+		return fmt.Errorf("modem did not come back after reset: %v", err)
+	}
+
+	if stopped != nil {
+		s.ListenForIncomingSMS(stopped.callback)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package smshandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSetFunctionality(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CFUN=4", "OK\r\n")
+	mockPort.AddResponse("AT+CFUN=0", "+CME ERROR: 3\r\n")
+
+	if err := handler.SetFunctionality(FunctionalityAirplane); err != nil {
+		t.Errorf("SetFunctionality(4) failed: %v", err)
+	}
+
+	err := handler.SetFunctionality(FunctionalityMinimum)
+	var modemErr *ModemError
+	if !errors.As(err, &modemErr) || modemErr.Code != 3 {
+		t.Errorf("expected CME error 3, got %v", err)
+	}
+}
+
+func TestResetModem(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	clk := newFakeClock()
+	handler.clk = clk
+	mockPort.AddResponse("AT+CFUN=1,1", "OK\r\n")
+	mockPort.AddResponse("AT+CMGF?", "+CMGF: 1\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSCS?", "+CSCS: \"GSM\"\r\nOK\r\n")
+
+	start := clk.Now()
+	if err := handler.ResetModem(); err != nil {
+		t.Fatalf("ResetModem failed: %v", err)
+	}
+	if waited := clk.Now().Sub(start); waited < DefaultResetWait {
+		t.Errorf("waited %v for the reboot, want at least %v", waited, DefaultResetWait)
+	}
+
+	written := mockPort.GetWrittenData()
+	reset := strings.Index(written, "AT+CFUN=1,1")
+	reinit := strings.Index(written, "AT+CMGF=1")
+	if reset < 0 || reinit < reset {
+		t.Errorf("modem not re-initialized after reset: %q", written)
+	}
+}
+
+func TestResetModemRefused(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CFUN=1,1", "ERROR\r\n")
+
+	if err := handler.ResetModem(); err == nil {
+		t.Error("expected an error when the modem refuses the reset")
+	}
+	if strings.Contains(mockPort.GetWrittenData(), "AT+CMGF") {
+		t.Error("init re-run although the reset was refused")
+	}
+}
+
+func TestResetModemRestartsListener(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.clk = newFakeClock()
+	mockPort.AddResponse("AT+CFUN=1,1", "OK\r\n")
+	defer handler.Close()
+
+	handler.ListenForIncomingSMS(func(SMS) {})
+	before := handler.currentListener()
+	if err := handler.ResetModem(); err != nil {
+		t.Fatalf("ResetModem failed: %v", err)
+	}
+
+	select {
+	case <-before.done:
+	default:
+		t.Error("old listener still running after the reset")
+	}
+	if after := handler.currentListener(); after == nil || after == before {
+		t.Error("listener not restarted after the reset")
+	}
+}
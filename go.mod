@@ -2,8 +2,9 @@ module github.com/brooksmcmillin/sms-handler
 
 go 1.18
 
+require go.bug.st/serial v1.6.4
+
 require (
 	github.com/creack/goselect v0.1.2 // indirect
-	go.bug.st/serial v1.6.4 // indirect
 	golang.org/x/sys v0.19.0 // indirect
 )
@@ -0,0 +1,121 @@
+package smshandler
+
+// gsm7Escape is the septet that switches to the extension table
+const gsm7Escape = 0x1B
+
+// gsm7Basic is the GSM 03.38 default alphabet indexed by septet value. The
+// escape septet is a placeholder and never matched as a character.
+var gsm7Basic = []rune("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// gsm7Extension maps the characters reached through the escape septet to
+// their septet values
+var gsm7Extension = map[rune]byte{
+	'\f': 0x0A,
+	'^':  0x14,
+	'{':  0x28,
+	'}':  0x29,
+	'\\': 0x2F,
+	'[':  0x3C,
+	'~':  0x3D,
+	']':  0x3E,
+	'|':  0x40,
+	'€':  0x65,
+}
+
+var (
+	gsm7BasicCodes     = map[rune]byte{}
+	gsm7ExtensionRunes = map[byte]rune{}
+)
+
+func init() {
+	for i, r := range gsm7Basic {
+		if i != gsm7Escape {
+			gsm7BasicCodes[r] = byte(i)
+		}
+	}
+	for r, code := range gsm7Extension {
+		gsm7ExtensionRunes[code] = r
+	}
+}
+
+// isGSM7 reports whether every character of s can be sent in the GSM 7-bit
+// alphabet, including the extension table
+func isGSM7(s string) bool {
+	_, ok := encodeGSM7(s)
+	return ok
+}
+
+// encodeGSM7 converts s to septets, escaping extension characters. It
+// returns false if s contains a character the alphabet cannot represent.
+func encodeGSM7(s string) ([]byte, bool) {
+	septets := make([]byte, 0, len(s))
+	for _, r := range s {
+		if code, ok := gsm7BasicCodes[r]; ok {
+			septets = append(septets, code)
+			continue
+		}
+		if code, ok := gsm7Extension[r]; ok {
+			septets = append(septets, gsm7Escape, code)
+			continue
+		}
+		return nil, false
+	}
+	return septets, true
+}
+
+// decodeGSM7 converts septets back to text. Unknown escape sequences fall
+// back to the basic table as GSM 03.38 recommends.
+func decodeGSM7(septets []byte) string {
+	runes := make([]rune, 0, len(septets))
+	for i := 0; i < len(septets); i++ {
+		c := septets[i] & 0x7F
+		if c == gsm7Escape {
+			if i+1 >= len(septets) {
+				break
+			}
+			i++
+			if r, ok := gsm7ExtensionRunes[septets[i]&0x7F]; ok {
+				runes = append(runes, r)
+				continue
+			}
+			c = septets[i] & 0x7F
+		}
+		runes = append(runes, gsm7Basic[c])
+	}
+	return string(runes)
+}
+
+// packSeptets packs septets into octets, leaving fillBits zero bits in
+// front so the text can start on a septet boundary after a user data header
+func packSeptets(septets []byte, fillBits int) []byte {
+	total := fillBits + 7*len(septets)
+	out := make([]byte, (total+7)/8)
+	for i, septet := range septets {
+		pos := fillBits + 7*i
+		v := uint16(septet&0x7F) << (pos % 8)
+		out[pos/8] |= byte(v)
+		if pos/8+1 < len(out) {
+			out[pos/8+1] |= byte(v >> 8)
+		}
+	}
+	return out
+}
+
+// unpackSeptets extracts count septets from packed octets, skipping
+// fillBits leading bits
+func unpackSeptets(data []byte, count, fillBits int) []byte {
+	out := make([]byte, 0, count)
+	for i := 0; i < count; i++ {
+		pos := fillBits + 7*i
+		if pos/8 >= len(data) {
+			break
+		}
+		v := uint16(data[pos/8])
+		if pos/8+1 < len(data) {
+			v |= uint16(data[pos/8+1]) << 8
+		}
+		out = append(out, byte(v>>(pos%8))&0x7F)
+	}
+	return out
+}
@@ -0,0 +1,289 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// splitRespectingQuotes splits s on sep, ignoring separators inside double
+// quotes, so a field like "24/01/15,10:30:45+00" stays whole
+func splitRespectingQuotes(s string, sep rune) []string {
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+
+	for _, r := range s {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+
+		if r == sep && !inQuotes {
+			parts = append(parts, current.String())
+			current.Reset()
+		} else {
+			current.WriteRune(r)
+		}
+	}
+
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+
+	return parts
+}
+
+// unquote trims surrounding whitespace and double quotes from a field
+func unquote(field string) string {
+	return strings.Trim(strings.TrimSpace(field), "\"")
+}
+
+// maxMessageIndex is the highest storage index accepted from a +CMTI
+// notification; no modem storage comes near it, so anything larger is a
+// garbled line
+const maxMessageIndex = 9999
+
+// parseCMTIIndex returns the storage index of a +CMTI: <mem>,<index>
+// notification. The index may be padded or quoted; anything that is not a
+// whole number between 0 and maxMessageIndex is rejected rather than read
+// as some other slot.
+func parseCMTIIndex(line string) (int, error) {
+	parts := splitRespectingQuotes(strings.TrimPrefix(line, "+CMTI:"), ',')
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("no index in %q", line)
+	}
+	field := unquote(parts[1])
+	index, err := strconv.Atoi(field)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index %q in %q", field, line)
+	}
+	if index < 0 || index > maxMessageIndex {
+		return 0, fmt.Errorf("index %d out of range in %q", index, line)
+	}
+	return index, nil
+}
+
+// parseListIndex parses the <index> field of a +CMGL header. Besides the
+// usual bare number it accepts one padded with any whitespace or quoted,
+// and as a last resort a field holding exactly one run of digits, since an
+// entry is better read under its real index than skipped. A field with no
+// digits, or several numbers, is an error rather than index 0.
+func parseListIndex(field string) (int, error) {
+	if index, err := strconv.Atoi(unquote(field)); err == nil {
+		if index < 0 {
+			return 0, fmt.Errorf("invalid SMS index %q", field)
+		}
+		return index, nil
+	}
+
+	var runs []string
+	start := -1
+	for i, r := range field + " " {
+		switch {
+		case r >= '0' && r <= '9':
+			if start < 0 {
+				start = i
+			}
+		case start >= 0:
+			runs = append(runs, field[start:i])
+			start = -1
+		}
+	}
+	if len(runs) != 1 {
+		return 0, fmt.Errorf("invalid SMS index %q", field)
+	}
+	return strconv.Atoi(runs[0])
+}
+
+// looksLikeTimestamp reports whether a header field is a modem timestamp
+func looksLikeTimestamp(field string) bool {
+	_, err := parseSMSTimestamp(unquote(field))
+	return err == nil
+}
+
+// isBareNumber reports whether a header field is an unquoted integer
+func isBareNumber(field string) bool {
+	_, err := strconv.Atoi(strings.TrimSpace(field))
+	return err == nil
+}
+
+// isAlphanumericAddress reports whether an address is a name such as
+// "VERIFY" rather than a phone number
+func isAlphanumericAddress(addr string) bool {
+	for _, r := range addr {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAddressFields fills sender, sender name and date from the fields that
+// follow the status in a +CMGL or +CMGR header. Modems either omit the
+// <alpha> field entirely (address,date) or include it (address,alpha,date);
+// the layout is told apart by whether the field after the address is a
+// timestamp.
+func applyAddressFields(sms *SMS, fields []string) {
+	if len(fields) == 0 {
+		return
+	}
+	sms.Sender = unquote(fields[0])
+
+	rest := fields[1:]
+	if len(rest) > 0 && !looksLikeTimestamp(rest[0]) {
+		sms.SenderName = unquote(rest[0])
+		rest = rest[1:]
+	}
+	// A bare number there is the <toda> of a stored outgoing message shown
+	// with header details, which has no timestamp
+	if len(rest) > 0 && !isBareNumber(rest[0]) {
+		sms.Date = unquote(rest[0])
+		sms.Timestamp, _ = parseSMSTimestamp(sms.Date)
+	}
+
+	// An alphanumeric originator is its own display name
+	if sms.SenderName == "" && isAlphanumericAddress(sms.Sender) {
+		sms.SenderName = sms.Sender
+	}
+}
+
+// cmtDetails are the fields a text-mode +CMT header carries after the
+// timestamp when the modem shows header details (AT+CSDH=1):
+// <tooa>,<fo>,<pid>,<dcs>[,<sca>,<tosca>],<length>. Some modems leave out
+// the service centre pair.
+type cmtDetails struct {
+	// dcs is the TP-DCS octet, or -1 when the header has none
+	dcs int
+	// smsc is the service centre address, when reported
+	smsc string
+	// length is the body length in characters, or 0 when unknown
+	length int
+}
+
+// parseCMTHeader parses a text-mode +CMT header, along with the optional
+// details that follow the timestamp:
+// +CMT: <oa>,[<alpha>],<scts>[,<tooa>,<fo>,<pid>,<dcs>,<sca>,<tosca>,<length>]
+func parseCMTHeader(line string) (SMS, cmtDetails, error) {
+	var sms SMS
+	details := cmtDetails{dcs: -1}
+
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "+CMT:") {
+		return sms, details, errors.New("invalid CMT header")
+	}
+
+	fields := splitRespectingQuotes(strings.TrimPrefix(line, "+CMT:"), ',')
+	if len(fields) < 2 {
+		return sms, details, errors.New("insufficient fields in CMT header")
+	}
+
+	applyAddressFields(&sms, fields)
+
+	// The details start after the timestamp, which is the second or third
+	// field depending on whether <alpha> is present
+	rest := fields[1:]
+	if len(rest) > 0 && !looksLikeTimestamp(rest[0]) {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		details = parseCMTDetails(rest[1:])
+	}
+	applyDCS(&sms, details)
+	return sms, details, nil
+}
+
+// parseCMTDetails parses the +CMT fields after the timestamp
+func parseCMTDetails(fields []string) cmtDetails {
+	details := cmtDetails{dcs: -1}
+	if len(fields) != 5 && len(fields) != 7 {
+		return details
+	}
+
+	if dcs, err := strconv.Atoi(strings.TrimSpace(fields[3])); err == nil && dcs >= 0 && dcs <= 0xFF {
+		details.dcs = dcs
+	}
+	if len(fields) == 7 {
+		details.smsc = unquote(fields[4])
+	}
+
+	// <length> counts octets rather than characters for 8-bit and UCS2
+	// bodies, which text mode shows as hex, so it only helps for GSM text
+	length, err := strconv.Atoi(strings.TrimSpace(fields[len(fields)-1]))
+	if err == nil && length > 0 && (details.dcs < 0 || dcsEncoding(byte(details.dcs)) == EncodingGSM7) {
+		details.length = length
+	}
+	return details
+}
+
+// parseCMGRHeader parses a text-mode +CMGR header, along with the details
+// the modem adds with AT+CSDH=1. A received message's details follow the
+// timestamp as in +CMT; a stored outgoing one has no timestamp:
+// +CMGR: <stat>,<oa>,[<alpha>],<scts>[,<tooa>,<fo>,<pid>,<dcs>,<sca>,<tosca>,<length>]
+// +CMGR: <stat>,<da>,[<alpha>][,<toda>,<fo>,<pid>,<dcs>,[<vp>],<sca>,<tosca>,<length>]
+func parseCMGRHeader(line string) (SMS, cmtDetails, error) {
+	var sms SMS
+	details := cmtDetails{dcs: -1}
+
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "+CMGR:") {
+		return sms, details, errors.New("invalid SMS header")
+	}
+
+	fields := splitRespectingQuotes(strings.TrimPrefix(line, "+CMGR:"), ',')
+	if len(fields) < 2 {
+		return sms, details, errors.New("insufficient fields in SMS header")
+	}
+
+	sms.Status = parseMessageStatus(fields[0])
+	applyAddressFields(&sms, fields[1:])
+
+	// With details shown <alpha> is always present, possibly empty
+	if rest := fields[2:]; len(rest) > 1 {
+		switch {
+		case looksLikeTimestamp(rest[1]):
+			details = parseCMTDetails(rest[2:])
+		case len(rest) == 9:
+			// Drop <vp> to line the rest up with the received layout
+			details = parseCMTDetails(append(append([]string(nil), rest[1:5]...), rest[6:]...))
+		}
+	}
+	applyDCS(&sms, details)
+	return sms, details, nil
+}
+
+// applyDCS sets the class and encoding of a text-mode message from the
+// header details, when they include the data coding scheme
+func applyDCS(sms *SMS, details cmtDetails) {
+	if details.dcs < 0 {
+		return
+	}
+	sms.Class = dcsClass(byte(details.dcs))
+	sms.Encoding = dcsEncoding(byte(details.dcs))
+}
+
+// parseCMGLHeader parses a text-mode +CMGL header:
+// +CMGL: <index>,<stat>,<oa/da>,[<alpha>],[<scts>][,...]
+func parseCMGLHeader(line string) (SMS, error) {
+	var sms SMS
+
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "+CMGL:") {
+		return sms, errors.New("invalid SMS list header")
+	}
+
+	fields := splitRespectingQuotes(strings.TrimPrefix(line, "+CMGL:"), ',')
+	if len(fields) < 3 {
+		return sms, errors.New("insufficient fields in SMS list header")
+	}
+
+	index, err := parseListIndex(fields[0])
+	if err != nil {
+		return sms, err
+	}
+	sms.Index = index
+	sms.Status = parseMessageStatus(fields[1])
+	applyAddressFields(&sms, fields[2:])
+	return sms, nil
+}
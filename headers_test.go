@@ -0,0 +1,336 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHeaderSenderName(t *testing.T) {
+	tests := []struct {
+		name       string
+		parse      func(string) (SMS, error)
+		input      string
+		sender     string
+		senderName string
+		date       string
+	}{
+		{
+			name:   "CMGR without alpha field",
+			parse:  parseSMSHeader,
+			input:  `+CMGR: "REC READ","+15551234567","24/01/15,10:30:45+00"`,
+			sender: "+15551234567",
+			date:   "24/01/15,10:30:45+00",
+		},
+		{
+			name:       "CMGR with phonebook name",
+			parse:      parseSMSHeader,
+			input:      `+CMGR: "REC READ","+15551234567","John Doe","24/01/15,10:30:45+00"`,
+			sender:     "+15551234567",
+			senderName: "John Doe",
+			date:       "24/01/15,10:30:45+00",
+		},
+		{
+			name:       "CMGR alphanumeric sender",
+			parse:      parseSMSHeader,
+			input:      `+CMGR: "REC UNREAD","VERIFY","","24/01/15,10:30:45+00"`,
+			sender:     "VERIFY",
+			senderName: "VERIFY",
+			date:       "24/01/15,10:30:45+00",
+		},
+		{
+			name:       "CMGL alphanumeric sender without alpha field",
+			parse:      parseCMGLHeader,
+			input:      `+CMGL: 4,"REC UNREAD","VERIFY","24/01/15,10:30:45+00"`,
+			sender:     "VERIFY",
+			senderName: "VERIFY",
+			date:       "24/01/15,10:30:45+00",
+		},
+		{
+			name: "CMT with length hint",
+			parse: func(line string) (SMS, error) {
+				sms, _, err := parseCMTHeader(line)
+				return sms, err
+			},
+			input:      `+CMT: "+15551234567","Alice","24/01/15,10:30:45+00",145,4,0,0,"+15550000000",145,5`,
+			sender:     "+15551234567",
+			senderName: "Alice",
+			date:       "24/01/15,10:30:45+00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sms, err := tt.parse(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sms.Sender != tt.sender {
+				t.Errorf("Sender: got %q, want %q", sms.Sender, tt.sender)
+			}
+			if sms.SenderName != tt.senderName {
+				t.Errorf("SenderName: got %q, want %q", sms.SenderName, tt.senderName)
+			}
+			if sms.Date != tt.date {
+				t.Errorf("Date: got %q, want %q", sms.Date, tt.date)
+			}
+			if sms.Timestamp.IsZero() {
+				t.Error("Timestamp not parsed")
+			}
+		})
+	}
+}
+
+func TestParseCMTDetails(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   cmtDetails
+	}{
+		{
+			name:   "Basic header",
+			header: `+CMT: "+15551234567","","24/01/15,10:30:45+00"`,
+			want:   cmtDetails{dcs: -1},
+		},
+		{
+			name:   "With SMSC",
+			header: `+CMT: "+15551234567","","24/01/15,10:30:45+00",145,4,0,0,"+15550000000",145,5`,
+			want:   cmtDetails{dcs: 0, smsc: "+15550000000", length: 5},
+		},
+		{
+			name:   "Without SMSC",
+			header: `+CMT: "+15551234567","","24/01/15,10:30:45+00",145,4,0,0,5`,
+			want:   cmtDetails{dcs: 0, length: 5},
+		},
+		{
+			name:   "Without alpha",
+			header: `+CMT: "+15551234567","24/01/15,10:30:45+00",145,4,0,0,"+15550000000",145,12`,
+			want:   cmtDetails{dcs: 0, smsc: "+15550000000", length: 12},
+		},
+		{
+			name:   "UCS2 length is in octets",
+			header: `+CMT: "+15551234567","","24/01/15,10:30:45+00",145,4,0,8,"+15550000000",145,10`,
+			want:   cmtDetails{dcs: 8, smsc: "+15550000000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sms, details, err := parseCMTHeader(tt.header)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if details != tt.want {
+				t.Errorf("details: got %+v, want %+v", details, tt.want)
+			}
+			if sms.Sender != "+15551234567" || sms.Date != "24/01/15,10:30:45+00" {
+				t.Errorf("address fields: got %+v", sms)
+			}
+		})
+	}
+}
+
+// A comma inside a quoted field must not shift the fields that follow
+func TestParseSMSListQuotedComma(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	response := "+CMGL: 1,\"REC READ\",\"+15551234567\",\"Doe, John\",\"24/01/15,10:30:45+00\"\r\n" +
+		"Hi, it's John\r\nOK\r\n"
+
+	messages := handler.parseSMSList(response)
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+
+	sms := messages[0]
+	if sms.SenderName != "Doe, John" {
+		t.Errorf("SenderName: got %q", sms.SenderName)
+	}
+	if sms.Date != "24/01/15,10:30:45+00" || sms.Timestamp.IsZero() {
+		t.Errorf("Date: got %q (timestamp %v)", sms.Date, sms.Timestamp)
+	}
+	if sms.Message != "Hi, it's John" {
+		t.Errorf("Message: got %q", sms.Message)
+	}
+}
+
+func TestSplitRespectingQuotes(t *testing.T) {
+	got := splitRespectingQuotes(`"SM",5,"a,b"`, ',')
+	want := []string{`"SM"`, "5", `"a,b"`}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("field %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseCMTIIndex(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    int
+		wantErr bool
+	}{
+		{line: `+CMTI: "SM",3`, want: 3},
+		{line: `+CMTI: "SM",0`, want: 0},
+		{line: `+CMTI: "ME", 12 `, want: 12},
+		{line: `+CMTI: "SM","7"`, want: 7},
+		{line: `+CMTI: "SM"`, wantErr: true},
+		{line: `+CMTI: "SM",`, wantErr: true},
+		{line: `+CMTI: "SM",abc`, wantErr: true},
+		{line: `+CMTI: "SM",5x`, wantErr: true},
+		{line: `+CMTI: "SM",-1`, wantErr: true},
+		{line: `+CMTI: "SM",123456`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCMTIIndex(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCMTIIndex(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseCMTIIndex(%q) = %d, want %d", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMalformedCMTISkipped(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	logger := &recordingLogger{}
+	handler.cfg.logger = logger
+
+	for _, line := range []string{`+CMTI: "SM",abc`, `+CMTI: "SM"`} {
+		handler.handleCMTIMessage(line, func(sms SMS) {
+			t.Errorf("delivered %+v from %q", sms, line)
+		})
+	}
+
+	if written := mockPort.GetWrittenData(); strings.Contains(written, "AT+CMGR") {
+		t.Errorf("read a slot for a malformed notification: %q", written)
+	}
+	if lines := handler.UnhandledLines(); len(lines) != 2 {
+		t.Errorf("unhandled lines = %q", lines)
+	}
+	if len(logger.warns) != 2 {
+		t.Errorf("warnings = %q, want one per skipped line", logger.warns)
+	}
+}
+
+func TestParseHeadersWithDetails(t *testing.T) {
+	tests := []struct {
+		name     string
+		parse    func(string) (SMS, error)
+		input    string
+		sender   string
+		date     string
+		encoding Encoding
+		class    MessageClass
+	}{
+		{
+			name:     "CMGR received GSM",
+			parse:    parseSMSHeader,
+			input:    `+CMGR: "REC READ","+15551234567","","24/01/15,10:30:45+00",145,4,0,0,"+15550000000",145,5`,
+			sender:   "+15551234567",
+			date:     "24/01/15,10:30:45+00",
+			encoding: EncodingGSM7,
+		},
+		{
+			name:     "CMGR received UCS2 flash",
+			parse:    parseSMSHeader,
+			input:    `+CMGR: "REC UNREAD","+15551234567",,"24/01/15,10:30:45+00",145,4,0,24,"+15550000000",145,4`,
+			sender:   "+15551234567",
+			date:     "24/01/15,10:30:45+00",
+			encoding: EncodingUCS2,
+			class:    ClassFlash,
+		},
+		{
+			name:     "CMGR stored outgoing with validity",
+			parse:    parseSMSHeader,
+			input:    `+CMGR: "STO UNSENT","+15551234567","",129,17,0,8,167,"+15550000000",145,4`,
+			sender:   "+15551234567",
+			encoding: EncodingUCS2,
+		},
+		{
+			name:   "CMGL received",
+			parse:  parseCMGLHeader,
+			input:  `+CMGL: 1,"REC READ","+15551234567","","24/01/15,10:30:45+00",145,5`,
+			sender: "+15551234567",
+			date:   "24/01/15,10:30:45+00",
+		},
+		{
+			name:   "CMGL stored outgoing without timestamp",
+			parse:  parseCMGLHeader,
+			input:  `+CMGL: 2,"STO SENT","+15551234567","",129,5`,
+			sender: "+15551234567",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sms, err := tt.parse(tt.input)
+			if err != nil {
+				t.Fatalf("parse failed: %v", err)
+			}
+			if sms.Sender != tt.sender || sms.Date != tt.date {
+				t.Errorf("sender %q, date %q; want %q, %q", sms.Sender, sms.Date, tt.sender, tt.date)
+			}
+			if sms.Encoding != tt.encoding || sms.Class != tt.class {
+				t.Errorf("encoding %q, class %q; want %q, %q", sms.Encoding, sms.Class, tt.encoding, tt.class)
+			}
+		})
+	}
+}
+
+func TestParseListIndex(t *testing.T) {
+	tests := []struct {
+		field   string
+		want    int
+		wantErr bool
+	}{
+		{field: "3", want: 3},
+		{field: " 12", want: 12},
+		{field: "\t7 ", want: 7},
+		{field: `"9"`, want: 9},
+		{field: "0", want: 0},
+		{field: "#4", want: 4},
+		{field: "", wantErr: true},
+		{field: "abc", wantErr: true},
+		{field: "-1", wantErr: true},
+		{field: "1 2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseListIndex(tt.field)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseListIndex(%q) error = %v, wantErr %v", tt.field, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseListIndex(%q) = %d, want %d", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestParseSMSListIndexSpacing(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	response := "+CMGL:1,\"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\n" +
+		"First\n" +
+		"+CMGL:   2 ,\"REC READ\",\"+1234567890\",,\"24/01/15,10:31:00+00\"\n" +
+		"Second\n" +
+		"+CMGL: \"3\",\"REC UNREAD\",\"+1234567890\",,\"24/01/15,10:32:00+00\"\n" +
+		"Third\n" +
+		"+CMGL: ?,\"REC UNREAD\",\"+1234567890\",,\"24/01/15,10:33:00+00\"\n" +
+		"Unreadable\n" +
+		"OK"
+
+	messages := handler.parseSMSList(response)
+	var indexes []int
+	for _, sms := range messages {
+		indexes = append(indexes, sms.Index)
+	}
+	if len(indexes) != 3 || indexes[0] != 1 || indexes[1] != 2 || indexes[2] != 3 {
+		t.Errorf("indexes = %v, want [1 2 3] with the unreadable entry skipped", indexes)
+	}
+}
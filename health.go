@@ -0,0 +1,145 @@
+package smshandler
+
+import "time"
+
+// HandlerStatus is a snapshot of a handler's health for a status page or
+// health check. Status builds it from cached values without talking to the
+// modem.
+type HandlerStatus struct {
+	// Connected is false once the handler has been closed
+	Connected bool
+	Listening bool
+
+	// LastSuccess is when a command last got a reply that was not an
+	// error; LastError is the most recent failed command, LastErrorAt when
+	// it failed
+	LastSuccess time.Time
+	LastError   error
+	LastErrorAt time.Time
+
+	// Signal and Registration are the last values read, by SignalQuality
+	// and RegistrationStatus or the WithStatusRefresh refresher. SignalAt
+	// and RegistrationAt are zero when they were never read.
+	Signal         SignalQuality
+	SignalAt       time.Time
+	Registration   RegistrationStatus
+	RegistrationAt time.Time
+}
+
+// Healthy reports whether the handler is open and its last command
+// succeeded
+func (h HandlerStatus) Healthy() bool {
+	return h.Connected && !h.LastSuccess.Before(h.LastErrorAt)
+}
+
+// healthState is what Status reports, updated as commands run
+type healthState struct {
+	lastSuccess    time.Time
+	lastErr        error
+	lastErrAt      time.Time
+	signal         SignalQuality
+	signalAt       time.Time
+	registration   RegistrationStatus
+	registrationAt time.Time
+	refreshing     bool
+}
+
+// Status returns the handler's current health. It never blocks on the
+// modem, so it is cheap enough to call on every health check request.
+func (s *SMSHandler) Status() HandlerStatus {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	h := s.health
+	return HandlerStatus{
+		Connected:      !s.isClosed(),
+		Listening:      s.isListening(),
+		LastSuccess:    h.lastSuccess,
+		LastError:      h.lastErr,
+		LastErrorAt:    h.lastErrAt,
+		Signal:         h.signal,
+		SignalAt:       h.signalAt,
+		Registration:   h.registration,
+		RegistrationAt: h.registrationAt,
+	}
+}
+
+// recordCommand notes the outcome of an AT command for Status. An ERROR
+// reply counts as a failure.
+func (s *SMSHandler) recordCommand(response string, err error) {
+	if err == nil {
+		if modemErr := parseModemError(response); modemErr != nil {
+			err = modemErr
+		}
+	}
+
+	now := s.clock().Now()
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	if err != nil {
+		s.health.lastErr = err
+		s.health.lastErrAt = now
+		return
+	}
+	s.health.lastSuccess = now
+}
+
+func (s *SMSHandler) recordSignal(q SignalQuality) {
+	now := s.clock().Now()
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.health.signal = q
+	s.health.signalAt = now
+}
+
+func (s *SMSHandler) recordRegistration(r RegistrationStatus) {
+	now := s.clock().Now()
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.health.registration = r
+	s.health.registrationAt = now
+}
+
+// startStatusRefresh starts the WithStatusRefresh goroutine unless it is
+// disabled or already running. It runs on its own goroutine because the
+// listener cannot send commands itself.
+func (s *SMSHandler) startStatusRefresh() {
+	interval := s.cfg.statusRefresh
+	if interval <= 0 {
+		return
+	}
+
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	if s.health.refreshing {
+		return
+	}
+	s.health.refreshing = true
+	go s.refreshStatus(interval)
+}
+
+// refreshStatus re-reads the signal and registration every interval until
+// the listener stops or the handler is closed
+func (s *SMSHandler) refreshStatus(interval time.Duration) {
+	defer func() {
+		s.healthMu.Lock()
+		s.health.refreshing = false
+		s.healthMu.Unlock()
+	}()
+
+	closed := s.closeSignal()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-s.clock().After(interval):
+		}
+		if !s.isListening() {
+			return
+		}
+
+		// Failures are already recorded as the last error
+		_, _ = s.SignalQuality()
+		_, _ = s.RegistrationStatus()
+	}
+}
@@ -0,0 +1,82 @@
+package smshandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	clk := newFakeClock()
+	handler.clk = clk
+	mockPort.AddResponse("AT+CSQ", "+CSQ: 18,99\r\nOK\r\n")
+	mockPort.AddResponse("AT+CREG?", "+CREG: 0,5\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMGD=1", "+CMS ERROR: 321\r\n")
+
+	if status := handler.Status(); !status.Connected || !status.SignalAt.IsZero() || !status.Healthy() {
+		t.Fatalf("initial status = %+v", status)
+	}
+
+	if _, err := handler.SignalQuality(); err != nil {
+		t.Fatalf("SignalQuality failed: %v", err)
+	}
+	if _, err := handler.RegistrationStatus(); err != nil {
+		t.Fatalf("RegistrationStatus failed: %v", err)
+	}
+	status := handler.Status()
+	if status.Signal.RSSI != 18 || status.Registration != RegistrationRoaming {
+		t.Errorf("cached signal %+v, registration %v", status.Signal, status.Registration)
+	}
+	if !status.SignalAt.Equal(clk.Now()) || !status.LastSuccess.Equal(clk.Now()) {
+		t.Errorf("SignalAt = %v, LastSuccess = %v", status.SignalAt, status.LastSuccess)
+	}
+
+	clk.Advance(time.Minute)
+	_ = handler.DeleteSMS(1)
+	status = handler.Status()
+	if status.LastError == nil || !status.LastErrorAt.Equal(clk.Now()) || status.Healthy() {
+		t.Errorf("error not recorded: %+v", status)
+	}
+
+	handler.Close()
+	if handler.Status().Connected {
+		t.Error("closed handler reported as connected")
+	}
+}
+
+func TestStatusRefresh(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	clk := newFakeClock()
+	handler.clk = clk
+	WithStatusRefresh(time.Minute)(&handler.cfg)
+	mockPort.AddResponse("AT+CSQ", "+CSQ: 25,0\r\nOK\r\n")
+	mockPort.AddResponse("AT+CREG?", "+CREG: 0,1\r\nOK\r\n")
+
+	handler.ListenForIncomingSMS(func(SMS) {})
+	defer handler.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for clk.timers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("refresher never waited")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !handler.Status().SignalAt.IsZero() {
+		t.Fatal("refreshed before the interval")
+	}
+
+	clk.Advance(time.Minute)
+	for handler.Status().RegistrationAt.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("status never refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	status := handler.Status()
+	if !status.Listening || status.Signal.RSSI != 25 || status.Registration != RegistrationHome {
+		t.Errorf("refreshed status = %+v", status)
+	}
+}
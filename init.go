@@ -0,0 +1,73 @@
+package smshandler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InitCommand is an extra AT command run after the standard modem init, for
+// vendor-specific setup such as AT+QURCCFG
+type InitCommand struct {
+	Command string
+	// WarnOnly logs a failure instead of failing handler construction
+	WarnOnly bool
+}
+
+// runInitCommands runs the user-supplied init commands in order
+func (s *SMSHandler) runInitCommands() error {
+	for _, ic := range s.cfg.initCommands {
+		response, err := s.sendATCommandExpectOK(ic.Command)
+		s.logger().Debugf("init command %s: %q", ic.Command, response)
+		if err == nil {
+			continue
+		}
+
+		if ic.WarnOnly {
+			s.logger().Printf("Init command %s failed, continuing: %v", ic.Command, err)
+			continue
+		}
+		return fmt.Errorf("init command %s failed: %v", ic.Command, err)
+	}
+	return nil
+}
+
+// verifyMessageFormat confirms with AT+CMGF? that the modem accepted the
+// requested message format, since some modems acknowledge AT+CMGF=1 and
+// silently stay in PDU mode. A modem that cannot answer the query is
+// trusted, with a debug message.
+func (s *SMSHandler) verifyMessageFormat() error {
+	want := 1
+	if s.cfg.mode == ModePDU {
+		want = 0
+	}
+
+	response, err := s.sendATCommandExpectOK("AT+CMGF?")
+	fields, ok := resultFields(response, "+CMGF:")
+	if err != nil || !ok || len(fields) == 0 {
+		s.logger().Debugf("could not verify SMS message format: %q", response)
+		return nil
+	}
+
+	got, err := strconv.Atoi(fields[0])
+	if err != nil || got != want {
+		return fmt.Errorf("modem reports message format %q after AT+CMGF=%d", fields[0], want)
+	}
+	return nil
+}
+
+// verifyCharset confirms with AT+CSCS? that the modem switched to the
+// requested character set
+func (s *SMSHandler) verifyCharset(want string) error {
+	response, err := s.sendATCommandExpectOK("AT+CSCS?")
+	fields, ok := resultFields(response, "+CSCS:")
+	if err != nil || !ok || len(fields) == 0 {
+		s.logger().Debugf("could not verify character set: %q", response)
+		return nil
+	}
+
+	if !strings.EqualFold(fields[0], want) {
+		return fmt.Errorf("modem reports character set %q after AT+CSCS=%q", fields[0], want)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package smshandler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.bug.st/serial"
+)
+
+// recordingLogger captures log output for assertions
+type recordingLogger struct {
+	mu     sync.Mutex
+	warns  []string
+	debugs []string
+}
+
+func (r *recordingLogger) Printf(format string, v ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warns = append(r.warns, fmt.Sprintf(format, v...))
+}
+
+func (r *recordingLogger) Debugf(format string, v ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.debugs = append(r.debugs, fmt.Sprintf(format, v...))
+}
+
+func TestInitCommands(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	logger := &recordingLogger{}
+	handler.cfg.logger = logger
+	handler.cfg.initCommands = []InitCommand{
+		{Command: `AT+QURCCFG="urcport","usbat"`},
+		{Command: "AT+VENDOR=1", WarnOnly: true},
+	}
+	mockPort.AddResponse(`AT+QURCCFG="urcport","usbat"`, "OK\r\n")
+	mockPort.AddResponse("AT+VENDOR=1", "ERROR\r\n")
+	mockPort.AddResponse("AT+CMGF?", "+CMGF: 1\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSCS?", "+CSCS: \"GSM\"\r\nOK\r\n")
+	mockPort.AddResponse("AT+CPMS?", "+CPMS: \"SM\",0,30,\"SM\",0,30,\"SM\",0,30\r\nOK\r\n")
+
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+
+	written := mockPort.GetWrittenData()
+	cnmi := strings.Index(written, "AT+CNMI")
+	extra := strings.Index(written, "AT+QURCCFG")
+	if extra < 0 || extra < cnmi {
+		t.Error("extra init command not run after the standard sequence")
+	}
+	if len(logger.debugs) != 2 {
+		t.Errorf("expected 2 debug lines, got %v", logger.debugs)
+	}
+	if len(logger.warns) != 1 || !strings.Contains(logger.warns[0], "AT+VENDOR=1") {
+		t.Errorf("expected a warning for the failed command, got %v", logger.warns)
+	}
+
+	// Without WarnOnly the same failure is fatal
+	handler.cfg.initCommands = []InitCommand{{Command: "AT+VENDOR=1"}}
+	if err := handler.initModem(); err == nil {
+		t.Error("expected init to fail")
+	}
+}
+
+func TestInitModemVerifiesMessageFormat(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CMGF?", "+CMGF: 0\r\nOK\r\n")
+
+	err := handler.initModem()
+	if err == nil || !strings.Contains(err.Error(), "message format") {
+		t.Errorf("expected a message format error, got %v", err)
+	}
+
+	mockPort = NewMockSerialPort()
+	handler = newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CMGF?", "+CMGF: 1\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSCS?", "+CSCS: \"IRA\"\r\nOK\r\n")
+
+	err = handler.initModem()
+	if err == nil || !strings.Contains(err.Error(), "character set") {
+		t.Errorf("expected a character set error, got %v", err)
+	}
+
+	mockPort = NewMockSerialPort()
+	handler = newMockHandler(mockPort)
+	handler.cfg.mode = ModePDU
+	mockPort.AddResponse("AT+CMGF?", "+CMGF: 0\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSCS?", "+CSCS: \"GSM\"\r\nOK\r\n")
+
+	if err := handler.initModem(); err != nil {
+		t.Errorf("initModem failed: %v", err)
+	}
+}
+
+func TestInitModemSetsErrorReporting(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CMGF?", "+CMGF: 1\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSCS?", "+CSCS: \"GSM\"\r\nOK\r\n")
+
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+	written := mockPort.GetWrittenData()
+	if cmee, cmgf := strings.Index(written, "AT+CMEE=1"), strings.Index(written, "AT+CMGF"); cmee < 0 || cmee > cmgf {
+		t.Errorf("AT+CMEE=1 not sent before the SMS setup: %q", written)
+	}
+
+	mockPort = NewMockSerialPort()
+	handler = newMockHandler(mockPort)
+	handler.cfg.errorReporting = ErrorReportingVerbose
+	mockPort.AddResponse("AT+CMGF?", "+CMGF: 1\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSCS?", "+CSCS: \"GSM\"\r\nOK\r\n")
+
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+	if !strings.Contains(mockPort.GetWrittenData(), "AT+CMEE=2") {
+		t.Error("verbose error reporting not requested")
+	}
+}
+
+func TestWithSkipInit(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	openSerialPort = func(name string, mode *serial.Mode) (serial.Port, error) {
+		return mockPort, nil
+	}
+	defer func() { openSerialPort = serial.Open }()
+
+	handler, err := NewSMSHandler("/dev/ttyUSB0", 115200, WithSkipInit())
+	if err != nil {
+		t.Fatalf("NewSMSHandler failed: %v", err)
+	}
+	if written := mockPort.GetWrittenData(); written != "" {
+		t.Errorf("init commands sent despite WithSkipInit: %q", written)
+	}
+
+	mockPort.AddResponse("AT+CSQ", "+CSQ: 20,99\r\nOK\r\n")
+	if response, err := handler.GetSignalStrength(); err != nil || !strings.Contains(response, "+CSQ") {
+		t.Errorf("handler not usable after skipping init: %q, %v", response, err)
+	}
+}
+
+func TestInitModemShowTextHeaders(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		handler.cfg.showTextHeaders = enabled
+		mockPort.AddResponse("AT+CMGF?", "+CMGF: 1\r\nOK\r\n")
+		mockPort.AddResponse("AT+CSCS?", "+CSCS: \"GSM\"\r\nOK\r\n")
+		mockPort.AddResponse("AT+CSDH=1", "OK\r\n")
+
+		if err := handler.initModem(); err != nil {
+			t.Fatalf("initModem failed: %v", err)
+		}
+		if sent := strings.Contains(mockPort.GetWrittenData(), "AT+CSDH=1"); sent != enabled {
+			t.Errorf("enabled=%v: AT+CSDH=1 sent = %v", enabled, sent)
+		}
+	}
+}
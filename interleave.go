@@ -0,0 +1,182 @@
+package smshandler
+
+import (
+	"bytes"
+	"strings"
+)
+
+// maxInterleavedURCs bounds the unsolicited results queued during sends;
+// past it the oldest are dropped
+const maxInterleavedURCs = 32
+
+// interleavedURC is an unsolicited result the modem sent in the middle of a
+// send response. A +CMT header keeps the body line that followed it.
+type interleavedURC struct {
+	line string
+	body string
+}
+
+// sendResponse scans a send response line by line as it arrives, setting
+// aside unsolicited results such as +CMTI or +CREG so they neither end the
+// send early nor get lost. With an empty resultPrefix no line completes it.
+type sendResponse struct {
+	resultPrefix string
+	buf          []byte
+	scanned      int // start of the first line not yet scanned
+	urcs         []interleavedURC
+	awaitingBody bool // the last URC was a +CMT header
+
+	done     bool
+	modemErr *ModemError
+	busy     bool
+	// ok is set by a bare OK, which some modems send without (or before)
+	// the result line
+	ok bool
+}
+
+// feed appends data to the response and scans every line it completes
+func (r *sendResponse) feed(data []byte) {
+	r.buf = append(r.buf, data...)
+	for !r.done && r.modemErr == nil && !r.busy {
+		end := bytes.IndexByte(r.buf[r.scanned:], '\n')
+		if end < 0 {
+			return
+		}
+		line := strings.TrimSpace(string(r.buf[r.scanned : r.scanned+end]))
+		r.scanned += end + 1
+		r.scanLine(line)
+	}
+}
+
+func (r *sendResponse) scanLine(line string) {
+	if line == "" {
+		return
+	}
+	if r.awaitingBody {
+		r.urcs[len(r.urcs)-1].body = line
+		r.awaitingBody = false
+		return
+	}
+
+	switch {
+	case r.resultPrefix != "" && strings.HasPrefix(line, r.resultPrefix):
+		r.done = true
+	case parseModemError(line) != nil:
+		r.modemErr = parseModemError(line)
+	case hasBusyResult(line):
+		r.busy = true
+	case line == "OK":
+		r.ok = true
+	case isURC(line):
+		r.urcs = append(r.urcs, interleavedURC{line: line})
+		r.awaitingBody = strings.HasPrefix(line, "+CMT:")
+	}
+	// Anything else is the command or body echo, or left over from an
+	// earlier command
+}
+
+// urcPrefixes start the unsolicited results a send response can contain
+var urcPrefixes = []string{
+	"+CMTI:", "+CMT:", "+CDS:", "+CDSI:", "+CBM:",
+	"+CREG:", "+CGREG:", "+CEREG:",
+	"+CLIP:", "+CRING:", "+CUSD:",
+}
+
+// isURC reports whether line is an unsolicited result rather than part of
+// a command's own response
+func isURC(line string) bool {
+	for _, prefix := range urcPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns everything read so far
+func (r *sendResponse) String() string {
+	return string(r.buf)
+}
+
+// setAsideBuffered empties the reader before a send, queueing the
+// unsolicited results among the buffered lines for the listener. The caller
+// holds readerMu.
+func (s *SMSHandler) setAsideBuffered() {
+	n := s.reader.Buffered()
+	if n == 0 {
+		return
+	}
+	data, _ := s.reader.Peek(n)
+	stale := &sendResponse{}
+	for _, line := range strings.Split(string(data), "\n") {
+		stale.scanLine(strings.TrimSpace(line))
+	}
+	_, _ = s.reader.Discard(n)
+	s.queueInterleavedURCs(stale.urcs)
+}
+
+// queueInterleavedURCs keeps URCs read during a send for the listener
+func (s *SMSHandler) queueInterleavedURCs(urcs []interleavedURC) {
+	if len(urcs) == 0 {
+		return
+	}
+
+	s.interleavedMu.Lock()
+	defer s.interleavedMu.Unlock()
+	s.interleaved = append(s.interleaved, urcs...)
+	if over := len(s.interleaved) - maxInterleavedURCs; over > 0 {
+		s.logger().Printf("Dropping %d unsolicited results queued during sends", over)
+		s.interleaved = append([]interleavedURC(nil), s.interleaved[over:]...)
+	}
+}
+
+// takeInterleavedURCs removes and returns the queued URCs
+func (s *SMSHandler) takeInterleavedURCs() []interleavedURC {
+	s.interleavedMu.Lock()
+	defer s.interleavedMu.Unlock()
+	urcs := s.interleaved
+	s.interleaved = nil
+	return urcs
+}
+
+// replayURC handles a queued URC the way the listener handles one read from
+// the port, passing a +CMTI to readStored
+func (s *SMSHandler) replayURC(urc interleavedURC, callback func(SMS), readStored func(line string)) {
+	switch {
+	case strings.HasPrefix(urc.line, "+CMTI:"):
+		readStored(urc.line)
+	case strings.HasPrefix(urc.line, "+CMT:"):
+		s.replayCMT(urc, callback)
+	case s.isATResponse(urc.line):
+	default:
+		s.recordUnhandled(urc.line)
+		s.dispatchURC(urc.line)
+	}
+}
+
+// replayCMT delivers a directly delivered message that arrived during a send
+func (s *SMSHandler) replayCMT(urc interleavedURC, callback func(SMS)) {
+	if urc.body == "" {
+		s.recordUnhandled(urc.line)
+		return
+	}
+
+	if s.cfg.mode == ModePDU {
+		sms, info, err := decodePDU(urc.body)
+		if err != nil {
+			s.logger().Printf("Error decoding incoming PDU: %v", err)
+			s.recordUnhandled(urc.body)
+			return
+		}
+		s.assemble(sms, info, callback)
+		return
+	}
+
+	sms, details, err := parseCMTHeader(urc.line)
+	if err != nil {
+		s.recordUnhandled(urc.line)
+		return
+	}
+	sms.Message = s.decodeTextBody(urc.body, details.dcs)
+	s.deliver(sms, callback)
+}
@@ -0,0 +1,238 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendSMSWithInterleavedCMTI(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Hello\x1A", "\r\n+CMTI: \"SM\",5\r\n+CMGS: 3\r\nOK\r\n")
+
+	ref, err := handler.SendSMSRef("+1234567890", "Hello")
+	if err != nil {
+		t.Fatalf("SendSMSRef failed: %v", err)
+	}
+	if ref != 3 {
+		t.Errorf("reference = %d, want 3", ref)
+	}
+
+	urcs := handler.takeInterleavedURCs()
+	if len(urcs) != 1 || urcs[0].line != `+CMTI: "SM",5` {
+		t.Errorf("queued URCs = %+v, want the +CMTI", urcs)
+	}
+}
+
+func TestSendResponseSplitAcrossReads(t *testing.T) {
+	r := &sendResponse{resultPrefix: "+CMGS:"}
+	for _, chunk := range []string{"\r\n+CREG: 1\r", "\n+CMGS", ": 7\r\nOK\r\n"} {
+		if r.done {
+			t.Fatalf("done before %q", chunk)
+		}
+		r.feed([]byte(chunk))
+	}
+
+	if !r.done {
+		t.Fatal("result line not recognized")
+	}
+	if len(r.urcs) != 1 || r.urcs[0].line != "+CREG: 1" {
+		t.Errorf("urcs = %+v", r.urcs)
+	}
+}
+
+func TestSendResponseErrorAfterURC(t *testing.T) {
+	r := &sendResponse{resultPrefix: "+CMGS:"}
+	r.feed([]byte("\r\n+CMTI: \"SM\",2\r\n+CMS ERROR: 500\r\n"))
+
+	if r.done || r.modemErr == nil || r.modemErr.Code != 500 {
+		t.Errorf("done = %v, modemErr = %v", r.done, r.modemErr)
+	}
+}
+
+// Only known unsolicited results are set aside; a stale result from an
+// earlier command is not one
+func TestSendResponseURCPrefixes(t *testing.T) {
+	r := &sendResponse{}
+	r.feed([]byte("\r\n+CSQ: 20,99\r\n+CREG: 1\r\n+CPMS: 1,30,1,30\r\n+CDSI: \"SR\",2\r\n"))
+
+	var lines []string
+	for _, urc := range r.urcs {
+		lines = append(lines, urc.line)
+	}
+	if strings.Join(lines, "|") != `+CREG: 1|+CDSI: "SR",2` {
+		t.Errorf("urcs = %q", lines)
+	}
+}
+
+func TestReplayInterleavedCMT(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	handler.queueInterleavedURCs([]interleavedURC{{
+		line: `+CMT: "+11234567890","","25/07/21,21:07:17-28"`,
+		body: "Hi there",
+	}})
+
+	var got []SMS
+	for _, urc := range handler.takeInterleavedURCs() {
+		handler.replayURC(urc, func(sms SMS) { got = append(got, sms) }, nil)
+	}
+
+	if len(got) != 1 || got[0].Sender != "+11234567890" || got[0].Message != "Hi there" {
+		t.Errorf("delivered = %+v", got)
+	}
+	if urcs := handler.takeInterleavedURCs(); len(urcs) != 0 {
+		t.Errorf("queue not emptied: %+v", urcs)
+	}
+}
+
+func TestSendSMSWithPreBufferedData(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	// The listener's last read left a notification and a stale result in
+	// the reader's buffer
+	mockPort.SimulateIncoming("\r\n+CMTI: \"SM\",4\r\nERROR\r\n")
+	if _, err := handler.reader.Peek(1); err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	if handler.reader.Buffered() == 0 {
+		t.Fatal("nothing buffered")
+	}
+
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Hello\x1A", "\r\n+CMGS: 8\r\nOK\r\n")
+
+	ref, err := handler.SendSMSRef("+1234567890", "Hello")
+	if err != nil {
+		t.Fatalf("SendSMSRef failed: %v", err)
+	}
+	if ref != 8 {
+		t.Errorf("reference = %d, want 8", ref)
+	}
+
+	urcs := handler.takeInterleavedURCs()
+	if len(urcs) != 1 || urcs[0].line != `+CMTI: "SM",4` {
+		t.Errorf("queued URCs = %+v, want the buffered +CMTI", urcs)
+	}
+}
+
+func TestCommandWithInterleavedCMTI(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CSQ", "AT+CSQ\r\n+CMTI: \"SM\",7\r\n+CSQ: 20,99\r\nOK\r\n")
+
+	resp, err := handler.sendATCommand("AT+CSQ")
+	if err != nil {
+		t.Fatalf("sendATCommand failed: %v", err)
+	}
+	if strings.Contains(resp, "+CMTI") || !strings.Contains(resp, "+CSQ: 20,99") {
+		t.Errorf("response = %q", resp)
+	}
+
+	urcs := handler.takeInterleavedURCs()
+	if len(urcs) != 1 || urcs[0].line != `+CMTI: "SM",7` {
+		t.Errorf("queued URCs = %+v, want the +CMTI", urcs)
+	}
+}
+
+func TestCommandWithInterleavedCMT(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	header := `+CMT: "+11234567890","","25/07/21,21:07:17-28"`
+	mockPort.AddResponse("AT+CSQ", "AT+CSQ\r\n"+header+"\r\nHi there\r\n+CSQ: 20,99\r\nOK\r\n")
+
+	resp, err := handler.sendATCommand("AT+CSQ")
+	if err != nil {
+		t.Fatalf("sendATCommand failed: %v", err)
+	}
+	if strings.Contains(resp, "Hi there") {
+		t.Errorf("message body leaked into response %q", resp)
+	}
+
+	var got []SMS
+	for _, urc := range handler.takeInterleavedURCs() {
+		handler.replayURC(urc, func(sms SMS) { got = append(got, sms) }, nil)
+	}
+	if len(got) != 1 || got[0].Sender != "+11234567890" || got[0].Message != "Hi there" {
+		t.Errorf("delivered = %+v", got)
+	}
+}
+
+// A +CMTI seen by the listener, live or queued during a command, is read
+// without blocking the listener or later commands
+func TestListenerReadsStoredMessages(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	defer handler.Close()
+	mockPort.AddResponse("AT+CMGR=3", "+CMGR: \"REC UNREAD\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nLive\r\n\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMGR=4", "+CMGR: \"REC UNREAD\",\"+1234567890\",,\"24/01/15,10:31:00+00\"\r\nQueued\r\n\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSQ", "+CSQ: 20,99\r\nOK\r\n")
+
+	handler.queueInterleavedURCs([]interleavedURC{{line: `+CMTI: "SM",4`}})
+	received := make(chan SMS, 2)
+	handler.ListenForIncomingSMS(func(sms SMS) { received <- sms })
+	mockPort.SimulateIncoming("+CMTI: \"SM\",3\r\n")
+
+	got := map[string]bool{}
+	for len(got) < 2 {
+		select {
+		case sms := <-received:
+			got[sms.Message] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out; delivered %v", got)
+		}
+	}
+	if !got["Live"] || !got["Queued"] {
+		t.Errorf("delivered %v, want Live and Queued", got)
+	}
+
+	if _, err := handler.GetSignalStrength(); err != nil {
+		t.Errorf("command after the reads failed: %v", err)
+	}
+}
+
+func TestCommandWithPreBufferedData(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	mockPort.SimulateIncoming("\r\n+CMTI: \"SM\",4\r\nOK\r\n")
+	if _, err := handler.reader.Peek(1); err != nil {
+		t.Fatalf("Peek failed: %v", err)
+	}
+	mockPort.AddResponse("AT+CSQ", "+CSQ: 20,99\r\nOK\r\n")
+
+	if _, err := handler.GetSignalStrength(); err != nil {
+		t.Fatalf("GetSignalStrength failed: %v", err)
+	}
+	urcs := handler.takeInterleavedURCs()
+	if len(urcs) != 1 || urcs[0].line != `+CMTI: "SM",4` {
+		t.Errorf("queued URCs = %+v, want the +CMTI", urcs)
+	}
+}
+
+// A +CMTI in the middle of a command's response reaches the callback once
+// the listener replays it
+func TestListenerReadsCMTIFromCommand(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	defer handler.Close()
+	mockPort.AddResponse("AT+CSQ", "+CMTI: \"SM\",5\r\n+CSQ: 20,99\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMGR=5", "+CMGR: \"REC UNREAD\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nDuring\r\n\r\nOK\r\n")
+
+	received := make(chan SMS, 1)
+	handler.ListenForIncomingSMS(func(sms SMS) { received <- sms })
+	if _, err := handler.GetSignalStrength(); err != nil {
+		t.Fatalf("GetSignalStrength failed: %v", err)
+	}
+
+	select {
+	case sms := <-received:
+		if sms.Message != "During" {
+			t.Errorf("got %+v", sms)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message announced during the command was not delivered")
+	}
+}
@@ -0,0 +1,46 @@
+package smshandler
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// smsJSON is the wire format produced by SMS.MarshalJSON
+type smsJSON struct {
+	Index            int    `json:"index"`
+	Status           string `json:"status,omitempty"`
+	Sender           string `json:"sender,omitempty"`
+	SenderNormalized string `json:"sender_normalized,omitempty"`
+	SenderName       string `json:"sender_name,omitempty"`
+	Date             string `json:"date,omitempty"`
+	Timestamp        string `json:"timestamp,omitempty"`
+	Message          string `json:"message,omitempty"`
+	Encoding         string `json:"encoding,omitempty"`
+	PartIndexes      []int  `json:"part_indexes,omitempty"`
+	Data             []byte `json:"data,omitempty"`
+	Port             uint16 `json:"port,omitempty"`
+	Class            string `json:"class,omitempty"`
+}
+
+// MarshalJSON encodes the message with the timestamp in RFC3339 format and
+// empty fields omitted. The raw modem date is kept alongside for reference.
+func (m SMS) MarshalJSON() ([]byte, error) {
+	out := smsJSON{
+		Index:            m.Index,
+		Status:           m.Status.String(),
+		Sender:           m.Sender,
+		SenderNormalized: m.SenderNormalized,
+		SenderName:       m.SenderName,
+		Date:             m.Date,
+		Message:          m.Message,
+		Encoding:         string(m.Encoding),
+		PartIndexes:      m.PartIndexes,
+		Data:             m.Data,
+		Port:             m.Port,
+		Class:            string(m.Class),
+	}
+	if !m.Timestamp.IsZero() {
+		out.Timestamp = m.Timestamp.Format(time.RFC3339)
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,87 @@
+package smshandler
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseSMSTimestamp(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"24/01/15,10:30:45+00", "2024-01-15T10:30:45Z", false},
+		{"25/07/21,21:07:17-28", "2025-07-21T21:07:17-07:00", false},
+		{`"24/01/15,10:30:45+22"`, "2024-01-15T10:30:45+05:30", false},
+		{"24/01/15,10:30:45", "2024-01-15T10:30:45Z", false},
+		{"24/01/15", "", true},
+		{"24/02/31,10:30:45+00", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSMSTimestamp(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSMSTimestamp(%q): expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSMSTimestamp(%q): %v", tt.input, err)
+			continue
+		}
+		if got.Format(time.RFC3339) != tt.want {
+			t.Errorf("parseSMSTimestamp(%q): got %s, want %s", tt.input, got.Format(time.RFC3339), tt.want)
+		}
+	}
+}
+
+func TestSMSMarshalJSON(t *testing.T) {
+	ts, _ := parseSMSTimestamp("25/07/21,21:07:17-28")
+	sms := SMS{
+		Index:     3,
+		Status:    StatusRead,
+		Sender:    "+11234567890",
+		Date:      "25/07/21,21:07:17-28",
+		Timestamp: ts,
+		Message:   "Hi",
+	}
+
+	data, err := json.Marshal(sms)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"index":3,"status":"REC READ","sender":"+11234567890","date":"25/07/21,21:07:17-28","timestamp":"2025-07-21T21:07:17-07:00","message":"Hi"}`
+	if string(data) != want {
+		t.Errorf("got %s\nwant %s", data, want)
+	}
+
+	// The normalized sender is included when set
+	data, err = json.Marshal(SMS{Index: 2, Sender: "07911123456", SenderNormalized: "+447911123456"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"index":2,"sender":"07911123456","sender_normalized":"+447911123456"}` {
+		t.Errorf("got %s", data)
+	}
+
+	// Empty fields are omitted
+	data, err = json.Marshal(SMS{Index: 1, Message: "x"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"index":1,"message":"x"}` {
+		t.Errorf("got %s", data)
+	}
+
+	// The output decodes back into the exported fields
+	var decoded SMS
+	if err := json.Unmarshal([]byte(want), &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Sender != sms.Sender || decoded.Status != StatusRead || !decoded.Timestamp.Equal(ts) {
+		t.Errorf("round trip mismatch: %+v", decoded)
+	}
+}
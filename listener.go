@@ -0,0 +1,174 @@
+package smshandler
+
+import (
+	"context"
+	"sync"
+)
+
+// listenerRun is one listener goroutine
+type listenerRun struct {
+	callback func(SMS)
+	// stop is closed by stopListener to ask the goroutine to exit
+	stop chan struct{}
+	// done is closed once the goroutine no longer reads the port
+	done chan struct{}
+	// reads reads the messages +CMTI notifications point to; see readStored
+	reads *workQueue
+	// callbacks runs callback for each message deliver queues, off the
+	// listener goroutine so the callback can send commands itself
+	callbacks *workQueue
+	deliver   func(SMS)
+}
+
+// workQueue runs queued functions one at a time, in order, on its own
+// goroutine
+type workQueue struct {
+	mu      sync.Mutex
+	pending []func()
+	wake    chan struct{}
+}
+
+func newWorkQueue() *workQueue {
+	return &workQueue{wake: make(chan struct{}, 1)}
+}
+
+func (q *workQueue) push(fn func()) {
+	q.mu.Lock()
+	q.pending = append(q.pending, fn)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next removes and returns the oldest queued function, nil if none
+func (q *workQueue) next() func() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil
+	}
+	fn := q.pending[0]
+	q.pending = q.pending[1:]
+	return fn
+}
+
+// run works through the queue until done is closed and nothing is left
+func (q *workQueue) run(done <-chan struct{}) {
+	for {
+		if fn := q.next(); fn != nil {
+			fn()
+			continue
+		}
+		select {
+		case <-q.wake:
+		case <-done:
+			if fn := q.next(); fn != nil {
+				fn()
+				continue
+			}
+			return
+		}
+	}
+}
+
+// isListening reports whether a listener goroutine is running
+func (s *SMSHandler) isListening() bool {
+	return s.currentListener() != nil
+}
+
+// currentListener returns the running listener, or nil
+func (s *SMSHandler) currentListener() *listenerRun {
+	s.listenMu.Lock()
+	defer s.listenMu.Unlock()
+	return s.listener
+}
+
+// startListener starts a listener with callback. The caller holds listenMu
+// and has made sure no other listener is running.
+func (s *SMSHandler) startListener(callback func(SMS)) {
+	run := &listenerRun{
+		callback:  callback,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		reads:     newWorkQueue(),
+		callbacks: newWorkQueue(),
+	}
+	run.deliver = func(sms SMS) {
+		run.callbacks.push(func() { s.invokeCallback(callback, sms) })
+	}
+	s.listener = run
+	s.startStatusRefresh()
+
+	readsDone := make(chan struct{})
+	go func() {
+		run.reads.run(run.done)
+		close(readsDone)
+	}()
+	go run.callbacks.run(readsDone)
+	go s.listen(run)
+}
+
+// readStored reads the message a +CMTI notification points to on run's read
+// worker. Reading it takes a command, which pauses the listener, so it
+// cannot be done on the listener goroutine itself.
+func (s *SMSHandler) readStored(run *listenerRun, line string) {
+	run.reads.push(func() {
+		defer s.recoverListener()
+		s.handleCMTIMessage(line, run.deliver)
+	})
+}
+
+// startListenerIfIdle starts a listener with a no-op callback unless one is
+// already running
+func (s *SMSHandler) startListenerIfIdle() {
+	s.listenMu.Lock()
+	defer s.listenMu.Unlock()
+
+	if s.listener == nil {
+		s.startListener(func(SMS) {})
+	}
+}
+
+// listenerExited unregisters run once its goroutine stops reading
+func (s *SMSHandler) listenerExited(run *listenerRun) {
+	s.listenMu.Lock()
+	if s.listener == run {
+		s.listener = nil
+	}
+	s.listenMu.Unlock()
+	close(run.done)
+}
+
+// requestStop asks the running listener to exit without waiting for it. It
+// returns that listener, nil if none was running.
+func (s *SMSHandler) requestStop() *listenerRun {
+	s.listenMu.Lock()
+	run := s.listener
+	s.listener = nil
+	s.listenMu.Unlock()
+
+	if run != nil {
+		close(run.stop)
+	}
+	return run
+}
+
+// stopListener asks the running listener to exit and waits until it has,
+// or until ctx is done. It returns the stopped listener, nil if none was
+// running. It must not be called from the listener goroutine.
+func (s *SMSHandler) stopListener(ctx context.Context) (*listenerRun, error) {
+	run := s.requestStop()
+	if run == nil {
+		return nil, nil
+	}
+	select {
+	case <-run.done:
+		return run, nil
+	case <-ctx.Done():
+		return run, ctx.Err()
+	}
+}
@@ -0,0 +1,26 @@
+package smshandler
+
+import "log"
+
+// Logger receives diagnostic output from the handler. Printf is used for
+// warnings and errors that do not fail an operation; Debugf for detailed
+// traces such as each init command and its response.
+type Logger interface {
+	Printf(format string, v ...interface{})
+	Debugf(format string, v ...interface{})
+}
+
+// stdLogger writes warnings to the standard log package and drops debug
+// output. It is used when no logger is configured.
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) { log.Printf(format, v...) }
+func (stdLogger) Debugf(format string, v ...interface{}) {}
+
+// logger returns the configured logger or the standard one
+func (s *SMSHandler) logger() Logger {
+	if s.cfg.logger == nil {
+		return stdLogger{}
+	}
+	return s.cfg.logger
+}
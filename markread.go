@@ -0,0 +1,48 @@
+package smshandler
+
+import "fmt"
+
+// ReadUnreadAndMarkRead reads the unread messages and then explicitly marks
+// each one read, so a message is returned by exactly one call.
+//
+// Whether listing REC UNREAD messages (AT+CMGL) flips them to REC READ
+// depends on the modem: some do, some leave them unread, so ReadNewSMS can
+// return the same message again or never again depending on hardware. Here
+// each listed message (every part of a concatenated one) is then read with
+// AT+CMGR, which GSM 07.05 requires to change a received unread message to
+// read, making the outcome the same on both kinds of modem. The returned
+// messages keep the status they were listed with. If marking fails, the
+// messages are still returned along with the error, so the caller can
+// process them and retry; the unmarked ones are listed again next time.
+func (s *SMSHandler) ReadUnreadAndMarkRead() ([]SMS, error) {
+	messages, err := s.ReadSMSByStatus(StatusUnread)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sms := range messages {
+		indexes := sms.PartIndexes
+		if len(indexes) == 0 {
+			indexes = []int{sms.Index}
+		}
+		for _, index := range indexes {
+			if err := s.markRead(index); err != nil {
+				return messages, err
+			}
+		}
+	}
+	return messages, nil
+}
+
+// markRead reads the message at index for the side effect of marking it read
+func (s *SMSHandler) markRead(index int) error {
+	response, err := s.sendATCommand(fmt.Sprintf("AT+CMGR=%d", index))
+	if err != nil {
+		return fmt.Errorf("failed to mark SMS %d read: %v", index, err)
+	}
+	if modemErr := finalResultError(response); modemErr != nil {
+		s.metricsRecorder().IncModemError(modemErr.class())
+		return fmt.Errorf("failed to mark SMS %d read: %w", index, modemErr)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+)
+
+const unreadListing = "+CMGL: 3,\"REC UNREAD\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nFirst\r\n" +
+	"+CMGL: 5,\"REC UNREAD\",\"+1987654321\",,\"24/01/15,10:31:00+00\"\r\nSecond\r\nOK\r\n"
+
+func TestReadUnreadAndMarkRead(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string // what AT+CMGR reports after the listing
+	}{
+		{"listing marks read", "REC READ"},
+		{"listing leaves unread", "REC UNREAD"},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		mockPort.AddResponse(`AT+CMGL="REC UNREAD"`, unreadListing)
+		mockPort.AddResponse("AT+CMGR=3", "+CMGR: \""+tt.status+"\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nFirst\r\nOK\r\n")
+		mockPort.AddResponse("AT+CMGR=5", "+CMGR: \""+tt.status+"\",\"+1987654321\",,\"24/01/15,10:31:00+00\"\r\nSecond\r\nOK\r\n")
+
+		messages, err := handler.ReadUnreadAndMarkRead()
+		if err != nil {
+			t.Errorf("%s: ReadUnreadAndMarkRead failed: %v", tt.name, err)
+			continue
+		}
+		if len(messages) != 2 || messages[0].Message != "First" || messages[1].Message != "Second" {
+			t.Errorf("%s: got %+v", tt.name, messages)
+		}
+
+		written := mockPort.GetWrittenData()
+		for _, cmd := range []string{"AT+CMGR=3", "AT+CMGR=5"} {
+			if !strings.Contains(written, cmd) {
+				t.Errorf("%s: %s not sent: %q", tt.name, cmd, written)
+			}
+		}
+	}
+}
+
+func TestReadUnreadAndMarkReadFailure(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGL="REC UNREAD"`, unreadListing)
+	mockPort.AddResponse("AT+CMGR=3", "+CMS ERROR: 321\r\n")
+
+	messages, err := handler.ReadUnreadAndMarkRead()
+	if err == nil || !strings.Contains(err.Error(), "SMS 3") {
+		t.Errorf("expected an error marking SMS 3, got %v", err)
+	}
+	if len(messages) != 2 {
+		t.Errorf("messages not returned with the error: %+v", messages)
+	}
+}
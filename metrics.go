@@ -0,0 +1,84 @@
+package smshandler
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error classes reported to MetricsRecorder
+const (
+	ErrorClassTimeout       = "timeout"
+	ErrorClassPromptTimeout = "prompt_timeout"
+	ErrorClassWrite         = "write"
+	ErrorClassCMS           = "cms_error"
+	ErrorClassCME           = "cme_error"
+	ErrorClassError         = "error"
+	ErrorClassBusy          = "busy"
+	ErrorClassUnknown       = "unknown"
+)
+
+// MetricsRecorder receives counters from the handler so they can be exported
+// to a monitoring system without the library depending on one. Methods may
+// be called from multiple goroutines.
+type MetricsRecorder interface {
+	// IncSent fires once for every message the modem accepted for sending
+	// (SendSMS or SendStoredSMS returned nil).
+	IncSent()
+	// IncReceived fires once for every incoming message passed to the
+	// listener callback, after de-duplication.
+	IncReceived()
+	// IncSendError fires when a send fails. The class is one of the
+	// ErrorClass constants.
+	IncSendError(class string)
+	// IncModemError fires when an AT command times out or the modem replies
+	// with ERROR, +CMS ERROR or +CME ERROR where success was expected.
+	IncModemError(class string)
+}
+
+// metricsRecorder returns the recorder events are reported to: the
+// handler's own Stats counts, and the configured recorder if there is one
+func (s *SMSHandler) metricsRecorder() MetricsRecorder {
+	if s.metrics == nil {
+		return &s.stats
+	}
+	return teeMetrics{stats: &s.stats, next: s.metrics}
+}
+
+// classifiedError tags an error with its metrics class
+type classifiedError struct {
+	class string
+	err   error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+func (e *classifiedError) Unwrap() error { return e.err }
+
+func classify(class string, err error) error {
+	return &classifiedError{class: class, err: err}
+}
+
+// errorClass returns the metrics class of err, or ErrorClassUnknown
+func errorClass(err error) string {
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return ce.class
+	}
+	var me *ModemError
+	if errors.As(err, &me) {
+		return me.class()
+	}
+	return ErrorClassUnknown
+}
+
+// responseErrorClass classifies a modem response containing an error
+func responseErrorClass(response string) string {
+	switch {
+	case strings.Contains(response, "+CMS ERROR"):
+		return ErrorClassCMS
+	case strings.Contains(response, "+CME ERROR"):
+		return ErrorClassCME
+	case strings.Contains(response, "ERROR"):
+		return ErrorClassError
+	}
+	return ErrorClassUnknown
+}
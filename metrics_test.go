@@ -0,0 +1,108 @@
+package smshandler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingMetrics records calls for assertions
+type countingMetrics struct {
+	mu          sync.Mutex
+	sent        int
+	received    int
+	sendErrors  map[string]int
+	modemErrors map[string]int
+}
+
+func newCountingMetrics() *countingMetrics {
+	return &countingMetrics{sendErrors: map[string]int{}, modemErrors: map[string]int{}}
+}
+
+func (c *countingMetrics) IncSent()     { c.mu.Lock(); c.sent++; c.mu.Unlock() }
+func (c *countingMetrics) IncReceived() { c.mu.Lock(); c.received++; c.mu.Unlock() }
+func (c *countingMetrics) IncSendError(class string) {
+	c.mu.Lock()
+	c.sendErrors[class]++
+	c.mu.Unlock()
+}
+func (c *countingMetrics) IncModemError(class string) {
+	c.mu.Lock()
+	c.modemErrors[class]++
+	c.mu.Unlock()
+}
+
+func TestMetricsRecorder(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	metrics := newCountingMetrics()
+	handler.metrics = metrics
+
+	mockPort.AddResponse("AT+CMSS=1", "+CMSS: 9\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMSS=2", "+CMS ERROR: 500\r\n")
+
+	if err := handler.SendStoredSMS(1); err != nil {
+		t.Fatalf("SendStoredSMS failed: %v", err)
+	}
+	if err := handler.SendStoredSMS(2); err == nil {
+		t.Fatal("expected error")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n> ")
+		time.Sleep(150 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n+CMS ERROR: 38\r\n")
+	}()
+	if err := handler.SendSMS("+1234567890", "Hi"); err == nil {
+		t.Fatal("expected SendSMS error")
+	}
+
+	handler.deliver(SMS{Sender: "+1", Message: "x"}, func(SMS) {})
+
+	if metrics.sent != 1 {
+		t.Errorf("sent: got %d, want 1", metrics.sent)
+	}
+	if metrics.received != 1 {
+		t.Errorf("received: got %d, want 1", metrics.received)
+	}
+	if metrics.sendErrors[ErrorClassCMS] != 2 {
+		t.Errorf("send errors: got %v, want 2 %s", metrics.sendErrors, ErrorClassCMS)
+	}
+	if metrics.modemErrors[ErrorClassCMS] != 1 {
+		t.Errorf("modem errors: got %v, want 1 %s", metrics.modemErrors, ErrorClassCMS)
+	}
+}
+
+func TestStats(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	clk := newFakeClock()
+	handler.clk = clk
+	metrics := newCountingMetrics()
+	handler.metrics = metrics
+
+	mockPort.AddResponse("AT+CMSS=1", "+CMSS: 9\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMSS=2", "+CMS ERROR: 500\r\n")
+	if err := handler.SendStoredSMS(1); err != nil {
+		t.Fatalf("SendStoredSMS failed: %v", err)
+	}
+	if err := handler.SendStoredSMS(2); err == nil {
+		t.Fatal("expected error")
+	}
+	handler.deliver(SMS{Sender: "+1234567890", Message: "hi"}, func(SMS) {})
+
+	stats := handler.Stats()
+	if stats.Sent != 1 || stats.SendFailed != 1 || stats.Received != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if metrics.sent != 1 || metrics.received != 1 {
+		t.Errorf("recorder not fed alongside stats: sent %d, received %d", metrics.sent, metrics.received)
+	}
+
+	clk.Advance(time.Hour)
+	handler.ResetStats()
+	if stats := handler.Stats(); stats != (Stats{Since: clk.Now()}) {
+		t.Errorf("after reset: %+v", stats)
+	}
+}
@@ -0,0 +1,142 @@
+package smshandler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// maxParts is the most parts a concatenation header can number
+const maxParts = 255
+
+// MultipartSendError is returned when one part of a concatenated message
+// fails to send. The parts before it were sent and will reach the
+// recipient as an incomplete message.
+type MultipartSendError struct {
+	// Part is the 1-based number of the part that failed
+	Part int
+	// Total is the number of parts in the message
+	Total int
+	// Refs holds the message references of the parts that were sent, in
+	// order; -1 where the modem did not report a readable one
+	Refs []int
+	// Err is the error from sending the failed part
+	Err error
+}
+
+func (e *MultipartSendError) Error() string {
+	return fmt.Sprintf("failed to send part %d of %d (%d sent): %v", e.Part, e.Total, len(e.Refs), e.Err)
+}
+
+func (e *MultipartSendError) Unwrap() error {
+	return e.Err
+}
+
+// submitPart is one encoded SMS-SUBMIT of a concatenated message
+type submitPart struct {
+	pdu    string
+	length int
+}
+
+// encodeMultipartPDUs splits message into SMS-SUBMIT PDUs that each carry a
+// concatenation header with the shared reference ref and the relative
+// validity period vp
+func encodeMultipartPDUs(number, message string, t AddressType, enc Encoding, ref, vp byte) ([]submitPart, error) {
+	da, err := encodeAddress(number, t)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks [][]byte
+	if enc == EncodingGSM7 {
+		septets, _ := encodeGSM7(message)
+		chunks = splitSeptets(septets, maxGSM7SeptetsPerPart)
+	} else {
+		chunks = splitUCS2(encodeUCS2(message), maxUCS2UnitsPerPart)
+	}
+	if len(chunks) > maxParts {
+		return nil, fmt.Errorf("message needs %d parts, at most %d can be concatenated", len(chunks), maxParts)
+	}
+
+	parts := make([]submitPart, len(chunks))
+	for i, chunk := range chunks {
+		udh := []byte{0x05, 0x00, 0x03, ref, byte(len(chunks)), byte(i + 1)}
+
+		var part submitPart
+		if enc == EncodingGSM7 {
+			// One fill bit aligns the septets after the 6-octet header
+			ud := append(udh, packSeptets(chunk, 1)...)
+			part.pdu, part.length = submitPDU(da, true, dcsGSM7, vp, 7+len(chunk), ud)
+		} else {
+			ud := append(udh, chunk...)
+			part.pdu, part.length = submitPDU(da, true, dcsUCS2, vp, len(ud), ud)
+		}
+		parts[i] = part
+	}
+	return parts, nil
+}
+
+// splitSeptets cuts GSM 7-bit text into chunks of at most size septets
+// without separating an escape from the extension character it introduces
+func splitSeptets(septets []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(septets) > size {
+		n := size
+		if septets[n-1] == gsm7Escape {
+			n--
+		}
+		chunks = append(chunks, septets[:n])
+		septets = septets[n:]
+	}
+	return append(chunks, septets)
+}
+
+// splitUCS2 cuts UCS2 text into chunks of at most size UTF-16 units without
+// separating a surrogate pair
+func splitUCS2(b []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(b) > 2*size {
+		n := 2 * size
+		if hi := b[n-2]; hi >= 0xD8 && hi <= 0xDB {
+			n -= 2
+		}
+		chunks = append(chunks, b[:n])
+		b = b[n:]
+	}
+	return append(chunks, b)
+}
+
+// sendMultipart sends a message too long for one SMS as a concatenated
+// message and returns the first part's reference. Parts share a rolling
+// 8-bit concatenation reference so the recipient's phone can join them.
+func (s *SMSHandler) sendMultipart(number, message string, enc Encoding, o sendOptions) (int, error) {
+	ref := byte(atomic.AddUint32(&s.concatRef, 1))
+	parts, err := encodeMultipartPDUs(number, message, o.addressType, enc, ref, o.relativeValidity())
+	if err != nil {
+		return -1, fmt.Errorf("failed to encode PDU: %v", err)
+	}
+
+	refs := make([]int, 0, len(parts))
+	for i, part := range parts {
+		s.waitForSendSlot()
+
+		progress := o.progressFor(i+1, len(parts))
+		response, err := s.composeMessage(context.Background(), fmt.Sprintf("AT+CMGS=%d", part.length), part.pdu, "+CMGS:", 30*time.Second, progress)
+		if err != nil {
+			s.metricsRecorder().IncSendError(errorClass(err))
+			return -1, &MultipartSendError{Part: i + 1, Total: len(parts), Refs: refs, Err: err}
+		}
+
+		mr, err := parseResultNumber(response, "+CMGS:")
+		if err != nil {
+			s.logger().Printf("Sent part %d of %d without a readable message reference: %v", i+1, len(parts), err)
+			mr = -1
+		}
+		refs = append(refs, mr)
+		progress.report(StagePartSent)
+	}
+
+	s.metricsRecorder().IncSent()
+	return refs[0], nil
+}
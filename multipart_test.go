@@ -0,0 +1,119 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// decodeParts decodes encoded parts and checks their concatenation headers
+func decodeParts(t *testing.T, parts []submitPart, ref int) string {
+	t.Helper()
+
+	var joined strings.Builder
+	for i, part := range parts {
+		sms, info, err := decodePDU(part.pdu)
+		if err != nil {
+			t.Fatalf("part %d: decodePDU failed: %v", i+1, err)
+		}
+		if info.ref != ref || info.total != len(parts) || info.seq != i+1 {
+			t.Errorf("part %d: header %+v", i+1, info)
+		}
+		if part.length != len(part.pdu)/2-1 {
+			t.Errorf("part %d: length %d for a %d-octet PDU", i+1, part.length, len(part.pdu)/2)
+		}
+		joined.WriteString(sms.Message)
+	}
+	return joined.String()
+}
+
+func TestEncodeMultipartPDUsGSM7(t *testing.T) {
+	message := strings.Repeat("0123456789", 20)
+	parts, err := encodeMultipartPDUs("+46708251358", message, AddressTypeAuto, EncodingGSM7, 42, defaultValidityPeriod)
+	if err != nil {
+		t.Fatalf("encodeMultipartPDUs failed: %v", err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(parts))
+	}
+	if got := decodeParts(t, parts, 42); got != message {
+		t.Errorf("joined parts: got %q", got)
+	}
+}
+
+func TestEncodeMultipartPDUsUCS2(t *testing.T) {
+	// The emoji's surrogate pair straddles the 67-unit boundary
+	message := strings.Repeat("ж", 66) + "😀" + strings.Repeat("ж", 10)
+	parts, err := encodeMultipartPDUs("+46708251358", message, AddressTypeAuto, EncodingUCS2, 7, defaultValidityPeriod)
+	if err != nil {
+		t.Fatalf("encodeMultipartPDUs failed: %v", err)
+	}
+	if got := decodeParts(t, parts, 7); got != message {
+		t.Errorf("joined parts: got %q", got)
+	}
+}
+
+func TestSplitSeptetsKeepsEscapes(t *testing.T) {
+	septets, _ := encodeGSM7(strings.Repeat("a", 152) + "€b")
+	chunks := splitSeptets(septets, maxGSM7SeptetsPerPart)
+	if len(chunks) != 2 || len(chunks[0]) != 152 {
+		t.Fatalf("escape split across parts: chunk sizes %d", len(chunks[0]))
+	}
+	if decodeGSM7(chunks[1]) != "€b" {
+		t.Errorf("second chunk: got %q", decodeGSM7(chunks[1]))
+	}
+}
+
+func TestSendSMSMultipart(t *testing.T) {
+	message := strings.Repeat("0123456789", 20)
+	parts, err := encodeMultipartPDUs("+46708251358", message, AddressTypeAuto, EncodingGSM7, 1, defaultValidityPeriod)
+	if err != nil {
+		t.Fatalf("encodeMultipartPDUs failed: %v", err)
+	}
+
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.mode = ModePDU
+	for i, part := range parts {
+		mockPort.AddResponse(fmt.Sprintf("AT+CMGS=%d", part.length), "\r\n> ")
+		mockPort.AddResponse(part.pdu+"\x1A", fmt.Sprintf("\r\n+CMGS: %d\r\nOK\r\n", 10+i))
+	}
+
+	ref, err := handler.SendSMSRef("+46708251358", message)
+	if err != nil {
+		t.Fatalf("SendSMSRef failed: %v", err)
+	}
+	if ref != 10 {
+		t.Errorf("reference: got %d, want the first part's 10", ref)
+	}
+}
+
+func TestSendSMSMultipartPartFails(t *testing.T) {
+	message := strings.Repeat("0123456789", 20)
+	parts, err := encodeMultipartPDUs("+46708251358", message, AddressTypeAuto, EncodingGSM7, 1, defaultValidityPeriod)
+	if err != nil {
+		t.Fatalf("encodeMultipartPDUs failed: %v", err)
+	}
+
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.mode = ModePDU
+	mockPort.AddResponse(fmt.Sprintf("AT+CMGS=%d", parts[0].length), "\r\n> ")
+	mockPort.AddResponse(parts[0].pdu+"\x1A", "\r\n+CMGS: 10\r\nOK\r\n")
+	mockPort.AddResponse(fmt.Sprintf("AT+CMGS=%d", parts[1].length), "\r\n> ")
+	mockPort.AddResponse(parts[1].pdu+"\x1A", "\r\n+CMS ERROR: 500\r\n")
+
+	err = handler.SendSMS("+46708251358", message)
+	var partErr *MultipartSendError
+	if !errors.As(err, &partErr) {
+		t.Fatalf("expected a MultipartSendError, got %v", err)
+	}
+	if partErr.Part != 2 || partErr.Total != 2 || len(partErr.Refs) != 1 || partErr.Refs[0] != 10 {
+		t.Errorf("unexpected error details: %+v", partErr)
+	}
+	var modemErr *ModemError
+	if !errors.As(err, &modemErr) {
+		t.Errorf("modem error not wrapped: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package smshandler
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SignalQuality is the received signal strength and bit error rate from
+// AT+CSQ
+type SignalQuality struct {
+	// RSSI is the raw strength from 0 (-113 dBm or less) to 31 (-51 dBm or
+	// more), or 99 when unknown
+	RSSI int
+	// BER is the raw bit error rate class from 0 to 7, or 99 when unknown
+	BER int
+}
+
+// DBm converts RSSI to dBm. It returns false when the modem did not know
+// the strength.
+func (q SignalQuality) DBm() (int, bool) {
+	if q.RSSI < 0 || q.RSSI > 31 {
+		return 0, false
+	}
+	return -113 + 2*q.RSSI, true
+}
+
+// SignalQuality reads and parses the signal strength with AT+CSQ.
+// GetSignalStrength returns the same response unparsed.
+func (s *SMSHandler) SignalQuality() (SignalQuality, error) {
+	response, err := s.sendATCommandExpectOK("AT+CSQ")
+	if err != nil {
+		return SignalQuality{}, fmt.Errorf("failed to read signal quality: %v", err)
+	}
+	quality, err := parseCSQ(response)
+	if err != nil {
+		return SignalQuality{}, err
+	}
+	s.recordSignal(quality)
+	return quality, nil
+}
+
+// parseCSQ parses "+CSQ: <rssi>,<ber>"
+func parseCSQ(response string) (SignalQuality, error) {
+	fields, ok := resultFields(response, "+CSQ:")
+	if !ok || len(fields) < 2 {
+		return SignalQuality{}, fmt.Errorf("unexpected AT+CSQ response %q", response)
+	}
+
+	rssi, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return SignalQuality{}, fmt.Errorf("invalid signal strength %q: %v", fields[0], err)
+	}
+	ber, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return SignalQuality{}, fmt.Errorf("invalid bit error rate %q: %v", fields[1], err)
+	}
+	return SignalQuality{RSSI: rssi, BER: ber}, nil
+}
+
+// Operator returns the name of the network the modem is registered on, as
+// reported by AT+COPS? in whatever format the modem is set to (usually the
+// long alphanumeric name). It returns "" when the modem is not registered.
+func (s *SMSHandler) Operator() (string, error) {
+	response, err := s.sendATCommandExpectOK("AT+COPS?")
+	if err != nil {
+		return "", fmt.Errorf("failed to read operator: %v", err)
+	}
+	return parseCOPS(response)
+}
+
+// parseCOPS extracts <oper> from "+COPS: <mode>[,<format>,<oper>[,<AcT>]]"
+func parseCOPS(response string) (string, error) {
+	fields, ok := resultFields(response, "+COPS:")
+	if !ok {
+		return "", fmt.Errorf("unexpected AT+COPS? response %q", response)
+	}
+	if len(fields) < 3 {
+		// Only <mode>: not registered
+		return "", nil
+	}
+	return fields[2], nil
+}
+
+// accessTechnologies names the <AcT> values of +COPS and +CREG
+var accessTechnologies = map[int]string{
+	0:  "GSM",
+	1:  "GSM Compact",
+	2:  "UMTS",
+	3:  "EDGE",
+	4:  "HSDPA",
+	5:  "HSUPA",
+	6:  "HSPA",
+	7:  "LTE",
+	8:  "EC-GSM-IoT",
+	9:  "NB-IoT",
+	10: "LTE (5GC)",
+	11: "NR",
+	12: "NG-RAN",
+	13: "LTE/NR (EN-DC)",
+}
+
+// AccessTechnology returns the radio access technology the modem is using,
+// such as "GSM", "UMTS" or "LTE". It reads the <AcT> field of AT+COPS?,
+// then of AT+CREG? for modems that only report it there (with AT+CREG=2).
+// When neither reports it the error wraps ErrNotSupported. The band is not
+// covered by a standard command and is not reported.
+func (s *SMSHandler) AccessTechnology() (string, error) {
+	for _, q := range []struct {
+		cmd, prefix string
+		field       int
+	}{
+		{"AT+COPS?", "+COPS:", 3}, // +COPS: <mode>,<format>,<oper>,<AcT>
+		{"AT+CREG?", "+CREG:", 4}, // +CREG: <n>,<stat>,<lac>,<ci>,<AcT>
+	} {
+		response, err := s.queryDevice(q.cmd)
+		if err != nil {
+			continue
+		}
+		fields, ok := resultFields(response, q.prefix)
+		if !ok || len(fields) <= q.field {
+			continue
+		}
+		act, err := strconv.Atoi(fields[q.field])
+		if err != nil {
+			continue
+		}
+		if name, ok := accessTechnologies[act]; ok {
+			return name, nil
+		}
+		return fmt.Sprintf("AcT %d", act), nil
+	}
+	return "", fmt.Errorf("failed to read access technology: %w", ErrNotSupported)
+}
@@ -0,0 +1,111 @@
+package smshandler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSignalQuality(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CSQ", "+CSQ: 20,99\r\nOK\r\n")
+
+	q, err := handler.SignalQuality()
+	if err != nil {
+		t.Fatalf("SignalQuality failed: %v", err)
+	}
+	if q != (SignalQuality{RSSI: 20, BER: 99}) {
+		t.Errorf("got %+v", q)
+	}
+	if dbm, ok := q.DBm(); !ok || dbm != -73 {
+		t.Errorf("DBm: got %d, %v", dbm, ok)
+	}
+	if _, ok := (SignalQuality{RSSI: 99}).DBm(); ok {
+		t.Error("unknown strength converted to dBm")
+	}
+
+	if _, err := parseCSQ("+CSQ: x,0\nOK"); err == nil {
+		t.Error("expected error for a non-numeric strength")
+	}
+}
+
+func TestOperator(t *testing.T) {
+	tests := []struct {
+		response string
+		want     string
+	}{
+		{"+COPS: 0,0,\"T-Mobile, USA\",7\r\nOK\r\n", "T-Mobile, USA"},
+		{"+COPS: 0,2,\"310260\"\r\nOK\r\n", "310260"},
+		{"+COPS: 0\r\nOK\r\n", ""},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		mockPort.AddResponse("AT+COPS?", tt.response)
+
+		got, err := handler.Operator()
+		if err != nil {
+			t.Fatalf("Operator failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Operator for %q: got %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestAccessTechnology(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses map[string]string
+		want      string
+	}{
+		{
+			name:      "From COPS",
+			responses: map[string]string{"AT+COPS?": "+COPS: 0,0,\"Carrier\",7\r\nOK\r\n"},
+			want:      "LTE",
+		},
+		{
+			name: "From CREG",
+			responses: map[string]string{
+				"AT+COPS?": "+COPS: 0,0,\"Carrier\"\r\nOK\r\n",
+				"AT+CREG?": "+CREG: 2,1,\"00C3\",\"1A2B\",2\r\nOK\r\n",
+			},
+			want: "UMTS",
+		},
+		{
+			name:      "Unlisted value",
+			responses: map[string]string{"AT+COPS?": "+COPS: 0,0,\"Carrier\",42\r\nOK\r\n"},
+			want:      "AcT 42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPort := NewMockSerialPort()
+			handler := newMockHandler(mockPort)
+			for cmd, resp := range tt.responses {
+				mockPort.AddResponse(cmd, resp)
+			}
+
+			got, err := handler.AccessTechnology()
+			if err != nil {
+				t.Fatalf("AccessTechnology failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccessTechnologyNotReported(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+COPS?", "+COPS: 0\r\nOK\r\n")
+	mockPort.AddResponse("AT+CREG?", "+CREG: 0,1\r\nOK\r\n")
+
+	if _, err := handler.AccessTechnology(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrClockNotSynced is returned by NetworkTime while the modem clock still
+// holds its power-on default, before the network has supplied the time.
+// Network time updates may need enabling first with AT+CTZU=1.
+var ErrClockNotSynced = errors.New("modem clock not synchronized with the network")
+
+// Years outside this range are factory defaults such as 80/01/06, never a
+// time the network reported
+const (
+	minNetworkYear = 2010
+	maxNetworkYear = 2069
+)
+
+// NetworkTime returns the modem's clock (AT+CCLK?), which tracks network
+// time on modems that receive it, in the zone reported by the modem.
+func (s *SMSHandler) NetworkTime() (time.Time, error) {
+	response, err := s.queryDevice("AT+CCLK?")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read modem clock: %w", err)
+	}
+
+	fields, ok := resultFields(response, "+CCLK:")
+	if !ok || len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("failed to read modem clock: unexpected response %q", response)
+	}
+
+	// "yy/MM/dd,hh:mm:ss±zz", quoted by most modems but not all
+	t, err := parseSMSTimestamp(strings.Join(fields, ","))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read modem clock: %v", err)
+	}
+	if t.Year() < minNetworkYear || t.Year() > maxNetworkYear {
+		return time.Time{}, ErrClockNotSynced
+	}
+	return t, nil
+}
@@ -0,0 +1,42 @@
+package smshandler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNetworkTime(t *testing.T) {
+	tests := []struct {
+		response string
+		want     string
+		wantErr  error
+	}{
+		{"+CCLK: \"24/01/15,10:30:45+08\"\r\nOK\r\n", "2024-01-15T10:30:45+02:00", nil},
+		{"+CCLK: 24/01/15,10:30:45-20\r\nOK\r\n", "2024-01-15T10:30:45-05:00", nil},
+		{"+CCLK: \"80/01/06,00:00:12+00\"\r\nOK\r\n", "", ErrClockNotSynced},
+		{"+CCLK: \"04/01/01,00:00:12+00\"\r\nOK\r\n", "", ErrClockNotSynced},
+		{"ERROR\r\n", "", ErrNotSupported},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		mockPort.AddResponse("AT+CCLK?", tt.response)
+
+		got, err := handler.NetworkTime()
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%q: got error %v, want %v", tt.response, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.response, err)
+			continue
+		}
+		if got.Format(time.RFC3339) != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.response, got.Format(time.RFC3339), tt.want)
+		}
+	}
+}
@@ -0,0 +1,606 @@
+package smshandler
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultPollInterval is the read timeout used by the incoming SMS listener
+// when no WithPollInterval option is supplied.
+const DefaultPollInterval = 100 * time.Millisecond
+
+// DefaultComposeDelay is the pause before a prompt-based command and after
+// its '>' prompt when no WithComposeDelays option is supplied.
+const DefaultComposeDelay = 100 * time.Millisecond
+
+// DefaultBaudRate is the serial speed Open uses without WithBaudRate
+const DefaultBaudRate = 115200
+
+// DefaultCommandTimeout is how long a command waits for the modem's final
+// result when no WithCommandTimeout option is supplied
+const DefaultCommandTimeout = 10 * time.Second
+
+// DefaultOKOnlyGrace is how long a send waits for its result line after a
+// bare OK when no WithOKOnlyGrace option is supplied
+const DefaultOKOnlyGrace = 2 * time.Second
+
+// DefaultReceiveTimeout is how long the listener waits for the body of a
+// +CMT message when no WithReceiveTimeout option is supplied.
+const DefaultReceiveTimeout = 2 * time.Second
+
+// Mode selects the message format the modem is driven in (AT+CMGF)
+type Mode int
+
+const (
+	// ModeText exchanges messages as text (AT+CMGF=1). It is the default.
+	ModeText Mode = iota
+	// ModePDU exchanges messages as hex-encoded PDUs (AT+CMGF=0), which
+	// exposes the encoding of received messages and joins concatenated ones
+	ModePDU
+)
+
+// Option configures an SMSHandler at construction time.
+type Option func(*config)
+
+// config holds the resolved settings for a handler
+type config struct {
+	baudRate       int
+	commandTimeout time.Duration
+	pin            string
+	pollInterval   time.Duration
+	receiveTimeout time.Duration
+	dedupEnabled   bool
+	dedupWindow    time.Duration
+
+	minSendInterval time.Duration
+	metrics         MetricsRecorder
+	logger          Logger
+	initCommands    []InitCommand
+	mode            Mode
+	listenerPanics  bool
+	readBufferSize  int
+	reconnect       *ReconnectPolicy
+	lineEnding      string
+	terminator      string
+	cmdTerminator   string
+	startupWait     time.Duration
+	readyAttempts   int
+	readyDelay      time.Duration
+	trace           io.Writer
+	resetWait       time.Duration
+	maxSegments     int
+	errorReporting  ErrorReporting
+	skipInit        bool
+	commandDelay    time.Duration
+	promptDelay     time.Duration
+	discardFlash    bool
+	writeChunkSize  int
+	writeChunkDelay time.Duration
+
+	overwriteWhenFull  bool
+	unhandledLines     int
+	verifySent         bool
+	cleanupSent        bool
+	preserveWhitespace bool
+	statusRefresh      time.Duration
+	showTextHeaders    bool
+	normalizeSenders   bool
+	countryCode        string
+	okOnlyGrace        time.Duration
+
+	callbackPanicHandler func(sms SMS, recovered interface{})
+}
+
+// defaultConfig returns the settings used when no options are given
+func defaultConfig() config {
+	return config{
+		baudRate:       DefaultBaudRate,
+		pollInterval:   DefaultPollInterval,
+		receiveTimeout: DefaultReceiveTimeout,
+		commandDelay:   DefaultComposeDelay,
+		promptDelay:    DefaultComposeDelay,
+	}
+}
+
+// WithBaudRate sets the serial speed Open uses (default 115200)
+func WithBaudRate(baudRate int) Option {
+	return func(c *config) {
+		c.baudRate = baudRate
+	}
+}
+
+// WithCommandTimeout sets how long an AT command waits for the modem's
+// final result before failing (default 10s). Prompt-based sends have their
+// own timeouts.
+func WithCommandTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.commandTimeout = d
+	}
+}
+
+// WithPIN unlocks a PIN-protected SIM during init. The PIN is only sent when
+// AT+CPIN? reports the SIM is waiting for it, so a wrong PIN is not retried
+// against an unlocked SIM.
+func WithPIN(pin string) Option {
+	return func(c *config) {
+		c.pin = pin
+	}
+}
+
+// WithPollInterval sets how long each listener read waits for data before
+// the listener checks for pause requests and loops again. The same interval
+// is used while collecting the body of a +CMT message.
+//
+// Shorter intervals lower the latency of incoming messages and of commands
+// that need to pause the listener, at the cost of more wakeups and CPU time.
+// Longer intervals suit battery-powered deployments where a few hundred
+// milliseconds of extra latency is acceptable. Non-positive values keep the
+// default of 100ms.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.pollInterval = d
+		}
+	}
+}
+
+// WithReceiveTimeout sets how long the listener keeps collecting the body of
+// a directly delivered (+CMT) message. Raise it for slow modems that split
+// long messages over several seconds; lower it to deliver short messages
+// sooner when the modem does not terminate them with a blank line. When the
+// header includes the body length (AT+CSDH=1) the body completes as soon as
+// that many characters arrive. Non-positive values keep the default of 2s.
+func WithReceiveTimeout(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.receiveTimeout = d
+		}
+	}
+}
+
+// WithDeduplication drops an incoming message when an identical one (same
+// sender, modem timestamp and body) was delivered within the given window.
+// This suppresses the double delivery some modems produce by reporting a
+// message both directly (+CMT) and as a stored index (+CMTI). Leave it off
+// on setups where such repeats are genuinely distinct messages. A
+// non-positive window uses DefaultDedupWindow.
+func WithDeduplication(window time.Duration) Option {
+	return func(c *config) {
+		c.dedupEnabled = true
+		c.dedupWindow = window
+	}
+}
+
+// WithMinSendInterval makes the handler wait until at least d has passed
+// since the previous send before starting another one. Modems with a small
+// send queue answer back-to-back sends with +CMS ERROR: 500; pacing sends
+// avoids that. The default of zero disables the limit.
+func WithMinSendInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.minSendInterval = d
+	}
+}
+
+// WithMetrics routes send, receive and error counts to m. See
+// MetricsRecorder for the events that fire each counter.
+func WithMetrics(m MetricsRecorder) Option {
+	return func(c *config) {
+		c.metrics = m
+	}
+}
+
+// WithLogger sends the handler's warnings and debug output to l instead of
+// the standard log package
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithInitCommands runs extra AT commands, in order, after the standard
+// modem init sequence. A failing command fails NewSMSHandler unless its
+// WarnOnly flag is set. Each command and response is logged at debug level.
+func WithInitCommands(cmds ...InitCommand) Option {
+	return func(c *config) {
+		c.initCommands = append(c.initCommands, cmds...)
+	}
+}
+
+// WithMode selects text or PDU mode. In PDU mode received messages report
+// their Encoding, and the parts of concatenated messages are joined into one
+// SMS when listed or received. SendSMS sends text longer than one PDU (160
+// GSM characters, or 70 when the text needs UCS2) as a concatenated
+// message; WriteSMS is still limited to a single PDU.
+func WithMode(m Mode) Option {
+	return func(c *config) {
+		c.mode = m
+	}
+}
+
+// ErrorReporting is the AT+CMEE setting that decides how the modem reports
+// +CME ERROR and +CMS ERROR results
+type ErrorReporting int
+
+const (
+	// ErrorReportingNumeric reports numeric error codes (AT+CMEE=1), the
+	// default, which ModemError.Code and error classification rely on
+	ErrorReportingNumeric ErrorReporting = 1
+	// ErrorReportingVerbose reports error text (AT+CMEE=2), kept in
+	// ModemError.Text
+	ErrorReportingVerbose ErrorReporting = 2
+)
+
+// WithErrorReporting sets the AT+CMEE mode applied during init. Modems left
+// at AT+CMEE=0 answer every failure with a bare ERROR, so the handler always
+// sets a mode explicitly; this option only picks numeric (the default) or
+// verbose.
+func WithErrorReporting(mode ErrorReporting) Option {
+	return func(c *config) {
+		c.errorReporting = mode
+	}
+}
+
+// WithListenerPanics makes a panic in the incoming SMS listener crash the
+// program after it is logged, instead of being recovered. Use it in tests
+// and development to get the full stack trace of parsing bugs; production
+// code normally keeps the default, which logs the panic and stops the
+// listener.
+func WithListenerPanics() Option {
+	return func(c *config) {
+		c.listenerPanics = true
+	}
+}
+
+// WithCallbackPanicHandler sets a function called when the SMS callback
+// panics, with the message being delivered and the recovered value. The
+// panic is logged and the listener carries on with the next message either
+// way; the handler lets the application record or retry the message.
+func WithCallbackPanicHandler(fn func(sms SMS, recovered interface{})) Option {
+	return func(c *config) {
+		c.callbackPanicHandler = fn
+	}
+}
+
+// WithSkipInit opens the port without running the modem init sequence, for
+// a modem already configured by another process or a serial device that is
+// not a modem at all. Reconnect and ResetModem skip it too. The caller is
+// then responsible for the message format (AT+CMGF), character set
+// (AT+CSCS), storage and new-message indications, and the handler's mode
+// must match what the modem is set to.
+func WithSkipInit() Option {
+	return func(c *config) {
+		c.skipInit = true
+	}
+}
+
+// WithDiscardFlashMessages deletes flash (class 0) messages from modem
+// storage once the listener has delivered them. Flash messages are meant to
+// be displayed and not kept, but depending on its AT+CNMI settings a modem
+// may store them like any other message, where they fill up storage and
+// show up again in ReadSMS. By default they are left in storage. Flash
+// messages delivered directly (+CMT) are never stored either way. The
+// callback sees them flagged with SMS.Class in both cases.
+func WithDiscardFlashMessages() Option {
+	return func(c *config) {
+		c.discardFlash = true
+	}
+}
+
+// WithShowTextHeaders enables AT+CSDH=1 during init in text mode, so the
+// modem adds the data coding scheme and other details to +CMT and +CMGR
+// headers and SMS.Encoding and SMS.Class are filled in. It also changes the
+// field layout of +CMGL and +CMGR responses, which matters to anyone
+// parsing ReadSMSRaw output. Off by default; a modem that rejects it is
+// logged and left as it was.
+func WithShowTextHeaders() Option {
+	return func(c *config) {
+		c.showTextHeaders = true
+	}
+}
+
+// WithSenderNormalization fills in SMS.SenderNormalized with the E.164
+// form of each received message's sender ("+" and digits), leaving Sender
+// as the modem reported it. National numbers get countryCode, such as "1"
+// or "+44"; with "" only numbers already in international format are
+// normalized. De-duplication and SendSMSAndWaitForReply then compare
+// normalized senders, so the same number in different formats matches.
+func WithSenderNormalization(countryCode string) Option {
+	return func(c *config) {
+		c.normalizeSenders = true
+		c.countryCode = strings.TrimPrefix(strings.TrimSpace(countryCode), "+")
+	}
+}
+
+// WithOKOnlyGrace sets how long a send waits for the +CMGS (or +CMGW)
+// result line after the modem has answered a message body with a bare OK
+// (default 2s). Some modems send OK before the result line, others send
+// only OK; once the grace period passes without a result line the send
+// counts as successful, without a message reference.
+func WithOKOnlyGrace(d time.Duration) Option {
+	return func(c *config) {
+		c.okOnlyGrace = d
+	}
+}
+
+// WithReadBufferSize sets the size in bytes of the buffered reader on the
+// serial port. A larger buffer cuts the number of reads needed for big
+// responses such as an AT+CMGL="ALL" dump of a full SIM. Values below
+// bufio's minimum keep the bufio default of 4096 bytes.
+func WithReadBufferSize(n int) Option {
+	return func(c *config) {
+		c.readBufferSize = n
+	}
+}
+
+// WithCommandLineEnding sets the line ending sent after prompt-based
+// commands such as AT+CMGS and AT+CMGW. The default is "\r", as the modem
+// answers those with the '>' prompt rather than a line of its own; some
+// modems only show the prompt after "\r\n". Other commands end in the
+// WithCommandTerminator terminator.
+func WithCommandLineEnding(ending string) Option {
+	return func(c *config) {
+		c.lineEnding = ending
+	}
+}
+
+// WithCommandTerminator sets what is sent after every AT command other than
+// the prompt-based ones covered by WithCommandLineEnding. The default is
+// "\r\n"; V.250 only requires "\r", which some modems insist on.
+func WithCommandTerminator(terminator string) Option {
+	return func(c *config) {
+		c.cmdTerminator = terminator
+	}
+}
+
+// WithComposeTerminator sets the character written after a message body.
+// The default, ComposeSubmit, sends the message. ComposeCancel makes every
+// send go through the prompt and body and then abort, returning
+// ErrCompositionCancelled, which is useful for testing a modem without
+// sending real messages.
+func WithComposeTerminator(t string) Option {
+	return func(c *config) {
+		c.terminator = t
+	}
+}
+
+// WithStartupWait makes initialization wait up to d for the modem's
+// "SMS Ready" indication before sending the first command, for handlers
+// opened right after the modem powers on. Until then many modems reject
+// SMS commands. Startup messages such as RDY or +CPIN: READY are discarded
+// either way; without this option init does not wait for them.
+func WithStartupWait(d time.Duration) Option {
+	return func(c *config) {
+		c.startupWait = d
+	}
+}
+
+// WithReadyProbe sets how many times init sends the first AT before giving
+// up with ErrModemNotResponding, and the delay between attempts (default 3
+// attempts, 1s apart). Raise it for modems that take a while to respond
+// after being plugged in. Only this first probe is retried.
+func WithReadyProbe(attempts int, delay time.Duration) Option {
+	return func(c *config) {
+		c.readyAttempts = attempts
+		c.readyDelay = delay
+	}
+}
+
+// WithStatusRefresh re-reads the signal quality and registration state
+// every interval while the listener runs, so Status stays current without
+// each caller querying the modem. By default they are only updated when
+// SignalQuality or RegistrationStatus is called.
+func WithStatusRefresh(interval time.Duration) Option {
+	return func(c *config) {
+		c.statusRefresh = interval
+	}
+}
+
+// WithTrace echoes all serial traffic to w for debugging a misbehaving or
+// unfamiliar modem. Each chunk is written on its own line as a quoted
+// string, prefixed with ">>" for bytes sent to the modem and "<<" for bytes
+// received. Errors writing to w are ignored.
+func WithTrace(w io.Writer) Option {
+	return func(c *config) {
+		c.trace = w
+	}
+}
+
+// WithResetWait sets how long ResetModem waits after AT+CFUN=1,1 before
+// reopening the port. Raise it for modems that take longer than the
+// default of 10s to boot and register their serial ports again.
+func WithResetWait(d time.Duration) Option {
+	return func(c *config) {
+		c.resetWait = d
+	}
+}
+
+// WithMaxSegments caps how many segments SendSMS will split one message
+// into; longer messages fail with a *SegmentLimitError before anything is
+// sent. This guards against a bug in the caller turning into hundreds of
+// billed messages. Non-positive values keep the default of 10.
+func WithMaxSegments(n int) Option {
+	return func(c *config) {
+		c.maxSegments = n
+	}
+}
+
+// WithComposeDelays sets the pauses around prompt-based commands (AT+CMGS
+// for SendSMS, SendBinary and multipart sends, AT+CMGW for WriteSMS):
+// beforeCommand is waited before the command is written, and afterPrompt
+// between the modem's '>' prompt and the message body. Both default to
+// 100ms. Slow modems that drop the first bytes of a command or body need
+// more; fast modems work with zero, which raises send throughput. Negative
+// values are treated as zero.
+func WithComposeDelays(beforeCommand, afterPrompt time.Duration) Option {
+	return func(c *config) {
+		if beforeCommand < 0 {
+			beforeCommand = 0
+		}
+		if afterPrompt < 0 {
+			afterPrompt = 0
+		}
+		c.commandDelay = beforeCommand
+		c.promptDelay = afterPrompt
+	}
+}
+
+// WithWriteChunkSize makes prompt-based commands write the message body,
+// including its terminator, in pieces of at most size bytes with delay
+// between them. Some USB-serial bridges overrun the modem's input buffer
+// when a long body, such as the hex PDU of a UCS2 message, is written at
+// once, and the modem loses bytes. By default, or when size is not
+// positive, the body goes out in a single write.
+func WithWriteChunkSize(size int, delay time.Duration) Option {
+	return func(c *config) {
+		c.writeChunkSize = size
+		c.writeChunkDelay = delay
+	}
+}
+
+// WithOverwriteWhenFull keeps an unattended receiver working when message
+// storage fills up: after each stored incoming message the handler checks
+// the free space (AT+CPMS) and, when none is left, deletes the oldest read
+// message. WriteSMS likewise deletes the oldest read message and retries
+// once when the modem reports storage full. Unread messages are never
+// deleted. Each deletion is logged.
+func WithOverwriteWhenFull() Option {
+	return func(c *config) {
+		c.overwriteWhenFull = true
+	}
+}
+
+// WithUnhandledLines sets how many of the most recent unhandled listener
+// lines UnhandledLines keeps (default 20). A negative n keeps none.
+func WithUnhandledLines(n int) Option {
+	return func(c *config) {
+		c.unhandledLines = n
+	}
+}
+
+// WithSentVerification checks the sent-messages storage (AT+CMGL="STO SENT")
+// after every successful send and logs a warning when the message is not
+// there. It is best-effort: a send is never failed by the check.
+func WithSentVerification() Option {
+	return func(c *config) {
+		c.verifySent = true
+	}
+}
+
+// WithCleanupSentMessages deletes each successfully sent message from the
+// sent-messages storage (AT+CMGL="STO SENT") when the modem stored a copy,
+// so a long-running sender does not slowly fill storage. The most recent
+// copy to the same number with the same text is deleted.
+func WithCleanupSentMessages() Option {
+	return func(c *config) {
+		c.cleanupSent = true
+	}
+}
+
+// WithPreserveWhitespace keeps leading and trailing whitespace in received
+// text-mode message bodies. Only the CR/LF line framing is removed; by
+// default each body line is trimmed.
+func WithPreserveWhitespace() Option {
+	return func(c *config) {
+		c.preserveWhitespace = true
+	}
+}
+
+// commandLineEnding returns the line ending for prompt-based commands
+func (s *SMSHandler) commandLineEnding() string {
+	if s.cfg.lineEnding == "" {
+		return "\r"
+	}
+	return s.cfg.lineEnding
+}
+
+// commandTerminator returns the terminator for ordinary commands
+func (s *SMSHandler) commandTerminator() string {
+	if s.cfg.cmdTerminator == "" {
+		return "\r\n"
+	}
+	return s.cfg.cmdTerminator
+}
+
+// composeTerminator returns the character written after a message body
+func (s *SMSHandler) composeTerminator() string {
+	if s.cfg.terminator == "" {
+		return ComposeSubmit
+	}
+	return s.cfg.terminator
+}
+
+// maxSegments returns the most segments one sent message may take
+func (s *SMSHandler) maxSegments() int {
+	if s.cfg.maxSegments <= 0 {
+		return DefaultMaxSegments
+	}
+	return s.cfg.maxSegments
+}
+
+// errorReporting returns the AT+CMEE mode to set during init
+func (s *SMSHandler) errorReporting() ErrorReporting {
+	if s.cfg.errorReporting != ErrorReportingVerbose {
+		return ErrorReportingNumeric
+	}
+	return ErrorReportingVerbose
+}
+
+// commandTimeout returns how long a command waits for its final result
+func (s *SMSHandler) commandTimeout() time.Duration {
+	if s.cfg.commandTimeout <= 0 {
+		return DefaultCommandTimeout
+	}
+	return s.cfg.commandTimeout
+}
+
+// readyAttempts returns how many times init probes the modem with AT
+func (s *SMSHandler) readyAttempts() int {
+	if s.cfg.readyAttempts <= 0 {
+		return DefaultReadyAttempts
+	}
+	return s.cfg.readyAttempts
+}
+
+// okOnlyGrace returns how long a send waits for its result line after a
+// bare OK
+func (s *SMSHandler) okOnlyGrace() time.Duration {
+	if s.cfg.okOnlyGrace <= 0 {
+		return DefaultOKOnlyGrace
+	}
+	return s.cfg.okOnlyGrace
+}
+
+// readyDelay returns the pause between AT probes
+func (s *SMSHandler) readyDelay() time.Duration {
+	if s.cfg.readyDelay <= 0 {
+		return DefaultReadyDelay
+	}
+	return s.cfg.readyDelay
+}
+
+// resetWait returns how long ResetModem waits for the modem to reboot
+func (s *SMSHandler) resetWait() time.Duration {
+	if s.cfg.resetWait <= 0 {
+		return DefaultResetWait
+	}
+	return s.cfg.resetWait
+}
+
+// pollInterval returns the configured listener read timeout, falling back to
+// the default for handlers built without a config
+func (s *SMSHandler) pollInterval() time.Duration {
+	if s.cfg.pollInterval <= 0 {
+		return DefaultPollInterval
+	}
+	return s.cfg.pollInterval
+}
+
+// receiveTimeout returns the configured +CMT body timeout, falling back to
+// the default for handlers built without a config
+func (s *SMSHandler) receiveTimeout() time.Duration {
+	if s.cfg.receiveTimeout <= 0 {
+		return DefaultReceiveTimeout
+	}
+	return s.cfg.receiveTimeout
+}
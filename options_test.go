@@ -0,0 +1,320 @@
+package smshandler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithPollInterval(t *testing.T) {
+	cfg := defaultConfig()
+	if cfg.pollInterval != DefaultPollInterval {
+		t.Errorf("default poll interval: got %v, want %v", cfg.pollInterval, DefaultPollInterval)
+	}
+
+	WithPollInterval(500 * time.Millisecond)(&cfg)
+	if cfg.pollInterval != 500*time.Millisecond {
+		t.Errorf("poll interval: got %v, want 500ms", cfg.pollInterval)
+	}
+
+	// Non-positive values keep the current setting
+	WithPollInterval(0)(&cfg)
+	if cfg.pollInterval != 500*time.Millisecond {
+		t.Errorf("poll interval after zero: got %v, want 500ms", cfg.pollInterval)
+	}
+
+	// Handlers built without a config fall back to the default
+	handler := &SMSHandler{}
+	if handler.pollInterval() != DefaultPollInterval {
+		t.Errorf("zero-config poll interval: got %v, want %v", handler.pollInterval(), DefaultPollInterval)
+	}
+}
+
+func TestReceiveTimeoutCollectsSlowBody(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	clk := newFakeClock()
+	handler.clk = clk
+	handler.cfg.receiveTimeout = 4 * time.Second
+
+	mockPort.SimulateIncoming("Part one\r\n")
+	received := make(chan SMS, 1)
+	go handler.handleCMTMessage(`+CMT: "+1234567890","","24/01/15,10:30:45+00"`, func(sms SMS) {
+		received <- sms
+	})
+	for clk.timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The second half of the body arrives after the old fixed 2s timeout
+	clk.Advance(2500 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond) // an expired timeout would be seen by now
+	mockPort.SimulateIncoming("Part two\r\n\r\n")
+
+	select {
+	case sms := <-received:
+		if sms.Message != "Part one\nPart two" {
+			t.Errorf("message: got %q", sms.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+	}
+}
+
+func TestCMTLengthHintCompletesEarly(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	// Body without a terminating blank line; the header says 5 characters
+	mockPort.SimulateIncoming("Hello\r\n")
+
+	var received []SMS
+	start := time.Now()
+	handler.handleCMTMessage(`+CMT: "+1234567890","","24/01/15,10:30:45+00",145,4,0,0,"+1987654321",145,5`, func(sms SMS) {
+		received = append(received, sms)
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, should complete once the body length is reached", elapsed)
+	}
+	if len(received) != 1 || received[0].Message != "Hello" {
+		t.Errorf("unexpected result: %+v", received)
+	}
+}
+
+// A header without the service centre fields still gives the length, and
+// the body may span lines
+func TestCMTLengthHintWithoutSMSC(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.SimulateIncoming("Hello\r\nthere\r\n")
+
+	var received []SMS
+	start := time.Now()
+	handler.handleCMTMessage(`+CMT: "+1234567890",,"24/01/15,10:30:45+00",145,4,0,0,11`, func(sms SMS) {
+		received = append(received, sms)
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %v, should complete once the body length is reached", elapsed)
+	}
+	if len(received) != 1 || received[0].Message != "Hello\nthere" {
+		t.Errorf("unexpected result: %+v", received)
+	}
+}
+
+func TestRecoverListener(t *testing.T) {
+	panicking := func(h *SMSHandler) (repanicked bool) {
+		defer func() {
+			repanicked = recover() != nil
+		}()
+		func() {
+			defer h.recoverListener()
+			panic("parse failure")
+		}()
+		return false
+	}
+
+	logger := &recordingLogger{}
+	handler := newMockHandler(NewMockSerialPort())
+	handler.cfg.logger = logger
+	if panicking(handler) {
+		t.Error("panic escaped the listener by default")
+	}
+	if len(logger.warns) == 0 || !strings.Contains(logger.warns[0], "parse failure") {
+		t.Errorf("panic not logged: %v", logger.warns)
+	}
+
+	handler.cfg.listenerPanics = true
+	if !panicking(handler) {
+		t.Error("WithListenerPanics did not re-raise the panic")
+	}
+}
+
+func TestWithReadBufferSize(t *testing.T) {
+	cfg := defaultConfig()
+	WithReadBufferSize(64 * 1024)(&cfg)
+
+	if got := newPortReader(NewMockSerialPort(), cfg.readBufferSize).Size(); got != 64*1024 {
+		t.Errorf("buffer size: got %d, want %d", got, 64*1024)
+	}
+	if got := newPortReader(NewMockSerialPort(), 0).Size(); got != 4096 {
+		t.Errorf("default buffer size: got %d, want 4096", got)
+	}
+}
+
+func TestWithComposeDelays(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want time.Duration
+	}{
+		{"default", nil, 2 * DefaultComposeDelay},
+		{"none", []Option{WithComposeDelays(0, 0)}, 0},
+		{"slow modem", []Option{WithComposeDelays(300*time.Millisecond, 500*time.Millisecond)}, 800 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPort := NewMockSerialPort()
+			handler := newMockHandler(mockPort)
+			for _, opt := range tt.opts {
+				opt(&handler.cfg)
+			}
+			clk := newFakeClock()
+			handler.clk = clk
+			mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+			mockPort.AddResponse("Paced\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+
+			start := clk.Now()
+			if err := handler.SendSMS("+1234567890", "Paced"); err != nil {
+				t.Fatalf("SendSMS failed: %v", err)
+			}
+			if waited := clk.Now().Sub(start); waited != tt.want {
+				t.Errorf("paced for %v, want %v", waited, tt.want)
+			}
+		})
+	}
+}
+
+// writeSizePort records the size of every write to the mock
+type writeSizePort struct {
+	*MockSerialPort
+	sizes []int
+}
+
+func (w *writeSizePort) Write(p []byte) (int, error) {
+	w.sizes = append(w.sizes, len(p))
+	return w.MockSerialPort.Write(p)
+}
+
+func TestWithWriteChunkSize(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	port := &writeSizePort{MockSerialPort: mockPort}
+	handler.port = port
+	WithWriteChunkSize(4, 20*time.Millisecond)(&handler.cfg)
+	clk := newFakeClock()
+	handler.clk = clk
+
+	// The response fires on the last chunk, which carries the terminator
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("rld\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+
+	start := clk.Now()
+	if err := handler.SendSMS("+1234567890", "Hello world"); err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+
+	// One write for the command, then "Hell", "o wo", "rld\x1A"
+	if want := []int{len(`AT+CMGS="+1234567890",145` + "\r"), 4, 4, 4}; !reflect.DeepEqual(port.sizes, want) {
+		t.Errorf("write sizes: got %v, want %v", port.sizes, want)
+	}
+	if !strings.HasSuffix(mockPort.GetWrittenData(), "\rHello world\x1A") {
+		t.Errorf("chunks did not reassemble: %q", mockPort.GetWrittenData())
+	}
+	if waited, want := clk.Now().Sub(start), 2*DefaultComposeDelay+2*20*time.Millisecond; waited != want {
+		t.Errorf("waited %v, want %v", waited, want)
+	}
+}
+
+func TestPreserveWhitespace(t *testing.T) {
+	listing := "+CMGL: 1,\"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\n  id=42  \r\n\r\nOK\r\n"
+	read := "+CMGR: \"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\n  id=42  \r\n\r\nOK\r\n"
+
+	for _, preserve := range []bool{false, true} {
+		want := "id=42"
+		if preserve {
+			want = "  id=42  "
+		}
+
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		handler.cfg.preserveWhitespace = preserve
+		mockPort.AddResponse(`AT+CMGL="ALL"`, listing)
+		mockPort.AddResponse("AT+CMGR=1", read)
+
+		messages, err := handler.ReadSMS()
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("preserve=%v: ReadSMS: %v, %d messages", preserve, err, len(messages))
+		}
+		if messages[0].Message != want {
+			t.Errorf("preserve=%v: listed body %q, want %q", preserve, messages[0].Message, want)
+		}
+
+		sms, err := handler.readSMSByIndex(1)
+		if err != nil {
+			t.Fatalf("preserve=%v: readSMSByIndex: %v", preserve, err)
+		}
+		if sms.Message != want {
+			t.Errorf("preserve=%v: read body %q, want %q", preserve, sms.Message, want)
+		}
+
+		mockPort.SimulateIncoming("  id=42  \r\n\r\n")
+		var received []SMS
+		handler.handleCMTMessage(`+CMT: "+1234567890","","24/01/15,10:30:45+00"`, func(sms SMS) {
+			received = append(received, sms)
+		})
+		if len(received) != 1 || received[0].Message != want {
+			t.Errorf("preserve=%v: delivered %+v, want body %q", preserve, received, want)
+		}
+	}
+}
+
+func TestCommandTerminators(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		cmd  string // bytes written for AT+CSQ
+		send string // bytes written before the send's prompt
+	}{
+		{"defaults", nil, "AT+CSQ\r\n", "AT+CMGS=\"+1234567890\",145\r"},
+		{"CR terminator", []Option{WithCommandTerminator("\r")}, "AT+CSQ\r", "AT+CMGS=\"+1234567890\",145\r"},
+		{"CRLF prompt line ending", []Option{WithCommandLineEnding("\r\n")}, "AT+CSQ\r\n", "AT+CMGS=\"+1234567890\",145\r\n"},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		for _, opt := range tt.opts {
+			opt(&handler.cfg)
+		}
+		mockPort.AddResponse("AT+CSQ", "+CSQ: 20,99\r\nOK\r\n")
+		mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+		mockPort.AddResponse("Hello\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+
+		if _, err := handler.sendATCommand("AT+CSQ"); err != nil {
+			t.Fatalf("%s: sendATCommand failed: %v", tt.name, err)
+		}
+		if written := mockPort.GetWrittenData(); written != tt.cmd {
+			t.Errorf("%s: command wrote %q, want %q", tt.name, written, tt.cmd)
+		}
+
+		if err := handler.SendSMS("+1234567890", "Hello"); err != nil {
+			t.Fatalf("%s: SendSMS failed: %v", tt.name, err)
+		}
+		if written := mockPort.GetWrittenData()[len(tt.cmd):]; written != tt.send+"Hello\x1A" {
+			t.Errorf("%s: send wrote %q, want %q", tt.name, written, tt.send+"Hello\x1A")
+		}
+	}
+}
+
+func TestConstructorOptions(t *testing.T) {
+	cfg := defaultConfig()
+	if cfg.baudRate != DefaultBaudRate {
+		t.Errorf("default baud rate: got %d, want %d", cfg.baudRate, DefaultBaudRate)
+	}
+
+	for _, opt := range []Option{WithBaudRate(9600), WithCommandTimeout(3 * time.Second), WithPIN("1234")} {
+		opt(&cfg)
+	}
+	if cfg.baudRate != 9600 || cfg.commandTimeout != 3*time.Second || cfg.pin != "1234" {
+		t.Errorf("options not applied: %+v", cfg)
+	}
+
+	handler := newMockHandler(NewMockSerialPort())
+	if handler.commandTimeout() != DefaultCommandTimeout {
+		t.Errorf("default command timeout: got %v", handler.commandTimeout())
+	}
+}
@@ -0,0 +1,84 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// cmsMemoryFull is +CMS ERROR 322, reported when message storage is full
+const cmsMemoryFull = 322
+
+// errNothingToOverwrite is returned by makeRoom when storage holds no read
+// message that could be deleted
+var errNothingToOverwrite = errors.New("storage is full and holds no read messages to delete")
+
+// isMemoryFull reports whether err is the modem's storage-full error
+func isMemoryFull(err error) bool {
+	var modemErr *ModemError
+	if !errors.As(err, &modemErr) || modemErr.Kind != "CMS" {
+		return false
+	}
+	return modemErr.Code == cmsMemoryFull || strings.EqualFold(modemErr.Text, "memory full")
+}
+
+// makeRoom deletes the oldest read message, every part of it for a joined
+// concatenated message, to free storage. Unread messages are never
+// touched. Messages with a parsed timestamp are ordered by it; when none
+// has one, the lowest index is taken as the oldest.
+func (s *SMSHandler) makeRoom() error {
+	messages, err := s.ReadSMSByStatus(StatusRead)
+	if err != nil {
+		return fmt.Errorf("failed to list read messages: %v", err)
+	}
+	if len(messages) == 0 {
+		return errNothingToOverwrite
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, b := messages[i].Timestamp, messages[j].Timestamp
+		if a.IsZero() != b.IsZero() {
+			return !a.IsZero()
+		}
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return messages[i].Index < messages[j].Index
+	})
+	oldest := messages[0]
+
+	indexes := oldest.PartIndexes
+	if len(indexes) == 0 {
+		indexes = []int{oldest.Index}
+	}
+	for _, index := range indexes {
+		if _, err := s.sendATCommandExpectOK(fmt.Sprintf("AT+CMGD=%d", index)); err != nil {
+			return fmt.Errorf("failed to delete SMS %d: %v", index, err)
+		}
+	}
+
+	s.logger().Printf("Storage full, deleted oldest read SMS %d from %s (%s)", oldest.Index, oldest.Sender, oldest.Date)
+	return nil
+}
+
+// keepRoom frees a slot after an incoming message was stored, when
+// WithOverwriteWhenFull is set and the receive storage has filled up, so
+// the next message can still be stored
+func (s *SMSHandler) keepRoom() {
+	if !s.cfg.overwriteWhenFull {
+		return
+	}
+
+	free, err := s.FreeSlots()
+	if err != nil {
+		s.logger().Printf("Failed to check free storage: %v", err)
+		return
+	}
+	if free > 0 {
+		return
+	}
+	if err := s.makeRoom(); err != nil {
+		s.logger().Printf("Failed to make room in storage: %v", err)
+	}
+}
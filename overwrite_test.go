@@ -0,0 +1,115 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+)
+
+const readListing = "+CMGL: 1,\"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nNewer\r\n" +
+	"+CMGL: 2,\"REC READ\",\"+1987654321\",,\"24/01/14,09:00:00+00\"\r\nOldest\r\n" +
+	"+CMGL: 3,\"REC READ\",\"+1555555555\",,\"24/01/16,08:00:00+00\"\r\nNewest\r\nOK\r\n"
+
+func TestMakeRoomDeletesOldestRead(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	logger := &recordingLogger{}
+	handler.cfg.logger = logger
+	mockPort.AddResponse(`AT+CMGL="REC READ"`, readListing)
+	mockPort.AddResponse("AT+CMGD=2", "OK\r\n")
+
+	if err := handler.makeRoom(); err != nil {
+		t.Fatalf("makeRoom failed: %v", err)
+	}
+
+	written := mockPort.GetWrittenData()
+	if strings.Count(written, "AT+CMGD") != 1 || !strings.Contains(written, "AT+CMGD=2") {
+		t.Errorf("expected only SMS 2 deleted, wrote %q", written)
+	}
+	if strings.Contains(written, "ALL") || strings.Contains(written, "UNREAD") {
+		t.Errorf("listed more than read messages: %q", written)
+	}
+	if len(logger.warns) != 1 || !strings.Contains(logger.warns[0], "+1987654321") {
+		t.Errorf("overwrite not logged: %q", logger.warns)
+	}
+
+	mockPort = NewMockSerialPort()
+	handler = newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGL="REC READ"`, "OK\r\n")
+	if err := handler.makeRoom(); err != errNothingToOverwrite {
+		t.Errorf("only unread messages: got %v, want errNothingToOverwrite", err)
+	}
+}
+
+// fullStoragePort rejects writes to storage until a message is deleted
+type fullStoragePort struct {
+	*MockSerialPort
+	body string
+}
+
+func (f *fullStoragePort) Write(p []byte) (int, error) {
+	if strings.HasPrefix(string(p), "AT+CMGD=") {
+		f.AddResponse(f.body, "\r\n+CMGW: 2\r\nOK\r\n")
+	}
+	return f.MockSerialPort.Write(p)
+}
+
+func TestWriteSMSOverwriteWhenFull(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.port = &fullStoragePort{MockSerialPort: mockPort, body: "Draft\x1A"}
+	mockPort.AddResponse(`AT+CMGW="+1234567890"`, "\r\n> ")
+	mockPort.AddResponse("Draft\x1A", "\r\n+CMS ERROR: 322\r\n")
+	mockPort.AddResponse(`AT+CMGL="REC READ"`, readListing)
+	mockPort.AddResponse("AT+CMGD=2", "OK\r\n")
+
+	if _, err := handler.WriteSMS("+1234567890", "Draft"); !isMemoryFull(err) {
+		t.Fatalf("without the option: expected storage full error, got %v", err)
+	}
+
+	handler.cfg.overwriteWhenFull = true
+	index, err := handler.WriteSMS("+1234567890", "Draft")
+	if err != nil {
+		t.Fatalf("WriteSMS failed: %v", err)
+	}
+	if index != 2 {
+		t.Errorf("index: got %d, want 2", index)
+	}
+}
+
+func TestKeepRoomAfterIncoming(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.overwriteWhenFull = true
+	mockPort.AddResponse("AT+CMGR=4", "+CMGR: \"REC UNREAD\",\"+1234567890\",,\"24/01/17,10:30:45+00\"\r\nHello\r\nOK\r\n")
+	mockPort.AddResponse("AT+CPMS?", "+CPMS: \"SM\",4,4,\"SM\",4,4,\"SM\",4,4\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CMGL="REC READ"`, readListing)
+	mockPort.AddResponse("AT+CMGD=2", "OK\r\n")
+
+	var received []SMS
+	handler.handleCMTIMessage(`+CMTI: "SM",4`, func(sms SMS) {
+		received = append(received, sms)
+	})
+
+	if len(received) != 1 || received[0].Message != "Hello" {
+		t.Errorf("message not delivered: %+v", received)
+	}
+	if !strings.Contains(mockPort.GetWrittenData(), "AT+CMGD=2") {
+		t.Error("oldest read message not deleted when storage was full")
+	}
+}
+
+func TestListenerKeepsRoom(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	defer handler.Close()
+	handler.cfg.overwriteWhenFull = true
+	mockPort.AddResponse("AT+CMGR=4", "+CMGR: \"REC UNREAD\",\"+1234567890\",,\"24/01/17,10:30:45+00\"\r\nHello\r\nOK\r\n")
+	mockPort.AddResponse("AT+CPMS?", "+CPMS: \"SM\",4,4,\"SM\",4,4,\"SM\",4,4\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CMGL="REC READ"`, readListing)
+	mockPort.AddResponse("AT+CMGD=2", "OK\r\n")
+
+	if sms := listenOnce(t, handler, mockPort, "+CMTI: \"SM\",4\r\n"); sms.Message != "Hello" {
+		t.Errorf("got %+v", sms)
+	}
+	waitForWritten(t, mockPort, "AT+CMGD=2")
+}
@@ -0,0 +1,450 @@
+package smshandler
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Encoding is the alphabet a message body was sent in, as given by its
+// data coding scheme
+type Encoding string
+
+const (
+	// EncodingGSM7 is the GSM 03.38 7-bit default alphabet
+	EncodingGSM7 Encoding = "gsm7"
+	// EncodingUCS2 is 16-bit UCS2, used for text outside the GSM alphabet
+	EncodingUCS2 Encoding = "ucs2"
+	// Encoding8Bit is binary data
+	Encoding8Bit Encoding = "8bit"
+)
+
+// Single-message size limits for each encoding
+const (
+	maxGSM7Septets = 160
+	maxUDOctets    = 140
+)
+
+// Message type indicator values in the first octet of a TPDU
+const (
+	mtiDeliver = 0x00
+	mtiSubmit  = 0x01
+)
+
+// Type-of-number bits of a type-of-address octet
+const (
+	tonMask          = 0x70
+	tonInternational = 0x10
+	tonAlphanumeric  = 0x50
+)
+
+// firstOctetUDHI is set when the user data starts with a header
+const firstOctetUDHI = 0x40
+
+// Data coding schemes in the general group, without message class
+const (
+	dcsGSM7 = 0x00
+	dcs8Bit = 0x04
+	dcsUCS2 = 0x08
+)
+
+// concatInfo describes one part of a concatenated message
+type concatInfo struct {
+	ref   int
+	total int
+	seq   int
+}
+
+// valid reports whether the header describes a real multipart message
+func (c concatInfo) valid() bool {
+	return c.total > 1 && c.seq >= 1 && c.seq <= c.total
+}
+
+// pduReader walks a TPDU, recording the first out-of-range read instead of
+// panicking on truncated input
+type pduReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *pduReader) next(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("PDU truncated at octet %d", r.pos)
+		return make([]byte, n)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *pduReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *pduReader) rest() []byte {
+	if r.err != nil {
+		return nil
+	}
+	b := r.data[r.pos:]
+	r.pos = len(r.data)
+	return b
+}
+
+// address reads a length-prefixed address field as used for TP-OA and TP-DA
+func (r *pduReader) address() string {
+	digits := int(r.byte())
+	toa := r.byte()
+	return decodeAddress(r.next((digits+1)/2), digits, toa)
+}
+
+// decodePDU decodes a hex SMS-DELIVER or stored SMS-SUBMIT PDU, as listed
+// by the modem in PDU mode, into an SMS. The returned concatInfo is valid
+// only when the message is one part of a concatenated message.
+func decodePDU(pduHex string) (SMS, concatInfo, error) {
+	var sms SMS
+
+	data, err := hex.DecodeString(strings.TrimSpace(pduHex))
+	if err != nil {
+		return sms, concatInfo{}, fmt.Errorf("invalid PDU hex: %v", err)
+	}
+
+	r := &pduReader{data: data}
+	r.next(int(r.byte())) // SMSC address
+	fo := r.byte()
+
+	var dcs byte
+	switch fo & 0x03 {
+	case mtiDeliver:
+		sms.Sender = r.address()
+		r.byte() // TP-PID
+		dcs = r.byte()
+		sms.Date = decodeSCTS(r.next(7))
+	case mtiSubmit:
+		r.byte() // TP-MR
+		sms.Sender = r.address()
+		r.byte() // TP-PID
+		dcs = r.byte()
+		switch (fo >> 3) & 0x03 {
+		case 2:
+			r.next(1) // relative validity period
+		case 1, 3:
+			r.next(7) // enhanced or absolute validity period
+		}
+	default:
+		return sms, concatInfo{}, fmt.Errorf("unsupported PDU type %d", fo&0x03)
+	}
+
+	udl := int(r.byte())
+	ud := r.rest()
+	if r.err != nil {
+		return sms, concatInfo{}, r.err
+	}
+
+	sms.Encoding = dcsEncoding(dcs)
+	sms.Class = dcsClass(dcs)
+
+	var header []byte
+	if sms.Encoding == EncodingGSM7 {
+		septets := unpackSeptets(ud, udl, 0)
+		if fo&firstOctetUDHI != 0 && len(ud) > 0 {
+			header = userDataHeader(ud)
+			skip := (len(header)*8 + 6) / 7
+			if skip > len(septets) {
+				skip = len(septets)
+			}
+			septets = septets[skip:]
+		}
+		sms.Message = decodeGSM7(septets)
+	} else {
+		if udl < len(ud) {
+			ud = ud[:udl]
+		}
+		if fo&firstOctetUDHI != 0 && len(ud) > 0 {
+			header = userDataHeader(ud)
+			ud = ud[len(header):]
+		}
+		if sms.Encoding == EncodingUCS2 {
+			sms.Message = decodeUCS2(ud)
+		} else {
+			sms.Data = append([]byte(nil), ud...)
+			sms.Message = string(ud)
+		}
+	}
+
+	sms.Timestamp, _ = parseSMSTimestamp(sms.Date)
+	if isAlphanumericAddress(sms.Sender) {
+		sms.SenderName = sms.Sender
+	}
+	sms.Port = parsePortHeader(header)
+	return sms, parseConcatHeader(header), nil
+}
+
+// userDataHeader returns the header at the start of ud, including its
+// length octet, clamped to the available data
+func userDataHeader(ud []byte) []byte {
+	n := 1 + int(ud[0])
+	if n > len(ud) {
+		n = len(ud)
+	}
+	return ud[:n]
+}
+
+// headerElements calls fn for each information element of a user data
+// header until fn returns false
+func headerElements(header []byte, fn func(id byte, ie []byte) bool) {
+	if len(header) == 0 {
+		return
+	}
+
+	ies := header[1:]
+	for len(ies) >= 2 {
+		id, n := ies[0], int(ies[1])
+		if 2+n > len(ies) {
+			return
+		}
+		if !fn(id, ies[2:2+n]) {
+			return
+		}
+		ies = ies[2+n:]
+	}
+}
+
+// parseConcatHeader finds the concatenation element (8- or 16-bit
+// reference) in a user data header
+func parseConcatHeader(header []byte) concatInfo {
+	var info concatInfo
+	headerElements(header, func(id byte, ie []byte) bool {
+		switch {
+		case id == 0x00 && len(ie) == 3:
+			info = concatInfo{ref: int(ie[0]), total: int(ie[1]), seq: int(ie[2])}
+		case id == 0x08 && len(ie) == 4:
+			info = concatInfo{ref: int(ie[0])<<8 | int(ie[1]), total: int(ie[2]), seq: int(ie[3])}
+		default:
+			return true
+		}
+		return false
+	})
+	return info
+}
+
+// parsePortHeader finds the destination port of the application port
+// addressing element (8- or 16-bit) in a user data header
+func parsePortHeader(header []byte) uint16 {
+	var port uint16
+	headerElements(header, func(id byte, ie []byte) bool {
+		switch {
+		case id == 0x04 && len(ie) == 2:
+			port = uint16(ie[0])
+		case id == 0x05 && len(ie) == 4:
+			port = uint16(ie[0])<<8 | uint16(ie[1])
+		default:
+			return true
+		}
+		return false
+	})
+	return port
+}
+
+// dcsEncoding extracts the alphabet from a TP-DCS octet
+func dcsEncoding(dcs byte) Encoding {
+	switch {
+	case dcs&0x80 == 0:
+		// General data coding, with or without automatic deletion
+		switch (dcs >> 2) & 0x03 {
+		case 1:
+			return Encoding8Bit
+		case 2:
+			return EncodingUCS2
+		}
+	case dcs&0xF0 == 0xE0:
+		return EncodingUCS2
+	case dcs&0xF0 == 0xF0:
+		if dcs&0x04 != 0 {
+			return Encoding8Bit
+		}
+	}
+	return EncodingGSM7
+}
+
+// decodeAddress decodes the semi-octet or alphanumeric digits of an address
+func decodeAddress(b []byte, digits int, toa byte) string {
+	if toa&tonMask == tonAlphanumeric {
+		return decodeGSM7(unpackSeptets(b, digits*4/7, 0))
+	}
+
+	const semiOctets = "0123456789*#abc"
+	var out strings.Builder
+	if toa&tonMask == tonInternational {
+		out.WriteByte('+')
+	}
+	for i := 0; i < digits && i/2 < len(b); i++ {
+		nibble := b[i/2] & 0x0F
+		if i%2 == 1 {
+			nibble = b[i/2] >> 4
+		}
+		if nibble == 0x0F {
+			break
+		}
+		out.WriteByte(semiOctets[nibble])
+	}
+	return out.String()
+}
+
+// encodeAddress encodes a destination number as a TP-DA field
+func encodeAddress(number string, t AddressType) ([]byte, error) {
+	toa := t.resolve(number)
+	if toa == 0 {
+		toa = AddressTypeUnknown
+	}
+
+	digits := strings.TrimPrefix(number, "+")
+	if digits == "" {
+		return nil, errors.New("empty destination number")
+	}
+
+	out := []byte{byte(len(digits)), byte(toa)}
+	for i := 0; i < len(digits); i += 2 {
+		lo, err := semiOctet(digits[i])
+		if err != nil {
+			return nil, err
+		}
+		hi := byte(0x0F)
+		if i+1 < len(digits) {
+			if hi, err = semiOctet(digits[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, hi<<4|lo)
+	}
+	return out, nil
+}
+
+func semiOctet(c byte) (byte, error) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', nil
+	case c == '*':
+		return 0x0A, nil
+	case c == '#':
+		return 0x0B, nil
+	}
+	return 0, fmt.Errorf("invalid character %q in destination number", c)
+}
+
+// decodeSCTS converts a service centre timestamp to the text-mode format
+// "yy/MM/dd,hh:mm:ss±zz" so PDU and text messages carry the same Date
+func decodeSCTS(b []byte) string {
+	bcd := func(v byte) int { return int(v&0x0F)*10 + int(v>>4) }
+
+	sign := "+"
+	if b[6]&0x08 != 0 {
+		sign = "-"
+	}
+	quarters := int(b[6]&0x07)*10 + int(b[6]>>4)
+
+	return fmt.Sprintf("%02d/%02d/%02d,%02d:%02d:%02d%s%02d",
+		bcd(b[0]), bcd(b[1]), bcd(b[2]), bcd(b[3]), bcd(b[4]), bcd(b[5]), sign, quarters)
+}
+
+// decodeUCS2 decodes big-endian UTF-16 text
+func decodeUCS2(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeUCS2 encodes text as big-endian UTF-16
+func encodeUCS2(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
+
+// encodeSubmitPDU builds a single-part SMS-SUBMIT PDU for message in the
+// given encoding, or the GSM 7-bit alphabet when possible and UCS2
+// otherwise when enc is empty. It returns the hex PDU together with the
+// TPDU length AT+CMGS and AT+CMGW expect (the PDU length without the SMSC
+// field). vp is the relative TP-VP value.
+func encodeSubmitPDU(number, message string, t AddressType, enc Encoding, vp byte) (string, int, error) {
+	enc, err := resolveEncoding(message, enc)
+	if err != nil {
+		return "", 0, err
+	}
+
+	da, err := encodeAddress(number, t)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if enc == EncodingGSM7 {
+		septets, _ := encodeGSM7(message)
+		if len(septets) > maxGSM7Septets {
+			return "", 0, fmt.Errorf("message is %d septets, a single PDU holds %d", len(septets), maxGSM7Septets)
+		}
+		pdu, length := submitPDU(da, false, dcsGSM7, vp, len(septets), packSeptets(septets, 0))
+		return pdu, length, nil
+	}
+
+	ud := encodeUCS2(message)
+	if len(ud) > maxUDOctets {
+		return "", 0, fmt.Errorf("message is %d UCS2 characters, a single PDU holds %d", len(ud)/2, maxUDOctets/2)
+	}
+	pdu, length := submitPDU(da, false, dcsUCS2, vp, len(ud), ud)
+	return pdu, length, nil
+}
+
+// submitPDU assembles an SMS-SUBMIT for the default SMSC with the relative
+// validity period vp and a modem-assigned reference. udl is the user data
+// length in septets for GSM 7-bit and in octets otherwise. It returns the
+// hex PDU and its TPDU length.
+func submitPDU(da []byte, udhi bool, dcs, vp byte, udl int, ud []byte) (string, int) {
+	fo := byte(FirstOctetSubmit | FirstOctetRelativeVP)
+	if udhi {
+		fo |= firstOctetUDHI
+	}
+
+	pdu := []byte{0x00, fo, 0x00}
+	pdu = append(pdu, da...)
+	pdu = append(pdu, 0x00, dcs, vp, byte(udl)) // TP-PID, TP-DCS, TP-VP, TP-UDL
+	pdu = append(pdu, ud...)
+	return strings.ToUpper(hex.EncodeToString(pdu)), len(pdu) - 1
+}
+
+// portHeader is the user data header addressing 16-bit application port
+// port, with the same port as the source
+func portHeader(port uint16) []byte {
+	hi, lo := byte(port>>8), byte(port)
+	return []byte{0x06, 0x05, 0x04, hi, lo, hi, lo}
+}
+
+// encodeBinaryPDU builds a single-part 8-bit SMS-SUBMIT PDU carrying data,
+// with an application port header when port is non-zero
+func encodeBinaryPDU(number string, data []byte, port uint16, t AddressType) (string, int, error) {
+	da, err := encodeAddress(number, t)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var ud []byte
+	if port != 0 {
+		ud = portHeader(port)
+	}
+	ud = append(ud, data...)
+	if len(ud) > maxUDOctets {
+		return "", 0, fmt.Errorf("binary message is %d octets, a single PDU holds %d", len(data), maxUDOctets-(len(ud)-len(data)))
+	}
+
+	pdu, length := submitPDU(da, port != 0, dcs8Bit, defaultValidityPeriod, len(ud), ud)
+	return pdu, length, nil
+}
@@ -0,0 +1,115 @@
+package smshandler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parsePDUList parses the response from AT+CMGL in PDU mode, where each
+// message is a "+CMGL: <index>,<stat>,[<alpha>],<length>" header followed by
+// the hex PDU. Parts of concatenated messages are joined; parts whose
+// siblings are missing from the listing are returned on their own.
+func (s *SMSHandler) parsePDUList(response string) []SMS {
+	var messages []SMS
+	parts := newReassembler()
+	lines := strings.Split(response, "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "+CMGL:") || i+1 >= len(lines) {
+			continue
+		}
+
+		fields := splitRespectingQuotes(strings.TrimPrefix(line, "+CMGL:"), ',')
+		if len(fields) < 3 {
+			s.logger().Printf("Error parsing SMS header: insufficient fields in %q", line)
+			continue
+		}
+
+		index, err := parseListIndex(fields[0])
+		if err != nil {
+			s.logger().Printf("Error parsing SMS header: %v", err)
+			continue
+		}
+
+		i++ // The PDU line belongs to this header
+		sms, info, err := decodePDU(lines[i])
+		if err != nil {
+			s.logger().Printf("Error decoding PDU for SMS %d: %v", index, err)
+			continue
+		}
+		sms.Index = index
+		sms.Status = parseMessageStatus(fields[1])
+
+		if !info.valid() {
+			messages = append(messages, sms)
+			continue
+		}
+		if joined, ok := parts.add(sms, info, s.clock().Now()); ok {
+			messages = append(messages, joined)
+		}
+	}
+
+	return append(messages, parts.flush()...)
+}
+
+// parsePDURead parses the response from AT+CMGR in PDU mode:
+// "+CMGR: <stat>,[<alpha>],<length>" followed by the hex PDU
+func parsePDURead(response string, index int) (SMS, concatInfo, error) {
+	lines := strings.Split(response, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "+CMGR:") {
+			continue
+		}
+		if i+1 >= len(lines) {
+			break
+		}
+
+		sms, info, err := decodePDU(lines[i+1])
+		if err != nil {
+			return SMS{}, concatInfo{}, fmt.Errorf("failed to parse SMS: %v", err)
+		}
+		fields := splitRespectingQuotes(strings.TrimPrefix(line, "+CMGR:"), ',')
+		if len(fields) > 0 {
+			sms.Status = parseMessageStatus(fields[0])
+		}
+		sms.Index = index
+		return sms, info, nil
+	}
+
+	return SMS{}, concatInfo{}, fmt.Errorf("failed to parse SMS")
+}
+
+// handleCMTPDU reads the PDU line that follows a PDU-mode +CMT header and
+// delivers the decoded message
+func (s *SMSHandler) handleCMTPDU(callback func(SMS)) {
+	s.readerMu.Lock()
+	defer s.readerMu.Unlock()
+
+	deadline := s.clock().Now().Add(s.receiveTimeout())
+	for s.clock().Now().Before(deadline) {
+		if err := s.port.SetReadTimeout(s.pollInterval()); err != nil {
+			s.logger().Printf("Error setting read timeout in handleCMTPDU: %v", err)
+			return
+		}
+		line, err := s.reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if err != nil {
+				s.clock().Sleep(10 * time.Millisecond)
+			}
+			continue
+		}
+
+		sms, info, err := decodePDU(line)
+		if err != nil {
+			s.logger().Printf("Error decoding incoming PDU: %v", err)
+			s.recordUnhandled(line)
+			return
+		}
+		s.assemble(sms, info, callback)
+		return
+	}
+}
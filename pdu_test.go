@@ -0,0 +1,155 @@
+package smshandler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeDeliverPDU(t *testing.T) {
+	sms, info, err := decodePDU("07911326040000F0040B911346610089F60000208062917314800CC8F71D14969741F977FD07")
+	if err != nil {
+		t.Fatalf("decodePDU failed: %v", err)
+	}
+
+	if sms.Sender != "+31641600986" {
+		t.Errorf("Sender: got %q", sms.Sender)
+	}
+	if sms.Message != "How are you?" {
+		t.Errorf("Message: got %q", sms.Message)
+	}
+	if sms.Date != "02/08/26,19:37:41+08" {
+		t.Errorf("Date: got %q", sms.Date)
+	}
+	if sms.Timestamp.IsZero() {
+		t.Error("Timestamp not parsed")
+	}
+	if sms.Encoding != EncodingGSM7 {
+		t.Errorf("Encoding: got %q", sms.Encoding)
+	}
+	if info.valid() {
+		t.Errorf("single message reported as concatenated: %+v", info)
+	}
+}
+
+func TestDecodePDUAlphanumericSender(t *testing.T) {
+	// Originator "VERIFY" (alphanumeric, 11 semi-octets) with body "Hi"
+	sms, _, err := decodePDU("00040BD0D6A23469CC02000042105101035400" + "02C834")
+	if err != nil {
+		t.Fatalf("decodePDU failed: %v", err)
+	}
+	if sms.Sender != "VERIFY" || sms.SenderName != "VERIFY" {
+		t.Errorf("got Sender %q SenderName %q", sms.Sender, sms.SenderName)
+	}
+	if sms.Message != "Hi" {
+		t.Errorf("Message: got %q", sms.Message)
+	}
+}
+
+func TestDecodePDUTruncated(t *testing.T) {
+	if _, _, err := decodePDU("07911326040000F0040B9113466100"); err == nil {
+		t.Error("expected an error for a truncated PDU")
+	}
+}
+
+func TestEncodeSubmitPDU(t *testing.T) {
+	pdu, length, err := encodeSubmitPDU("+46708251358", "hellohello", AddressTypeAuto, "", defaultValidityPeriod)
+	if err != nil {
+		t.Fatalf("encodeSubmitPDU failed: %v", err)
+	}
+	if want := "0011000B916407281553F80000A70AE8329BFD4697D9EC37"; pdu != want {
+		t.Errorf("pdu: got %s, want %s", pdu, want)
+	}
+	if length != 23 {
+		t.Errorf("length: got %d, want 23", length)
+	}
+
+	// Text outside the GSM alphabet switches to UCS2
+	pdu, _, err = encodeSubmitPDU("+46708251358", "Привет", AddressTypeAuto, "", defaultValidityPeriod)
+	if err != nil {
+		t.Fatalf("encodeSubmitPDU failed: %v", err)
+	}
+	if !strings.Contains(pdu, "0008A70C041F04400438043204350442") {
+		t.Errorf("unexpected UCS2 PDU %s", pdu)
+	}
+
+	if _, _, err := encodeSubmitPDU("+46708251358", strings.Repeat("a", 161), AddressTypeAuto, "", defaultValidityPeriod); err == nil {
+		t.Error("expected an error for a message longer than one PDU")
+	}
+}
+
+func TestGSM7RoundTrip(t *testing.T) {
+	text := "Price: 5€ [50% off] {now}"
+	septets, ok := encodeGSM7(text)
+	if !ok {
+		t.Fatal("text should be GSM 7-bit encodable")
+	}
+	for fill := 0; fill < 7; fill++ {
+		packed := packSeptets(septets, fill)
+		if got := decodeGSM7(unpackSeptets(packed, len(septets), fill)); got != text {
+			t.Errorf("fill %d: got %q", fill, got)
+		}
+	}
+	if len(gsm7Basic) != 128 {
+		t.Errorf("basic alphabet has %d characters", len(gsm7Basic))
+	}
+}
+
+// Two UCS2 parts of a concatenated message from +15551234567, reference 42
+const (
+	concatPart1 = "00440B915155214365F70008421051010354000C0500032A020100480065006C"
+	concatPart2 = "00440B915155214365F70008421051010354000A0500032A0202006C006F"
+)
+
+func TestParsePDUListJoinsConcatenatedParts(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	handler.cfg.mode = ModePDU
+
+	response := "+CMGL: 3,1,,22\r\n" + concatPart2 + "\r\n" +
+		"+CMGL: 4,0,,38\r\n07911326040000F0040B911346610089F60000208062917314800CC8F71D14969741F977FD07\r\n" +
+		"+CMGL: 2,1,,24\r\n" + concatPart1 + "\r\nOK\r\n"
+
+	messages := handler.parsePDUList(response)
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d: %+v", len(messages), messages)
+	}
+
+	if messages[0].Message != "How are you?" || messages[0].Status != StatusUnread {
+		t.Errorf("unexpected first message %+v", messages[0])
+	}
+
+	joined := messages[1]
+	if joined.Message != "Hello" {
+		t.Errorf("joined message: got %q", joined.Message)
+	}
+	if joined.Index != 2 || !reflect.DeepEqual(joined.PartIndexes, []int{2, 3}) {
+		t.Errorf("got Index %d PartIndexes %v", joined.Index, joined.PartIndexes)
+	}
+	if joined.Encoding != EncodingUCS2 || joined.Sender != "+15551234567" {
+		t.Errorf("unexpected joined message %+v", joined)
+	}
+}
+
+func TestParsePDUListKeepsIncompleteParts(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+
+	messages := handler.parsePDUList("+CMGL: 2,1,,24\r\n" + concatPart1 + "\r\nOK\r\n")
+	if len(messages) != 1 || messages[0].Message != "Hel" || messages[0].PartIndexes != nil {
+		t.Errorf("expected the lone part as-is, got %+v", messages)
+	}
+}
+
+func TestReadSMSPDUMode(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.mode = ModePDU
+	mockPort.AddResponse("AT+CMGL=0", "+CMGL: 4,0,,38\r\n07911326040000F0040B911346610089F60000208062917314800CC8F71D14969741F977FD07\r\nOK\r\n")
+
+	messages, err := handler.ReadNewSMS()
+	if err != nil {
+		t.Fatalf("ReadNewSMS failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Index != 4 || messages[0].Message != "How are you?" {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
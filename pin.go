@@ -0,0 +1,32 @@
+package smshandler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unlockSIM enters pin if AT+CPIN? reports the SIM is waiting for its PIN.
+// A SIM that is already unlocked is left alone; one that wants anything
+// else, such as its PUK, is an error, as retrying the PIN could lock it.
+func (s *SMSHandler) unlockSIM(pin string) error {
+	response, err := s.sendATCommandExpectOK("AT+CPIN?")
+	if err != nil {
+		return fmt.Errorf("failed to query SIM PIN state: %v", err)
+	}
+
+	fields, ok := resultFields(response, "+CPIN:")
+	if !ok || len(fields) == 0 {
+		return fmt.Errorf("failed to query SIM PIN state: unexpected response %q", response)
+	}
+	switch state := strings.ToUpper(fields[0]); state {
+	case "READY":
+		return nil
+	case "SIM PIN":
+		if _, err := s.sendATCommandExpectOK(fmt.Sprintf("AT+CPIN=\"%s\"", pin)); err != nil {
+			return fmt.Errorf("failed to enter SIM PIN: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("SIM is waiting for %s, not its PIN", state)
+	}
+}
@@ -0,0 +1,40 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnlockSIM(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   string
+		entry   string // response to AT+CPIN="1234", empty if it must not be sent
+		wantErr string
+	}{
+		{"already unlocked", "READY", "", ""},
+		{"needs PIN", "SIM PIN", "OK\r\n", ""},
+		{"wrong PIN", "SIM PIN", "+CME ERROR: 16\r\n", "failed to enter SIM PIN"},
+		{"needs PUK", "SIM PUK", "", "SIM PUK"},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		mockPort.AddResponse("AT+CPIN?", "+CPIN: "+tt.state+"\r\nOK\r\n")
+		mockPort.AddResponse(`AT+CPIN="1234"`, tt.entry)
+
+		err := handler.unlockSIM("1234")
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: unlockSIM failed: %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: got %v, want an error mentioning %q", tt.name, err, tt.wantErr)
+		}
+
+		sent := strings.Contains(mockPort.GetWrittenData(), `AT+CPIN="1234"`)
+		if sent != (tt.entry != "") {
+			t.Errorf("%s: PIN sent = %v", tt.name, sent)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package smshandler
+
+import (
+	"fmt"
+
+	"go.bug.st/serial"
+	"go.bug.st/serial/enumerator"
+)
+
+// PortInfo describes a serial port found by ListPortsDetailed
+type PortInfo struct {
+	Name  string
+	IsUSB bool
+	// VID and PID are the USB vendor and product IDs in hex, when known
+	VID string
+	PID string
+	// SerialNumber and Product are reported by the OS and may be empty
+	SerialNumber string
+	Product      string
+}
+
+// ListPorts returns the names of the serial ports on the system, such as
+// "/dev/ttyUSB2" or "COM3", as candidates for NewSMSHandler
+func ListPorts() ([]string, error) {
+	ports, err := serial.GetPortsList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list serial ports: %v", err)
+	}
+	return ports, nil
+}
+
+// ListPortsDetailed returns the serial ports with USB details where the OS
+// provides them. Modems usually expose several ports with the same VID and
+// PID; only one of them accepts AT commands for SMS.
+func ListPortsDetailed() ([]PortInfo, error) {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list serial ports: %v", err)
+	}
+
+	infos := make([]PortInfo, 0, len(ports))
+	for _, p := range ports {
+		infos = append(infos, PortInfo{
+			Name:         p.Name,
+			IsUSB:        p.IsUSB,
+			VID:          p.VID,
+			PID:          p.PID,
+			SerialNumber: p.SerialNumber,
+			Product:      p.Product,
+		})
+	}
+	return infos, nil
+}
@@ -0,0 +1,54 @@
+package smshandler
+
+// SendStage is a step of a send reported through WithProgress
+type SendStage string
+
+// Send stages, in the order each part of a message goes through them
+const (
+	// StagePromptReceived is reported when the modem shows the '>' prompt
+	StagePromptReceived SendStage = "prompt received"
+	// StageBodyWritten is reported once the body and Ctrl+Z are written
+	StageBodyWritten SendStage = "body written"
+	// StageAwaitingConfirmation is reported while waiting for +CMGS
+	StageAwaitingConfirmation SendStage = "awaiting confirmation"
+	// StagePartSent is reported when the modem confirms the part was sent
+	StagePartSent SendStage = "part sent"
+)
+
+// SendProgress is one progress event of a send. Part counts from 1; a
+// message that fits one SMS is part 1 of 1.
+type SendProgress struct {
+	Stage SendStage
+	Part  int
+	Total int
+}
+
+// WithProgress calls fn at each stage of the send, for every part of a
+// concatenated message, so an interactive tool can show feedback during a
+// slow send. fn runs on the sending goroutine and should return quickly.
+func WithProgress(fn func(SendProgress)) SendOption {
+	return func(o *sendOptions) {
+		o.progress = fn
+	}
+}
+
+// composeProgress receives the stages of one composeMessage call; a nil
+// composeProgress reports nothing
+type composeProgress func(SendStage)
+
+func (p composeProgress) report(stage SendStage) {
+	if p != nil {
+		p(stage)
+	}
+}
+
+// progressFor returns the progress reporter for part of total, or nil when
+// no WithProgress callback was given
+func (o sendOptions) progressFor(part, total int) composeProgress {
+	if o.progress == nil {
+		return nil
+	}
+	return func(stage SendStage) {
+		o.progress(SendProgress{Stage: stage, Part: part, Total: total})
+	}
+}
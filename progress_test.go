@@ -0,0 +1,61 @@
+package smshandler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSendProgress(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Hello\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+
+	var events []SendProgress
+	if err := handler.SendSMS("+1234567890", "Hello", WithProgress(func(p SendProgress) {
+		events = append(events, p)
+	})); err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+
+	var want []SendProgress
+	for _, stage := range []SendStage{StagePromptReceived, StageBodyWritten, StageAwaitingConfirmation, StagePartSent} {
+		want = append(want, SendProgress{Stage: stage, Part: 1, Total: 1})
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got %+v, want %+v", events, want)
+	}
+}
+
+func TestSendProgressMultipart(t *testing.T) {
+	message := strings.Repeat("0123456789", 20)
+	parts, err := encodeMultipartPDUs("+46708251358", message, AddressTypeAuto, EncodingGSM7, 1, defaultValidityPeriod)
+	if err != nil {
+		t.Fatalf("encodeMultipartPDUs failed: %v", err)
+	}
+
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.mode = ModePDU
+	for i, part := range parts {
+		mockPort.AddResponse(fmt.Sprintf("AT+CMGS=%d", part.length), "\r\n> ")
+		mockPort.AddResponse(part.pdu+"\x1A", fmt.Sprintf("\r\n+CMGS: %d\r\nOK\r\n", 10+i))
+	}
+
+	var sent []SendProgress
+	err = handler.SendSMS("+46708251358", message, WithProgress(func(p SendProgress) {
+		if p.Stage == StagePartSent {
+			sent = append(sent, p)
+		}
+	}))
+	if err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+
+	want := []SendProgress{{StagePartSent, 1, 2}, {StagePartSent, 2, 2}}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("got %+v, want %+v", sent, want)
+	}
+}
@@ -0,0 +1,111 @@
+package smshandler
+
+import "sync"
+
+// DropPolicy decides which message is discarded when the incoming queue of
+// ListenBuffered is full
+type DropPolicy int
+
+const (
+	// DropOldest discards the oldest queued message to make room
+	DropOldest DropPolicy = iota
+	// DropNewest discards the message that just arrived
+	DropNewest
+)
+
+// messageQueue is a bounded FIFO of received messages
+type messageQueue struct {
+	mu       sync.Mutex
+	items    []SMS
+	capacity int
+	policy   DropPolicy
+	dropped  uint64
+}
+
+func newMessageQueue(capacity int, policy DropPolicy) *messageQueue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &messageQueue{capacity: capacity, policy: policy}
+}
+
+func (q *messageQueue) push(sms SMS) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) >= q.capacity {
+		q.dropped++
+		if q.policy == DropNewest {
+			return
+		}
+		q.items = q.items[1:]
+	}
+	q.items = append(q.items, sms)
+}
+
+// poll removes and returns up to max messages, oldest first; max <= 0
+// returns everything queued
+func (q *messageQueue) poll(max int) []SMS {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := len(q.items)
+	if max > 0 && max < n {
+		n = max
+	}
+	if n == 0 {
+		return nil
+	}
+
+	out := make([]SMS, n)
+	copy(out, q.items)
+	q.items = q.items[n:]
+	return out
+}
+
+func (q *messageQueue) droppedCount() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// ListenBuffered starts the incoming SMS listener with messages collected
+// in an internal queue of the given capacity instead of passed to a
+// callback, for consumers that wake up periodically and poll with
+// PollIncoming. When the queue is full the policy decides which message is
+// dropped; DroppedIncoming counts them. Call it once, before polling.
+func (s *SMSHandler) ListenBuffered(capacity int, policy DropPolicy) {
+	q := newMessageQueue(capacity, policy)
+	s.queueMu.Lock()
+	s.queue = q
+	s.queueMu.Unlock()
+
+	s.ListenForIncomingSMS(q.push)
+}
+
+// PollIncoming returns up to max queued messages, oldest first, without
+// blocking. A max of zero or less returns everything queued. It returns nil
+// when nothing has arrived or ListenBuffered was not started.
+func (s *SMSHandler) PollIncoming(max int) []SMS {
+	q := s.incomingQueue()
+	if q == nil {
+		return nil
+	}
+	return q.poll(max)
+}
+
+// DroppedIncoming returns how many messages ListenBuffered has discarded
+// because the queue was full
+func (s *SMSHandler) DroppedIncoming() uint64 {
+	q := s.incomingQueue()
+	if q == nil {
+		return 0
+	}
+	return q.droppedCount()
+}
+
+func (s *SMSHandler) incomingQueue() *messageQueue {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	return s.queue
+}
@@ -0,0 +1,76 @@
+package smshandler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMessageQueueDropPolicies(t *testing.T) {
+	bodies := func(messages []SMS) []string {
+		var out []string
+		for _, m := range messages {
+			out = append(out, m.Message)
+		}
+		return out
+	}
+
+	oldest := newMessageQueue(2, DropOldest)
+	newest := newMessageQueue(2, DropNewest)
+	for _, body := range []string{"a", "b", "c"} {
+		oldest.push(SMS{Message: body})
+		newest.push(SMS{Message: body})
+	}
+
+	if got := bodies(oldest.poll(0)); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("DropOldest: got %v", got)
+	}
+	if got := bodies(newest.poll(0)); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("DropNewest: got %v", got)
+	}
+	if oldest.droppedCount() != 1 || newest.droppedCount() != 1 {
+		t.Errorf("dropped: got %d and %d, want 1", oldest.droppedCount(), newest.droppedCount())
+	}
+}
+
+func TestMessageQueuePollMax(t *testing.T) {
+	q := newMessageQueue(10, DropOldest)
+	for _, body := range []string{"a", "b", "c"} {
+		q.push(SMS{Message: body})
+	}
+
+	if got := q.poll(2); len(got) != 2 || got[0].Message != "a" {
+		t.Errorf("first poll: got %+v", got)
+	}
+	if got := q.poll(2); len(got) != 1 || got[0].Message != "c" {
+		t.Errorf("second poll: got %+v", got)
+	}
+	if got := q.poll(2); got != nil {
+		t.Errorf("empty poll: got %+v", got)
+	}
+}
+
+func TestListenBuffered(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	if got := handler.PollIncoming(0); got != nil {
+		t.Errorf("poll before ListenBuffered: got %+v", got)
+	}
+
+	mockPort.SimulateIncoming("+CMT: \"+15551234567\",\"\",\"24/01/15,10:30:45+00\"\r\nHello\r\n\r\n")
+	handler.ListenBuffered(4, DropOldest)
+	defer handler.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := handler.PollIncoming(0); len(got) > 0 {
+			if got[0].Message != "Hello" {
+				t.Errorf("got %+v", got[0])
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("message never reached the queue")
+}
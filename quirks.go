@@ -0,0 +1,114 @@
+package smshandler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// QuirkProfile describes model-specific settings applied during modem init
+type QuirkProfile struct {
+	// CNMI is the AT+CNMI argument list known to work on the model, for
+	// example "2,1,0,0,0". Empty means try the generic fallbacks.
+	CNMI string
+	// Storage is the preferred message storage area ("SM", "ME" or "MT").
+	// Empty means the SIM card ("SM").
+	Storage string
+	// UCS2Hex indicates the modem delivers UCS2 message bodies as hex
+	// strings even when the GSM character set is selected. Text-mode bodies
+	// that look like hex UCS2 are then decoded even without a DCS to confirm.
+	UCS2Hex bool
+}
+
+var (
+	quirkMu       sync.RWMutex
+	quirkProfiles = map[string]QuirkProfile{
+		"SIM7600": {CNMI: "2,1,0,0,0", Storage: "SM"},
+		"EC25":    {CNMI: "2,1,0,0,0", Storage: "ME"},
+		"E3372":   {CNMI: "2,1,0,2,0", Storage: "SM", UCS2Hex: true},
+	}
+)
+
+// RegisterQuirkProfile registers or replaces the quirk profile for a modem
+// model. The model is matched case-insensitively against the AT+CGMM response,
+// either exactly or as a substring, so "SIM7600" matches "SIMCOM_SIM7600G-H".
+func RegisterQuirkProfile(model string, profile QuirkProfile) {
+	quirkMu.Lock()
+	defer quirkMu.Unlock()
+	quirkProfiles[strings.ToUpper(strings.TrimSpace(model))] = profile
+}
+
+// lookupQuirkProfile finds the profile for a reported model. When several
+// registered names match, the longest (most specific) one wins.
+func lookupQuirkProfile(model string) (QuirkProfile, bool) {
+	model = strings.ToUpper(strings.TrimSpace(model))
+	if model == "" {
+		return QuirkProfile{}, false
+	}
+
+	quirkMu.RLock()
+	defer quirkMu.RUnlock()
+
+	if profile, ok := quirkProfiles[model]; ok {
+		return profile, true
+	}
+
+	best := ""
+	for name := range quirkProfiles {
+		if strings.Contains(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return QuirkProfile{}, false
+	}
+	return quirkProfiles[best], true
+}
+
+// DetectModemQuirks reads the modem model with AT+CGMM, records it on the
+// handler and returns the matching quirk profile. Unknown models yield the
+// zero profile, which selects the generic init behavior.
+func (s *SMSHandler) DetectModemQuirks() (QuirkProfile, error) {
+	response, err := s.sendATCommandExpectOK("AT+CGMM")
+	if err != nil {
+		return QuirkProfile{}, fmt.Errorf("failed to read modem model: %v", err)
+	}
+
+	model := parseModelResponse(response)
+	profile, _ := lookupQuirkProfile(model)
+
+	s.quirksMu.Lock()
+	s.modemModel = model
+	s.quirks = profile
+	s.quirksMu.Unlock()
+	return profile, nil
+}
+
+// ModemModel returns the model reported by the modem during init, or an
+// empty string if it could not be determined
+func (s *SMSHandler) ModemModel() string {
+	s.quirksMu.RLock()
+	defer s.quirksMu.RUnlock()
+	return s.modemModel
+}
+
+// modemQuirks returns the quirk profile DetectModemQuirks last selected
+func (s *SMSHandler) modemQuirks() QuirkProfile {
+	s.quirksMu.RLock()
+	defer s.quirksMu.RUnlock()
+	return s.quirks
+}
+
+// parseModelResponse extracts the model name from an AT+CGMM response, which
+// is either a bare line or prefixed with "+CGMM:" depending on the vendor
+func parseModelResponse(response string) string {
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "OK" {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "+CGMM:"))
+		return strings.Trim(line, "\"")
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupQuirkProfile(t *testing.T) {
+	tests := []struct {
+		model   string
+		want    QuirkProfile
+		matched bool
+	}{
+		{"SIMCOM_SIM7600G-H", quirkProfiles["SIM7600"], true},
+		{"EC25", quirkProfiles["EC25"], true},
+		{"e3372h-153", quirkProfiles["E3372"], true},
+		{"UNKNOWN-MODEM", QuirkProfile{}, false},
+		{"", QuirkProfile{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := lookupQuirkProfile(tt.model)
+		if ok != tt.matched || got != tt.want {
+			t.Errorf("lookupQuirkProfile(%q): got %+v/%v, want %+v/%v", tt.model, got, ok, tt.want, tt.matched)
+		}
+	}
+}
+
+func TestInitModemAppliesQuirks(t *testing.T) {
+	RegisterQuirkProfile("TestModem-X1", QuirkProfile{CNMI: "2,2,0,0,0", Storage: "ME"})
+	defer func() {
+		quirkMu.Lock()
+		delete(quirkProfiles, "TESTMODEM-X1")
+		quirkMu.Unlock()
+	}()
+
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CGMM", "TESTMODEM-X1\r\nOK\r\n")
+	mockPort.AddResponse("AT+CNMI=2,2,0,0,0", "OK\r\n")
+
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+
+	if handler.ModemModel() != "TESTMODEM-X1" {
+		t.Errorf("model: got %q", handler.ModemModel())
+	}
+
+	written := mockPort.GetWrittenData()
+	if !strings.Contains(written, `AT+CPMS="ME","ME","ME"`) {
+		t.Error("quirk storage not applied")
+	}
+	if !strings.Contains(written, "AT+CNMI=2,2,0,0,0") {
+		t.Error("quirk CNMI not applied")
+	}
+	if strings.Contains(written, "AT+CNMI=1,2,0,1,0") {
+		t.Error("generic CNMI sent despite quirk CNMI succeeding")
+	}
+}
+
+func TestInitModemUnknownModelUsesGenericSettings(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CGMM", "SOMETHING-ELSE\r\nOK\r\n")
+
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+
+	written := mockPort.GetWrittenData()
+	if !strings.Contains(written, `AT+CPMS="SM","SM","SM"`) {
+		t.Error("generic storage not applied")
+	}
+	if !strings.Contains(written, "AT+CNMI=1,2,0,1,0") {
+		t.Error("generic CNMI not applied")
+	}
+}
+
+// Detecting quirks again, as Reconnect does, is safe while the listener
+// decodes messages with the current profile; run with -race
+func TestDetectModemQuirksWhileDecoding(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CGMM", "E3372\r\nOK\r\n")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			handler.decodeTextBody(ucs2HexBody, -1)
+		}
+	}()
+	if _, err := handler.DetectModemQuirks(); err != nil {
+		t.Fatalf("DetectModemQuirks failed: %v", err)
+	}
+	<-done
+
+	if got := handler.decodeTextBody(ucs2HexBody, -1); got != "Hey 😀" {
+		t.Errorf("decoded %q with the E3372 profile", got)
+	}
+}
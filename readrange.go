@@ -0,0 +1,76 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// cmsInvalidIndex is +CMS ERROR 321, reported for an empty storage slot
+const cmsInvalidIndex = 321
+
+// errEmptySlot is returned by readMessageByIndex for a slot with no message
+var errEmptySlot = errors.New("no message at this index")
+
+// ReadRangeError reports the indexes ReadSMSRange could not read. The
+// messages that were read are still returned alongside it.
+type ReadRangeError struct {
+	// Errors maps each failed storage index to its error
+	Errors map[int]error
+}
+
+func (e *ReadRangeError) Error() string {
+	indexes := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	details := make([]string, len(indexes))
+	for i, index := range indexes {
+		details[i] = fmt.Sprintf("index %d: %v", index, e.Errors[index])
+	}
+	return fmt.Sprintf("failed to read %d messages: %s", len(indexes), strings.Join(details, "; "))
+}
+
+// ReadSMSRange reads the messages at storage indexes from through to
+// (inclusive) with AT+CMGR, for example everything after the last index an
+// application processed. Empty slots are skipped. If some indexes fail,
+// the messages that were read are returned together with a
+// *ReadRangeError. In PDU mode, parts of a concatenated message found in
+// the range are joined.
+func (s *SMSHandler) ReadSMSRange(from, to int) ([]SMS, error) {
+	if from < 0 || to < from {
+		return nil, fmt.Errorf("invalid index range %d-%d", from, to)
+	}
+
+	var messages []SMS
+	parts := newReassembler()
+	failed := make(map[int]error)
+	for index := from; index <= to; index++ {
+		sms, info, err := s.readMessageByIndex(index)
+		if errors.Is(err, errEmptySlot) {
+			continue
+		}
+		if err != nil {
+			failed[index] = err
+			continue
+		}
+
+		if !info.valid() {
+			messages = append(messages, sms)
+			continue
+		}
+		if joined, ok := parts.add(sms, info, s.clock().Now()); ok {
+			messages = append(messages, joined)
+		}
+	}
+	messages = append(messages, parts.flush()...)
+	sortByIndex(messages)
+
+	if len(failed) > 0 {
+		return messages, &ReadRangeError{Errors: failed}
+	}
+	return messages, nil
+}
@@ -0,0 +1,40 @@
+package smshandler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadSMSRange(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CMGR=1", "+CMGR: \"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nFirst\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMGR=2", "OK\r\n")
+	mockPort.AddResponse("AT+CMGR=3", "+CMS ERROR: 321\r\n")
+	mockPort.AddResponse("AT+CMGR=4", "+CMS ERROR: 500\r\n")
+	mockPort.AddResponse("AT+CMGR=5", "+CMGR: \"REC UNREAD\",\"+1234567890\",,\"24/01/15,10:35:00+00\"\r\nFifth\r\nOK\r\n")
+
+	messages, err := handler.ReadSMSRange(1, 5)
+
+	var rangeErr *ReadRangeError
+	if !errors.As(err, &rangeErr) {
+		t.Fatalf("expected a ReadRangeError, got %v", err)
+	}
+	if len(rangeErr.Errors) != 1 || rangeErr.Errors[4] == nil {
+		t.Errorf("expected only index 4 to fail, got %v", rangeErr.Errors)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].Index != 1 || messages[0].Message != "First" || messages[1].Index != 5 || messages[1].Message != "Fifth" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+}
+
+func TestReadSMSRangeInvalid(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	if _, err := handler.ReadSMSRange(5, 1); err == nil {
+		t.Error("expected an error for a reversed range")
+	}
+}
@@ -0,0 +1,201 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Defaults for ReconnectPolicy fields left at zero
+const (
+	DefaultReconnectAttempts = 5
+	DefaultReconnectBackoff  = time.Second
+	DefaultMaxReconnectDelay = time.Minute
+)
+
+// openSerialPort opens the modem's serial port; tests replace it
+var openSerialPort = serial.Open
+
+// ReconnectPolicy controls how the listener recovers from a fatal read
+// error, such as a USB modem being unplugged or resetting
+type ReconnectPolicy struct {
+	// MaxAttempts is how many times to try Reconnect before giving up
+	MaxAttempts int
+	// InitialBackoff is the wait before the first attempt; it doubles after
+	// each failure up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// OnEvent, if set, is called after every attempt and once more when
+	// the listener gives up
+	OnEvent func(ReconnectEvent)
+}
+
+// ReconnectEvent reports the outcome of one reconnect attempt
+type ReconnectEvent struct {
+	Attempt int
+	// Err is the attempt's error, nil when the handler reconnected
+	Err error
+	// GaveUp is set on the final event after the last attempt failed
+	GaveUp bool
+}
+
+// WithAutoReconnect makes the listener reconnect by itself after a fatal
+// read error: it calls Reconnect with exponential backoff and resumes
+// listening with the same callback once the modem is back. Without it the
+// listener keeps retrying reads on the broken port.
+func WithAutoReconnect(policy ReconnectPolicy) Option {
+	return func(c *config) {
+		if policy.MaxAttempts <= 0 {
+			policy.MaxAttempts = DefaultReconnectAttempts
+		}
+		if policy.InitialBackoff <= 0 {
+			policy.InitialBackoff = DefaultReconnectBackoff
+		}
+		if policy.MaxBackoff <= 0 {
+			policy.MaxBackoff = DefaultMaxReconnectDelay
+		}
+		c.reconnect = &policy
+	}
+}
+
+// openPort opens a serial port with the handler's line settings
+func openPort(portName string, baudRate int) (serial.Port, error) {
+	mode := &serial.Mode{
+		BaudRate: baudRate,
+		Parity:   serial.NoParity,
+		DataBits: 8,
+		StopBits: serial.OneStopBit}
+
+	port, err := openSerialPort(portName, mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open serial port: %v", err)
+	}
+	return port, nil
+}
+
+// currentPort returns the serial port for callers that do not hold readerMu
+func (s *SMSHandler) currentPort() serial.Port {
+	s.portMu.Lock()
+	defer s.portMu.Unlock()
+	return s.port
+}
+
+// Reconnect closes and reopens the serial port and runs the modem init
+// sequence again. Use it after the modem was reset or re-enumerated. It
+// does not restart a stopped listener. If Close is called while the port
+// is reopening, the handler stays closed and Reconnect returns ErrClosed.
+func (s *SMSHandler) Reconnect() error {
+	return s.reconnect(s.closeCount())
+}
+
+// closeCount returns how many times the handler has been closed
+func (s *SMSHandler) closeCount() int {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+	return s.closes
+}
+
+// reconnect does the work of Reconnect, refusing with ErrClosed once the
+// handler has been closed more than closes times
+func (s *SMSHandler) reconnect(closes int) error {
+	if s.portName == "" {
+		return errors.New("cannot reconnect: handler was not opened from a port name")
+	}
+
+	s.readerMu.Lock()
+	if err := s.port.Close(); err != nil {
+		s.logger().Debugf("closing port before reconnect: %v", err)
+	}
+	port, err := openPort(s.portName, s.baudRate)
+	if err != nil {
+		s.readerMu.Unlock()
+		return err
+	}
+	port = withTrace(port, s.cfg.trace)
+
+	// Checked and swapped in one step so a concurrent Close either stops the
+	// reconnect or closes the new port
+	s.lifecycleMu.Lock()
+	if s.closes != closes {
+		s.lifecycleMu.Unlock()
+		s.readerMu.Unlock()
+		if err := port.Close(); err != nil {
+			s.logger().Debugf("closing reopened port: %v", err)
+		}
+		return fmt.Errorf("cannot reconnect: %w", ErrClosed)
+	}
+	s.portMu.Lock()
+	s.port = port
+	s.portMu.Unlock()
+	s.reader = newPortReader(port, s.cfg.readBufferSize)
+	s.closed = false
+	s.closedCh = nil
+	s.lifecycleMu.Unlock()
+	s.readerMu.Unlock()
+
+	if s.cfg.skipInit {
+		return nil
+	}
+	if err := s.initModem(); err != nil {
+		return fmt.Errorf("failed to reinitialize modem: %v", err)
+	}
+	return nil
+}
+
+// isFatalReadError reports whether a listener read error means the port is
+// gone rather than that no data arrived in time
+func isFatalReadError(err error) bool {
+	return err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrNoProgress)
+}
+
+// superviseReconnect runs on the listener goroutine after a fatal read
+// error. It retries Reconnect with backoff and starts a fresh listener on
+// success.
+func (s *SMSHandler) superviseReconnect(callback func(SMS), cause error) {
+	policy := s.cfg.reconnect
+	s.logger().Printf("SMS listener read failed, reconnecting: %v", cause)
+
+	emit := func(ev ReconnectEvent) {
+		if policy.OnEvent != nil {
+			policy.OnEvent(ev)
+		}
+	}
+
+	// A Close from here on ends the supervision, even mid-attempt
+	closes := s.closeCount()
+	backoff := policy.InitialBackoff
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		select {
+		case <-s.clock().After(backoff):
+		case <-s.closeSignal():
+			return
+		}
+		if s.isShuttingDown() {
+			return
+		}
+
+		err = s.reconnect(closes)
+		if errors.Is(err, ErrClosed) {
+			return
+		}
+		emit(ReconnectEvent{Attempt: attempt, Err: err})
+		if err == nil {
+			s.logger().Printf("Reconnected to modem on attempt %d", attempt)
+			s.ListenForIncomingSMS(callback)
+			return
+		}
+		s.logger().Printf("Reconnect attempt %d/%d failed: %v", attempt, policy.MaxAttempts, err)
+
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+
+	s.logger().Printf("Giving up reconnecting after %d attempts", policy.MaxAttempts)
+	emit(ReconnectEvent{Attempt: policy.MaxAttempts, Err: err, GaveUp: true})
+}
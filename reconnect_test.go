@@ -0,0 +1,165 @@
+package smshandler
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+func TestAutoReconnectResumesListening(t *testing.T) {
+	broken := NewMockSerialPort()
+	broken.readErr = errors.New("device disconnected")
+
+	fresh := NewMockSerialPort()
+	opens := 0
+	openSerialPort = func(name string, mode *serial.Mode) (serial.Port, error) {
+		opens++
+		if opens == 1 {
+			return nil, errors.New("no such device")
+		}
+		return fresh, nil
+	}
+	defer func() { openSerialPort = serial.Open }()
+
+	events := make(chan ReconnectEvent, 4)
+	handler := newMockHandler(broken)
+	handler.portName = "/dev/ttyUSB0"
+	handler.baudRate = 115200
+	WithAutoReconnect(ReconnectPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		OnEvent:        func(ev ReconnectEvent) { events <- ev },
+	})(&handler.cfg)
+
+	received := make(chan SMS, 1)
+	handler.ListenForIncomingSMS(func(sms SMS) { received <- sms })
+	defer handler.Close()
+
+	for _, wantErr := range []bool{true, false} {
+		select {
+		case ev := <-events:
+			if (ev.Err != nil) != wantErr || ev.GaveUp {
+				t.Errorf("unexpected event %+v", ev)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for reconnect event")
+		}
+	}
+
+	fresh.SimulateIncoming("+CMT: \"+15551234567\",\"\",\"24/01/15,10:30:45+00\"\r\nBack online\r\n\r\n")
+	select {
+	case sms := <-received:
+		if sms.Message != "Back online" {
+			t.Errorf("got %+v", sms)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not resume after reconnecting")
+	}
+}
+
+func TestAutoReconnectGivesUp(t *testing.T) {
+	broken := NewMockSerialPort()
+	broken.readErr = errors.New("device disconnected")
+	openSerialPort = func(name string, mode *serial.Mode) (serial.Port, error) {
+		return nil, errors.New("no such device")
+	}
+	defer func() { openSerialPort = serial.Open }()
+
+	events := make(chan ReconnectEvent, 4)
+	handler := newMockHandler(broken)
+	handler.portName = "/dev/ttyUSB0"
+	WithAutoReconnect(ReconnectPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		OnEvent:        func(ev ReconnectEvent) { events <- ev },
+	})(&handler.cfg)
+
+	handler.ListenForIncomingSMS(func(SMS) {})
+
+	var last ReconnectEvent
+	for i := 0; i < 3; i++ {
+		select {
+		case last = <-events:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for reconnect event")
+		}
+	}
+	if !last.GaveUp || last.Attempt != 2 || last.Err == nil {
+		t.Errorf("expected a give-up event after 2 attempts, got %+v", last)
+	}
+}
+
+// A Close while the port reopens wins: the handler stays closed and the new
+// port is not left open
+func TestReconnectRacingClose(t *testing.T) {
+	fresh := NewMockSerialPort()
+	handler := newMockHandler(NewMockSerialPort())
+	handler.portName = "/dev/ttyUSB0"
+	handler.cfg.skipInit = true
+	openSerialPort = func(name string, mode *serial.Mode) (serial.Port, error) {
+		_ = handler.Close()
+		return fresh, nil
+	}
+	defer func() { openSerialPort = serial.Open }()
+
+	if err := handler.Reconnect(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+	if !handler.isClosed() {
+		t.Error("handler reopened after Close")
+	}
+	fresh.mu.Lock()
+	defer fresh.mu.Unlock()
+	if !fresh.closed {
+		t.Error("reopened port left open")
+	}
+}
+
+// Close during the backoff ends the supervision, so it cannot interfere with
+// the handler once it is reopened
+func TestAutoReconnectStopsOnClose(t *testing.T) {
+	broken := NewMockSerialPort()
+	broken.readErr = errors.New("device disconnected")
+	var mu sync.Mutex
+	opens := 0
+	openSerialPort = func(name string, mode *serial.Mode) (serial.Port, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		opens++
+		return NewMockSerialPort(), nil
+	}
+	defer func() { openSerialPort = serial.Open }()
+
+	handler := newMockHandler(broken)
+	handler.portName = "/dev/ttyUSB0"
+	handler.cfg.skipInit = true
+	clk := newFakeClock()
+	handler.clk = clk
+	WithAutoReconnect(ReconnectPolicy{InitialBackoff: time.Minute})(&handler.cfg)
+
+	handler.ListenForIncomingSMS(func(SMS) {})
+	deadline := time.Now().Add(5 * time.Second)
+	for clk.timers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("supervisor never started its backoff")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = handler.Close()
+	time.Sleep(20 * time.Millisecond)
+	if err := handler.Reconnect(); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	clk.Advance(time.Minute)
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if opens != 1 {
+		t.Errorf("port opened %d times, want only the manual Reconnect", opens)
+	}
+}
@@ -0,0 +1,113 @@
+package smshandler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RegistrationStatus is the <stat> field of +CREG and +CGREG
+type RegistrationStatus int
+
+// Registration states as defined by GSM 07.07
+const (
+	RegistrationNotSearching RegistrationStatus = 0
+	RegistrationHome         RegistrationStatus = 1
+	RegistrationSearching    RegistrationStatus = 2
+	RegistrationDenied       RegistrationStatus = 3
+	RegistrationUnknown      RegistrationStatus = 4
+	RegistrationRoaming      RegistrationStatus = 5
+)
+
+func (r RegistrationStatus) String() string {
+	switch r {
+	case RegistrationNotSearching:
+		return "not registered"
+	case RegistrationHome:
+		return "registered (home)"
+	case RegistrationSearching:
+		return "searching"
+	case RegistrationDenied:
+		return "registration denied"
+	case RegistrationRoaming:
+		return "registered (roaming)"
+	}
+	return "unknown"
+}
+
+// Registered reports whether the status allows sending
+func (r RegistrationStatus) Registered() bool {
+	return r == RegistrationHome || r == RegistrationRoaming
+}
+
+// registrationPollInterval is how often WaitForRegistration polls
+const registrationPollInterval = time.Second
+
+// RegistrationStatus reads the circuit-switched registration state with
+// AT+CREG?
+func (s *SMSHandler) RegistrationStatus() (RegistrationStatus, error) {
+	status, err := s.queryRegistration(context.Background(), "AT+CREG?", "+CREG:")
+	if err != nil {
+		return status, err
+	}
+	s.recordRegistration(status)
+	return status, nil
+}
+
+// WaitForRegistration blocks until the modem is registered on its home
+// network or roaming, polling AT+CREG? and, for modems that carry SMS over
+// the packet domain, AT+CGREG?. Call it after NewSMSHandler and before the
+// first send. When ctx is done first the error wraps ctx.Err() and names
+// the last status seen.
+func (s *SMSHandler) WaitForRegistration(ctx context.Context) error {
+	last := RegistrationUnknown
+	for {
+		for _, q := range []struct{ cmd, prefix string }{
+			{"AT+CREG?", "+CREG:"},
+			{"AT+CGREG?", "+CGREG:"},
+		} {
+			status, err := s.queryRegistration(ctx, q.cmd, q.prefix)
+			if err != nil {
+				if ctx.Err() != nil {
+					return fmt.Errorf("modem not registered (%s): %w", last, ctx.Err())
+				}
+				continue
+			}
+			if status.Registered() {
+				return nil
+			}
+			if q.prefix == "+CREG:" {
+				last = status
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("modem not registered (%s): %w", last, ctx.Err())
+		case <-s.clock().After(registrationPollInterval):
+		}
+	}
+}
+
+// queryRegistration sends a registration query and parses the <stat> from
+// "+CREG: <n>,<stat>[,...]"
+func (s *SMSHandler) queryRegistration(ctx context.Context, cmd, prefix string) (RegistrationStatus, error) {
+	response, err := s.sendATCommandContext(ctx, cmd)
+	if err != nil {
+		return RegistrationUnknown, err
+	}
+	if modemErr := parseModemError(response); modemErr != nil {
+		return RegistrationUnknown, fmt.Errorf("modem returned error: %w", modemErr)
+	}
+
+	fields, ok := resultFields(response, prefix)
+	if !ok || len(fields) < 2 {
+		return RegistrationUnknown, fmt.Errorf("unexpected %s response %q", cmd, response)
+	}
+	stat, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return RegistrationUnknown, fmt.Errorf("invalid registration status %q: %v", fields[1], err)
+	}
+	return RegistrationStatus(stat), nil
+}
@@ -0,0 +1,49 @@
+package smshandler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForRegistration(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CREG?", "+CREG: 0,5\r\nOK\r\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := handler.WaitForRegistration(ctx); err != nil {
+		t.Fatalf("WaitForRegistration failed: %v", err)
+	}
+}
+
+func TestWaitForRegistrationPacketDomain(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CREG?", "+CREG: 0,0\r\nOK\r\n")
+	mockPort.AddResponse("AT+CGREG?", "+CGREG: 0,1\r\nOK\r\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := handler.WaitForRegistration(ctx); err != nil {
+		t.Fatalf("WaitForRegistration failed: %v", err)
+	}
+}
+
+func TestWaitForRegistrationTimeout(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CREG?", "+CREG: 0,2\r\nOK\r\n")
+	mockPort.AddResponse("AT+CGREG?", "+CGREG: 0,2\r\nOK\r\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	err := handler.WaitForRegistration(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "searching") {
+		t.Errorf("expected a deadline error naming the status, got %v", err)
+	}
+}
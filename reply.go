@@ -0,0 +1,107 @@
+package smshandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNoReplyAddress is returned by Reply for a message whose sender cannot
+// be replied to, such as an alphanumeric sender ID like "VERIFY"
+var ErrNoReplyAddress = errors.New("sender cannot be replied to")
+
+// addIncomingHook registers fn to see every delivered message, after
+// de-duplication and before the listener callback, and returns a function
+// that removes it
+func (s *SMSHandler) addIncomingHook(fn func(SMS)) (remove func()) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	if s.hooks == nil {
+		s.hooks = make(map[int]func(SMS))
+	}
+	s.nextHookID++
+	id := s.nextHookID
+	s.hooks[id] = fn
+
+	return func() {
+		s.hooksMu.Lock()
+		defer s.hooksMu.Unlock()
+		delete(s.hooks, id)
+	}
+}
+
+// runIncomingHooks passes a delivered message to every registered hook
+func (s *SMSHandler) runIncomingHooks(sms SMS) {
+	s.hooksMu.Lock()
+	hooks := make([]func(SMS), 0, len(s.hooks))
+	for _, fn := range s.hooks {
+		hooks = append(hooks, fn)
+	}
+	s.hooksMu.Unlock()
+
+	for _, fn := range hooks {
+		fn(sms)
+	}
+}
+
+// SendSMSAndWaitForReply sends message to number and returns the next
+// message received from that number, for request/response exchanges such
+// as querying a short code. Numbers are compared as in ReadSMSFromSender,
+// or in E.164 form with WithSenderNormalization.
+// It gives up when ctx is done. A listener (ListenForIncomingSMS or
+// ListenBuffered) must be running; the reply is still delivered to it as
+// usual. Called from the listener callback, it holds up the callbacks for
+// later messages until it returns, but the reply itself is still seen.
+func (s *SMSHandler) SendSMSAndWaitForReply(ctx context.Context, number, message string, opts ...SendOption) (SMS, error) {
+	return s.SendSMSAndWaitFor(ctx, number, message, func(sms SMS) bool {
+		return s.isFrom(sms, number)
+	}, opts...)
+}
+
+// SendSMSAndWaitFor is SendSMSAndWaitForReply with a custom test for the
+// reply: it returns the first received message for which match returns
+// true. Watching starts before the message is sent, so a reply that
+// arrives while the send is still completing is not missed.
+func (s *SMSHandler) SendSMSAndWaitFor(ctx context.Context, number, message string, match func(SMS) bool, opts ...SendOption) (SMS, error) {
+	replies := make(chan SMS, 1)
+	remove := s.addIncomingHook(func(sms SMS) {
+		if !match(sms) {
+			return
+		}
+		select {
+		case replies <- sms:
+		default:
+			// Already have a reply
+		}
+	})
+	defer remove()
+
+	if err := s.SendSMS(number, message, opts...); err != nil {
+		return SMS{}, err
+	}
+
+	select {
+	case sms := <-replies:
+		return sms, nil
+	case <-ctx.Done():
+		return SMS{}, ctx.Err()
+	}
+}
+
+// Reply sends message to the sender of original: SenderNormalized when it
+// is set, otherwise Sender normalized with NormalizePhoneNumber so
+// formatting the modem added to the address does not get in the way.
+// Messages from an alphanumeric sender ID, or with no sender, fail with an
+// error wrapping ErrNoReplyAddress. It may be called from the
+// ListenForIncomingSMS callback.
+func (s *SMSHandler) Reply(original SMS, message string, opts ...SendOption) error {
+	number := original.SenderNormalized
+	if number == "" {
+		number = NormalizePhoneNumber(original.Sender)
+	}
+	if number == "" || isAlphanumericAddress(number) {
+		return fmt.Errorf("cannot reply to %q: %w", original.Sender, ErrNoReplyAddress)
+	}
+	return s.SendSMS(number, message, opts...)
+}
@@ -0,0 +1,164 @@
+package smshandler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendSMSAndWaitForReply(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGS="+15551234567",145`, "\r\n> ")
+	mockPort.AddResponse("BALANCE\x1A", "\r\n+CMGS: 12\r\nOK\r\n")
+
+	type result struct {
+		sms SMS
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		sms, err := handler.SendSMSAndWaitForReply(context.Background(), "+15551234567", "BALANCE")
+		done <- result{sms, err}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(mockPort.GetWrittenData(), "BALANCE\x1A") {
+		if time.Now().After(deadline) {
+			t.Fatal("message was never sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var delivered []string
+	callback := func(sms SMS) { delivered = append(delivered, sms.Message) }
+	handler.deliver(SMS{Sender: "+15559999999", Message: "Unrelated"}, callback)
+	handler.deliver(SMS{Sender: "15551234567", Message: "Balance: 10.00"}, callback)
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatalf("SendSMSAndWaitForReply failed: %v", r.err)
+		}
+		if r.sms.Message != "Balance: 10.00" {
+			t.Errorf("reply: got %q", r.sms.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reply was not returned")
+	}
+
+	if len(delivered) != 2 {
+		t.Errorf("listener callback should still see every message, got %q", delivered)
+	}
+	if len(handler.hooks) != 0 {
+		t.Errorf("reply hook not removed: %d left", len(handler.hooks))
+	}
+}
+
+func TestSendSMSAndWaitForReplyTimeout(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGS="+15551234567",145`, "\r\n> ")
+	mockPort.AddResponse("PING\x1A", "\r\n+CMGS: 3\r\nOK\r\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	if _, err := handler.SendSMSAndWaitForReply(ctx, "+15551234567", "PING"); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if len(handler.hooks) != 0 {
+		t.Errorf("reply hook not removed: %d left", len(handler.hooks))
+	}
+}
+
+func TestReply(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGS="+15551234567",145`, "\r\n> ")
+	mockPort.AddResponse("Got it\x1A", "\r\n+CMGS: 4\r\nOK\r\n")
+
+	if err := handler.Reply(SMS{Sender: " +1 (555) 123-4567 ", Message: "Hi"}, "Got it"); err != nil {
+		t.Fatalf("Reply failed: %v", err)
+	}
+
+	for _, sender := range []string{"VERIFY", "MyBank", ""} {
+		err := handler.Reply(SMS{Sender: sender}, "No")
+		if !errors.Is(err, ErrNoReplyAddress) {
+			t.Errorf("Reply to %q: expected ErrNoReplyAddress, got %v", sender, err)
+		}
+	}
+}
+
+// An auto-reply bot answers from inside the listener callback
+func TestReplyFromCallback(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	defer handler.Close()
+	mockPort.AddResponse(`AT+CMGS="+15551234567",145`, "\r\n> ")
+	mockPort.AddResponse("Pong\x1A", "\r\n+CMGS: 4\r\nOK\r\n")
+
+	replied := make(chan error, 1)
+	handler.ListenForIncomingSMS(func(sms SMS) {
+		replied <- handler.Reply(sms, "Pong")
+	})
+	mockPort.SimulateIncoming("+CMT: \"+15551234567\",\"\",\"24/01/15,10:30:45+00\"\r\nPing\r\n\r\n")
+
+	select {
+	case err := <-replied:
+		if err != nil {
+			t.Fatalf("Reply failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reply from the callback did not complete")
+	}
+	if !strings.Contains(mockPort.GetWrittenData(), "Pong\x1A") {
+		t.Errorf("reply not sent: %q", mockPort.GetWrittenData())
+	}
+}
+
+// The reply is matched by the live listener even while the waiting call
+// runs inside the callback
+func TestSendSMSAndWaitForReplyFromCallback(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	defer handler.Close()
+	mockPort.AddResponse(`AT+CMGS="+15551234567",145`, "\r\n> ")
+	mockPort.AddResponse("BALANCE\x1A", "\r\n+CMGS: 12\r\nOK\r\n")
+
+	replies := make(chan string, 1)
+	handler.ListenForIncomingSMS(func(sms SMS) {
+		if sms.Message != "Check" {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		reply, err := handler.SendSMSAndWaitForReply(ctx, "+15551234567", "BALANCE")
+		if err != nil {
+			replies <- err.Error()
+			return
+		}
+		replies <- reply.Message
+	})
+	mockPort.SimulateIncoming("+CMT: \"+15559999999\",\"\",\"24/01/15,10:30:45+00\"\r\nCheck\r\n\r\n")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(mockPort.GetWrittenData(), "BALANCE\x1A") {
+		if time.Now().After(deadline) {
+			t.Fatal("message was never sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mockPort.SimulateIncoming("+CMT: \"+15551234567\",\"\",\"24/01/15,10:31:00+00\"\r\nBalance: 10.00\r\n\r\n")
+
+	select {
+	case got := <-replies:
+		if got != "Balance: 10.00" {
+			t.Errorf("reply: got %q", got)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("reply was not returned")
+	}
+}
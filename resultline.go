@@ -0,0 +1,43 @@
+package smshandler
+
+import "strings"
+
+// ParseURC splits an information response or unsolicited result line such
+// as `+CREG: 1,"00C3","1A2B",7` into its command name ("+CREG") and its
+// argument fields, split on commas outside quotes with surrounding spaces
+// and quotes removed. Empty fields are kept so positions stay stable. For
+// a line that is not of the form "+NAME: ..." it returns "" and nil. It is
+// handy for lines received through OnURC.
+func ParseURC(line string) (command string, fields []string) {
+	line = strings.TrimSpace(line)
+	command = resultName(line)
+	if command == "" || command == line {
+		return "", nil
+	}
+
+	args := strings.TrimSpace(line[len(command)+1:])
+	if args == "" {
+		return command, nil
+	}
+	fields = splitRespectingQuotes(args, ',')
+	// A trailing empty field is dropped by the splitter; restore it
+	if strings.HasSuffix(args, ",") {
+		fields = append(fields, "")
+	}
+	for i := range fields {
+		fields[i] = unquote(fields[i])
+	}
+	return command, fields
+}
+
+// resultFields returns the fields of the first line of response for the
+// command named by prefix, as in "+CBC:"
+func resultFields(response, prefix string) ([]string, bool) {
+	name := strings.TrimSuffix(prefix, ":")
+	for _, line := range strings.Split(response, "\n") {
+		if command, fields := ParseURC(line); command == name {
+			return fields, true
+		}
+	}
+	return nil, false
+}
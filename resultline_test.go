@@ -0,0 +1,45 @@
+package smshandler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseURC(t *testing.T) {
+	tests := []struct {
+		line    string
+		command string
+		fields  []string
+	}{
+		{"+CSQ: 20,99", "+CSQ", []string{"20", "99"}},
+		{`+CREG: 1,"00C3","1A2B",7`, "+CREG", []string{"1", "00C3", "1A2B", "7"}},
+		{`+COPS: 0,0,"Operator, Inc.",7`, "+COPS", []string{"0", "0", "Operator, Inc.", "7"}},
+		{`+CPMS: "SM", 5, 30,"ME",0,100`, "+CPMS", []string{"SM", "5", "30", "ME", "0", "100"}},
+		{`+CLCC: 1,0,0,0,0,"",129`, "+CLCC", []string{"1", "0", "0", "0", "0", "", "129"}},
+		{"+CMTI: \"SM\",3\r\n", "+CMTI", []string{"SM", "3"}},
+		{"+CGREG: 0,", "+CGREG", []string{"0", ""}},
+		{"+CPIN: READY", "+CPIN", []string{"READY"}},
+		{"+CMEE:", "+CMEE", nil},
+		{"OK", "", nil},
+		{"RDY", "", nil},
+		{"+QIND", "", nil},
+	}
+
+	for _, tt := range tests {
+		command, fields := ParseURC(tt.line)
+		if command != tt.command || !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("ParseURC(%q): got %q %q, want %q %q", tt.line, command, fields, tt.command, tt.fields)
+		}
+	}
+}
+
+func TestResultFields(t *testing.T) {
+	response := "+CGREG: 0,1\n+CREG: 0,5\nOK"
+	fields, ok := resultFields(response, "+CREG:")
+	if !ok || !reflect.DeepEqual(fields, []string{"0", "5"}) {
+		t.Errorf("got %q, %v", fields, ok)
+	}
+	if _, ok := resultFields(response, "+CSQ:"); ok {
+		t.Error("found a line for a command not in the response")
+	}
+}
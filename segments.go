@@ -0,0 +1,111 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Per-segment capacity of concatenated messages, after the 6-octet UDH
+const (
+	maxGSM7SeptetsPerPart = 153
+	maxUCS2UnitsPerPart   = 67
+)
+
+// DefaultMaxSegments is the most segments SendSMS will send one message as
+// when no WithMaxSegments option is supplied
+const DefaultMaxSegments = 10
+
+// SegmentLimitError is returned when a message would take more segments
+// than the handler's MaxSegments limit. Nothing is sent.
+type SegmentLimitError struct {
+	// Segments is how many segments the message would take
+	Segments int
+	// MaxSegments is the configured limit
+	MaxSegments int
+}
+
+func (e *SegmentLimitError) Error() string {
+	return fmt.Sprintf("message would take %d segments, over the limit of %d", e.Segments, e.MaxSegments)
+}
+
+// MessageTooLongError is returned when a message does not fit in a single
+// SMS and cannot be split: in text mode, and when writing to storage.
+// Sending it anyway would let the modem truncate or reject it.
+type MessageTooLongError struct {
+	// Encoding is the alphabet the message needs
+	Encoding Encoding
+	// Length is the message size in septets (GSM) or UTF-16 units (UCS2)
+	Length int
+	// Limit is the single-message capacity in the same units
+	Limit int
+	// Segments is how many concatenated parts the message would take
+	Segments int
+}
+
+func (e *MessageTooLongError) Error() string {
+	return fmt.Sprintf("message is %d %s units, over the single-SMS limit of %d; it would need %d segments, so split or shorten it",
+		e.Length, e.Encoding, e.Limit, e.Segments)
+}
+
+// resolveEncoding picks the encoding for a message: GSM 7-bit when every
+// character fits, UCS2 otherwise, unless a specific encoding is forced
+func resolveEncoding(message string, forced Encoding) (Encoding, error) {
+	switch forced {
+	case "":
+		if isGSM7(message) {
+			return EncodingGSM7, nil
+		}
+		return EncodingUCS2, nil
+	case EncodingGSM7:
+		if !isGSM7(message) {
+			return "", errors.New("message contains characters outside the GSM 7-bit alphabet")
+		}
+		return EncodingGSM7, nil
+	case EncodingUCS2:
+		return EncodingUCS2, nil
+	}
+	return "", fmt.Errorf("encoding %q cannot be used for text messages", forced)
+}
+
+// segmentCount returns the encoding a message needs, its length in that
+// encoding's units and the number of SMS segments it takes
+func segmentCount(message string) (Encoding, int, int) {
+	enc, _ := resolveEncoding(message, "")
+	n, segments := segmentCountFor(message, enc)
+	return enc, n, segments
+}
+
+// segmentCountFor returns the length of message in units of enc and the
+// number of SMS segments it takes
+func segmentCountFor(message string, enc Encoding) (int, int) {
+	if enc == EncodingGSM7 {
+		septets, _ := encodeGSM7(message)
+		n := len(septets)
+		if n <= maxGSM7Septets {
+			return n, 1
+		}
+		return n, (n + maxGSM7SeptetsPerPart - 1) / maxGSM7SeptetsPerPart
+	}
+
+	n := len(utf16.Encode([]rune(message)))
+	if n <= maxUDOctets/2 {
+		return n, 1
+	}
+	return n, (n + maxUCS2UnitsPerPart - 1) / maxUCS2UnitsPerPart
+}
+
+// checkSingleSegment returns a MessageTooLongError when message needs more
+// than one SMS in the given encoding
+func checkSingleSegment(message string, enc Encoding) error {
+	n, segments := segmentCountFor(message, enc)
+	if segments <= 1 {
+		return nil
+	}
+
+	limit := maxGSM7Septets
+	if enc == EncodingUCS2 {
+		limit = maxUDOctets / 2
+	}
+	return &MessageTooLongError{Encoding: enc, Length: n, Limit: limit, Segments: segments}
+}
@@ -0,0 +1,73 @@
+package smshandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSegmentCount(t *testing.T) {
+	tests := []struct {
+		message  string
+		encoding Encoding
+		length   int
+		segments int
+	}{
+		{strings.Repeat("a", 160), EncodingGSM7, 160, 1},
+		{strings.Repeat("a", 161), EncodingGSM7, 161, 2},
+		{strings.Repeat("€", 80), EncodingGSM7, 160, 1},
+		{strings.Repeat("a", 306), EncodingGSM7, 306, 2},
+		{strings.Repeat("ж", 70), EncodingUCS2, 70, 1},
+		{strings.Repeat("ж", 71), EncodingUCS2, 71, 2},
+		{strings.Repeat("ж", 135), EncodingUCS2, 135, 3},
+	}
+
+	for _, tt := range tests {
+		enc, n, segments := segmentCount(tt.message)
+		if enc != tt.encoding || n != tt.length || segments != tt.segments {
+			t.Errorf("segmentCount(%d x %q): got %s/%d/%d, want %s/%d/%d",
+				len([]rune(tt.message)), []rune(tt.message)[0], enc, n, segments, tt.encoding, tt.length, tt.segments)
+		}
+	}
+}
+
+func TestSendSMSRejectsMultiSegmentMessage(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	err := handler.SendSMS("+15551234567", strings.Repeat("a", 200))
+
+	var tooLong *MessageTooLongError
+	if !errors.As(err, &tooLong) {
+		t.Fatalf("expected MessageTooLongError, got %v", err)
+	}
+	if tooLong.Segments != 2 || !strings.Contains(err.Error(), "2 segments") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if mockPort.GetWrittenData() != "" {
+		t.Errorf("nothing should be sent, got %q", mockPort.GetWrittenData())
+	}
+}
+
+func TestMaxSegments(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.mode = ModePDU
+
+	// 11 full GSM segments
+	err := handler.SendSMS("+15551234567", strings.Repeat("a", 11*maxGSM7SeptetsPerPart))
+	var limitErr *SegmentLimitError
+	if !errors.As(err, &limitErr) || limitErr.Segments != 11 || limitErr.MaxSegments != DefaultMaxSegments {
+		t.Errorf("expected a SegmentLimitError for 11 segments, got %v", err)
+	}
+
+	handler.cfg.maxSegments = 1
+	err = handler.SendSMS("+15551234567", strings.Repeat("a", 161))
+	if !errors.As(err, &limitErr) || limitErr.Segments != 2 {
+		t.Errorf("expected a SegmentLimitError for 2 segments, got %v", err)
+	}
+
+	if mockPort.GetWrittenData() != "" {
+		t.Errorf("nothing should be sent, got %q", mockPort.GetWrittenData())
+	}
+}
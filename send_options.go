@@ -0,0 +1,165 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SendOption adjusts how a single message is sent.
+type SendOption func(*sendOptions)
+
+// sendOptions holds the resolved per-send settings
+type sendOptions struct {
+	addressType AddressType
+	senderID    string
+	encoding    Encoding
+	validity    time.Duration
+	progress    func(SendProgress)
+}
+
+func applySendOptions(opts []SendOption) sendOptions {
+	var o sendOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// AddressType is the GSM 04.11 type-of-address octet (TON/NPI) sent with the
+// destination number
+type AddressType int
+
+const (
+	// AddressTypeAuto sends international format (145) when the number
+	// starts with '+' and leaves the type to the modem otherwise
+	AddressTypeAuto AddressType = 0
+	// AddressTypeUnknown (129) lets the network interpret the number
+	AddressTypeUnknown AddressType = 129
+	// AddressTypeInternational (145) marks the number as including the
+	// country code
+	AddressTypeInternational AddressType = 145
+	// AddressTypeNational (161) marks the number as a national number
+	AddressTypeNational AddressType = 161
+)
+
+// resolve returns the type-of-address to send for number, or 0 to omit it
+func (t AddressType) resolve(number string) AddressType {
+	if t != AddressTypeAuto {
+		return t
+	}
+	if strings.HasPrefix(number, "+") {
+		return AddressTypeInternational
+	}
+	return 0
+}
+
+// WithAddressType forces the type-of-address used for the destination
+// number. Some networks reject bare numbers unless the international type is
+// given explicitly. Numbers produced by NormalizePhoneNumber that start with
+// '+' already get the international type under the default
+// AddressTypeAuto, so this is only needed to override that inference.
+func WithAddressType(t AddressType) SendOption {
+	return func(o *sendOptions) {
+		o.addressType = t
+	}
+}
+
+// WithEncoding forces the encoding of a message instead of picking GSM
+// 7-bit when possible and UCS2 otherwise. Forcing EncodingGSM7 fails the
+// send if the text has characters outside the GSM alphabet; forcing
+// EncodingUCS2 keeps segment sizes predictable and requires PDU mode.
+func WithEncoding(e Encoding) SendOption {
+	return func(o *sendOptions) {
+		o.encoding = e
+	}
+}
+
+// WithValidityPeriod sets how long the SMSC keeps trying to deliver the
+// message before discarding it, so a time-sensitive message such as a
+// one-time code is not delivered long after it stopped being useful. The
+// network only supports steps of 5 minutes up to 12 hours, 30 minutes up to
+// 24 hours, days up to 30 days and weeks up to 63 weeks; d is rounded up to
+// the next step and clamped to 5 minutes to 63 weeks. In PDU mode it is set
+// in the message itself; in text mode AT+CSMP is changed for the send and
+// restored afterwards. Without it the modem's default, normally 24 hours,
+// applies.
+func WithValidityPeriod(d time.Duration) SendOption {
+	return func(o *sendOptions) {
+		o.validity = d
+	}
+}
+
+// relativeValidity returns the TP-VP value for a PDU-mode send
+func (o sendOptions) relativeValidity() byte {
+	if o.validity <= 0 {
+		return defaultValidityPeriod
+	}
+	return byte(encodeValidityPeriod(o.validity))
+}
+
+// MaxSenderIDLength is the longest alphanumeric originator a network accepts
+const MaxSenderIDLength = 11
+
+// WithSenderID requests an alphanumeric originating address such as
+// "ACME". The name must be 1 to 11 characters from the GSM 7-bit alphabet.
+// The SMS-SUBMIT a modem hands to the SMSC, in text or PDU mode, carries no
+// originating address, so the network always stamps the SIM's own number:
+// a send with a valid sender ID fails with an error wrapping
+// ErrNotSupported rather than going out from that number unnoticed.
+// Branded sender IDs have to be set by an SMS gateway or provider API.
+func WithSenderID(name string) SendOption {
+	return func(o *sendOptions) {
+		o.senderID = name
+	}
+}
+
+// validateSenderID checks an alphanumeric originator against the GSM 03.40
+// limits
+func validateSenderID(name string) error {
+	if name == "" {
+		return errors.New("sender ID is empty")
+	}
+	if n := utf8.RuneCountInString(name); n > MaxSenderIDLength {
+		return fmt.Errorf("sender ID %q is %d characters, maximum is %d", name, n, MaxSenderIDLength)
+	}
+	if !isGSM7(name) {
+		return fmt.Errorf("sender ID %q contains characters outside the GSM 7-bit alphabet", name)
+	}
+	return nil
+}
+
+// NormalizePhoneNumber strips formatting characters (spaces, dashes, dots and
+// parentheses) from a phone number and rewrites a leading international
+// access code "00" as '+'. The result starts with '+' when the number is in
+// international format, which SendSMS sends with AddressTypeInternational.
+// Other characters, such as letters in alphanumeric sender IDs, are kept.
+func NormalizePhoneNumber(number string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(number) {
+		switch {
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			// Formatting only
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	normalized := b.String()
+	if strings.HasPrefix(normalized, "00") {
+		normalized = "+" + normalized[2:]
+	}
+	return normalized
+}
+
+// sameNumber reports whether two phone numbers are the same after
+// normalization, ignoring whether either has a leading '+'
+func sameNumber(a, b string) bool {
+	a = strings.TrimPrefix(NormalizePhoneNumber(a), "+")
+	b = strings.TrimPrefix(NormalizePhoneNumber(b), "+")
+	return a != "" && a == b
+}
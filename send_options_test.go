@@ -0,0 +1,136 @@
+package smshandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"+1 (234) 567-890", "+1234567890"},
+		{"0044 20 7946 0958", "+442079460958"},
+		{"555.123.4567", "5551234567"},
+		{" +1234567890 ", "+1234567890"},
+		{"VERIFY", "VERIFY"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizePhoneNumber(tt.input); got != tt.want {
+			t.Errorf("NormalizePhoneNumber(%q): got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAddressTypeResolve(t *testing.T) {
+	tests := []struct {
+		addressType AddressType
+		number      string
+		want        AddressType
+	}{
+		{AddressTypeAuto, "+1234567890", AddressTypeInternational},
+		{AddressTypeAuto, "1234567890", 0},
+		{AddressTypeInternational, "1234567890", AddressTypeInternational},
+		{AddressTypeNational, "0201234567", AddressTypeNational},
+	}
+
+	for _, tt := range tests {
+		if got := tt.addressType.resolve(tt.number); got != tt.want {
+			t.Errorf("%d.resolve(%q): got %d, want %d", tt.addressType, tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestSendSMSAddressType(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n> ")
+		time.Sleep(150 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n+CMGS: 5\r\nOK\r\n")
+	}()
+
+	if err := handler.SendSMS("441234567890", "Hi", WithAddressType(AddressTypeInternational)); err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+	if !strings.Contains(mockPort.GetWrittenData(), `AT+CMGS="441234567890",145`) {
+		t.Errorf("address type not sent: %q", mockPort.GetWrittenData())
+	}
+}
+
+func TestWithSenderID(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		unsupported bool
+	}{
+		{"valid", "ACME", true},
+		{"eleven characters", "ACMEPHARMA1", true},
+		{"too long", "ACMEPHARMACY", false},
+		{"non GSM characters", "Café☕", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPort := NewMockSerialPort()
+			handler := newMockHandler(mockPort)
+
+			err := handler.SendSMS("+15551234567", "Hello", WithSenderID(tt.id))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrNotSupported) != tt.unsupported {
+				t.Errorf("got %v, want ErrNotSupported: %v", err, tt.unsupported)
+			}
+			if mockPort.GetWrittenData() != "" {
+				t.Errorf("nothing should be written, got %q", mockPort.GetWrittenData())
+			}
+		})
+	}
+}
+
+func TestWithEncoding(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	err := handler.SendSMS("+15551234567", "Привет", WithEncoding(EncodingGSM7))
+	if err == nil || !strings.Contains(err.Error(), "GSM 7-bit") {
+		t.Errorf("expected a GSM 7-bit error, got %v", err)
+	}
+
+	err = handler.SendSMS("+15551234567", "Hello", WithEncoding(EncodingUCS2))
+	if !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected forcing UCS2 in text mode to be unsupported, got %v", err)
+	}
+
+	err = handler.SendSMS("+15551234567", "Hello", WithEncoding(Encoding8Bit))
+	if err == nil {
+		t.Error("expected 8-bit encoding to be rejected for text")
+	}
+
+	// 71 GSM characters fit one GSM segment but not one UCS2 segment, so
+	// forcing UCS2 in PDU mode sends them as a concatenated message
+	if _, segments := segmentCountFor(strings.Repeat("a", 71), EncodingUCS2); segments != 2 {
+		t.Errorf("forced UCS2 segments: got %d, want 2", segments)
+	}
+
+	if mockPort.GetWrittenData() != "" {
+		t.Errorf("nothing should be sent, got %q", mockPort.GetWrittenData())
+	}
+}
+
+func TestEncodeSubmitPDUForcedUCS2(t *testing.T) {
+	pdu, _, err := encodeSubmitPDU("+46708251358", "hi", AddressTypeAuto, EncodingUCS2, defaultValidityPeriod)
+	if err != nil {
+		t.Fatalf("encodeSubmitPDU failed: %v", err)
+	}
+	if !strings.HasSuffix(pdu, "0008A70400680069") {
+		t.Errorf("expected UCS2 user data, got %s", pdu)
+	}
+}
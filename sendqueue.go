@@ -0,0 +1,55 @@
+package smshandler
+
+// sendJob is a message waiting in the send queue
+type sendJob struct {
+	number  string
+	message string
+	opts    []SendOption
+	result  chan error
+}
+
+// Enqueue queues a message for sending and returns a channel that receives
+// the send's result. Queued messages are sent one at a time, in the order
+// they were enqueued, by a worker goroutine that runs while the queue is
+// non-empty, so producers never wait on the modem themselves. Send options
+// apply as for SendSMS.
+//
+// Queued messages count as sends in progress: Shutdown waits for the queue
+// to drain (until its context is done), and Enqueue after Shutdown reports
+// ErrShuttingDown on the returned channel.
+func (s *SMSHandler) Enqueue(number, message string, opts ...SendOption) <-chan error {
+	result := make(chan error, 1)
+	if err := s.beginSend(); err != nil {
+		result <- err
+		return result
+	}
+
+	s.sendQueueMu.Lock()
+	defer s.sendQueueMu.Unlock()
+
+	s.sendQueue = append(s.sendQueue, sendJob{number: number, message: message, opts: opts, result: result})
+	if !s.sendQueueRunning {
+		s.sendQueueRunning = true
+		go s.runSendQueue()
+	}
+	return result
+}
+
+// runSendQueue sends queued messages in order until the queue is empty
+func (s *SMSHandler) runSendQueue() {
+	for {
+		s.sendQueueMu.Lock()
+		if len(s.sendQueue) == 0 {
+			s.sendQueueRunning = false
+			s.sendQueueMu.Unlock()
+			return
+		}
+		job := s.sendQueue[0]
+		s.sendQueue = s.sendQueue[1:]
+		s.sendQueueMu.Unlock()
+
+		_, err := s.sendSMS(job.number, job.message, job.opts...)
+		s.endSend()
+		job.result <- err
+	}
+}
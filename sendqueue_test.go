@@ -0,0 +1,54 @@
+package smshandler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEnqueueSendsInOrder(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.clk = newFakeClock()
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+
+	var results []<-chan error
+	for i := 1; i <= 3; i++ {
+		body := fmt.Sprintf("Message %d", i)
+		mockPort.AddResponse(body+"\x1A", fmt.Sprintf("\r\n+CMGS: %d\r\nOK\r\n", i))
+		results = append(results, handler.Enqueue("+1234567890", body))
+	}
+
+	for i, result := range results {
+		if err := <-result; err != nil {
+			t.Errorf("message %d: %v", i+1, err)
+		}
+	}
+
+	written := mockPort.GetWrittenData()
+	first, second, third := strings.Index(written, "Message 1"), strings.Index(written, "Message 2"), strings.Index(written, "Message 3")
+	if first < 0 || second < first || third < second {
+		t.Errorf("messages not sent in order: %q", written)
+	}
+}
+
+func TestShutdownDrainsSendQueue(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.clk = newFakeClock()
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Queued\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+
+	result := handler.Enqueue("+1234567890", "Queued")
+	if err := handler.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("queued message not sent before shutdown: %v", err)
+	}
+
+	if err := <-handler.Enqueue("+1234567890", "Too late"); err != ErrShuttingDown {
+		t.Errorf("got %v, want ErrShuttingDown", err)
+	}
+}
@@ -0,0 +1,93 @@
+package smshandler
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrShuttingDown is returned by send methods once Shutdown has been called
+var ErrShuttingDown = errors.New("sms handler is shutting down")
+
+// ErrClosed is returned by a command that was waiting for the modem when
+// Close was called
+var ErrClosed = errors.New("sms handler is closed")
+
+// beginSend registers an in-flight send, refusing it during shutdown. Every
+// successful call must be paired with endSend.
+func (s *SMSHandler) beginSend() error {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	if s.shuttingDown {
+		return ErrShuttingDown
+	}
+	s.inflight.Add(1)
+	return nil
+}
+
+// isShuttingDown reports whether Shutdown or Close has been called
+func (s *SMSHandler) isShuttingDown() bool {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+	return s.shuttingDown || s.closed
+}
+
+// isClosed reports whether Close has been called since the port was last
+// opened
+func (s *SMSHandler) isClosed() bool {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+	return s.closed
+}
+
+// closeSignal returns a channel that is closed by Close
+func (s *SMSHandler) closeSignal() <-chan struct{} {
+	s.lifecycleMu.Lock()
+	defer s.lifecycleMu.Unlock()
+
+	if s.closedCh == nil {
+		s.closedCh = make(chan struct{})
+		if s.closed {
+			close(s.closedCh)
+		}
+	}
+	return s.closedCh
+}
+
+// endSend marks an in-flight send as finished
+func (s *SMSHandler) endSend() {
+	s.inflight.Done()
+}
+
+// Shutdown gracefully stops the handler: new sends are refused with
+// ErrShuttingDown, sends already in progress are given until ctx is done to
+// finish, then the listener is stopped and waited for and the port closed.
+// The port is closed even if ctx expires first, in which case ctx.Err() is
+// returned.
+func (s *SMSHandler) Shutdown(ctx context.Context) error {
+	s.lifecycleMu.Lock()
+	s.shuttingDown = true
+	s.lifecycleMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+
+	var waitErr error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+
+	// The listener must be done with the port before it is closed
+	if _, err := s.stopListener(ctx); err != nil && waitErr == nil {
+		waitErr = err
+	}
+	if err := s.Close(); err != nil {
+		return err
+	}
+	return waitErr
+}
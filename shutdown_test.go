@@ -0,0 +1,162 @@
+package smshandler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForInflightSend(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n> ")
+		time.Sleep(300 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n+CMGS: 1\r\nOK\r\n")
+	}()
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- handler.SendSMS("+1234567890", "In flight")
+	}()
+
+	// Let the send get under way before shutting down
+	time.Sleep(20 * time.Millisecond)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- handler.Shutdown(context.Background())
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := handler.SendSMS("+1234567890", "Too late"); err != ErrShuttingDown {
+		t.Errorf("new send during shutdown: got %v, want ErrShuttingDown", err)
+	}
+
+	if err := <-sendErr; err != nil {
+		t.Errorf("in-flight send failed: %v", err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if !mockPort.closed {
+		t.Error("port not closed after shutdown")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	// Simulate a send that never finishes
+	if err := handler.beginSend(); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.endSend()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := handler.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+	if !mockPort.closed {
+		t.Error("port not closed after deadline")
+	}
+}
+
+func TestShutdownWaitsForListener(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	handler.ListenForIncomingSMS(func(SMS) {})
+	run := handler.currentListener()
+
+	if err := handler.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	select {
+	case <-run.done:
+	default:
+		t.Error("Shutdown returned before the listener exited")
+	}
+	if handler.isListening() {
+		t.Error("listener still registered after Shutdown")
+	}
+}
+
+// hangingPort never answers; like a real serial port, closing it unblocks
+// a pending read
+type hangingPort struct {
+	*MockSerialPort
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (h *hangingPort) Read(p []byte) (int, error) {
+	<-h.closed
+	return 0, errors.New("port closed")
+}
+
+func (h *hangingPort) Close() error {
+	h.closeOnce.Do(func() { close(h.closed) })
+	return h.MockSerialPort.Close()
+}
+
+func TestCloseInterruptsCommand(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	port := &hangingPort{MockSerialPort: mockPort, closed: make(chan struct{})}
+	handler := newMockHandler(mockPort)
+	handler.port = port
+	handler.reader = bufio.NewReader(port)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := handler.GetSignalStrength()
+		result <- err
+	}()
+	for !strings.Contains(mockPort.GetWrittenData(), "AT+CSQ") {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("command still waiting after Close")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %v", elapsed)
+	}
+}
+
+func TestCloseStopsListener(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	port := &hangingPort{MockSerialPort: mockPort, closed: make(chan struct{})}
+	handler := newMockHandler(mockPort)
+	handler.port = port
+	handler.reader = bufio.NewReader(port)
+
+	handler.ListenForIncomingSMS(func(SMS) {})
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for handler.isListening() {
+		if time.Now().After(deadline) {
+			t.Fatal("listener still running after Close")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
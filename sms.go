@@ -3,30 +3,151 @@ package smshandler
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"go.bug.st/serial"
 )
 
 type SMSHandler struct {
+	portName   string
+	baudRate   int
 	port       serial.Port
 	reader     *bufio.Reader
 	readerMu   sync.Mutex
-	listening  bool
 	pauseChan  chan bool
 	resumeChan chan bool
+	cfg        config
+	dedup      *deduplicator
+	metrics    MetricsRecorder
+	concat     *reassembler
+	clk        clock
+	urcMu      sync.RWMutex
+	urcHandler func(string)
+	queueMu    sync.Mutex
+	queue      *messageQueue
+
+	sendGateMu sync.Mutex
+	lastSend   time.Time
+
+	// unhandled keeps recent listener lines; see UnhandledLines
+	unhandled lineLog
+
+	// modemModel and quirks are set by DetectModemQuirks and read by the
+	// listener; see modemQuirks
+	quirksMu   sync.RWMutex
+	modemModel string
+	quirks     QuirkProfile
+
+	// Settings initModem applied, reported by Config
+	charset string
+	storage string
+	cnmi    string
+
+	// activeStorage is what AT+CPMS? reported after the last storage
+	// change; see selectStorage
+	activeStorage []string
+
+	// concatRef is the last concatenation reference used for a long send
+	concatRef uint32
+
+	sendQueueMu      sync.Mutex
+	sendQueue        []sendJob
+	sendQueueRunning bool
+
+	// hooks see every delivered message; see addIncomingHook
+	hooksMu    sync.Mutex
+	hooks      map[int]func(SMS)
+	nextHookID int
+
+	// incomingFilter gates the listener callback; see SetIncomingFilter
+	filterMu       sync.RWMutex
+	incomingFilter func(SMS) bool
+
+	// subscriptions are the Subscribe channels by id
+	subsMu             sync.Mutex
+	subscriptions      map[int]*subscription
+	nextSubscriptionID int
+
+	// interleaved are URCs read during sends, waiting for the listener
+	interleavedMu sync.Mutex
+	interleaved   []interleavedURC
+
+	// exclusiveMu keeps every other command out while a send has switched
+	// modem settings for its message; see beginExclusive
+	exclusiveMu sync.Mutex
+
+	// composeOpen is set while a prompt-based command may have left the
+	// modem waiting for message text; guarded by readerMu
+	composeOpen bool
+
+	// stats backs Stats; see metricsRecorder
+	stats statsRecorder
+
+	// health backs Status; see recordCommand
+	healthMu sync.Mutex
+	health   healthState
+
+	// listener is the running listener goroutine, nil when stopped; see
+	// stopListener
+	listenMu sync.Mutex
+	listener *listenerRun
+
+	// portMu guards replacing port, which Reconnect does while also holding
+	// readerMu; code not holding readerMu reads port through currentPort
+	portMu sync.Mutex
+
+	lifecycleMu  sync.Mutex
+	shuttingDown bool
+	closed       bool
+	closedCh     chan struct{}
+	// closes counts the Close calls that closed the handler; see reconnect
+	closes   int
+	inflight sync.WaitGroup
 }
 
+// SMS is a message read from or delivered by the modem
 type SMS struct {
-	Index   int
-	Status  string
-	Sender  string
-	Date    string
-	Message string
+	Index  int           `json:"index"`
+	Status MessageStatus `json:"status,omitempty"`
+	Sender string        `json:"sender,omitempty"`
+	// SenderNormalized is Sender in E.164 form when WithSenderNormalization
+	// is set, or "" for alphanumeric senders, short codes and numbers that
+	// could not be normalized
+	SenderNormalized string `json:"sender_normalized,omitempty"`
+	// SenderName is the originator's display name: the modem's <alpha>
+	// field when reported, or the address itself for alphanumeric senders
+	SenderName string `json:"sender_name,omitempty"`
+	// Date is the timestamp exactly as reported by the modem
+	Date string `json:"date,omitempty"`
+	// Timestamp is Date parsed into a time.Time, zero if it could not be parsed
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message,omitempty"`
+	// Encoding is the alphabet the body was sent in. It is known in PDU
+	// mode, and in text mode when the modem shows header details (see
+	// WithShowTextHeaders).
+	Encoding Encoding `json:"encoding,omitempty"`
+	// PartIndexes lists the storage index of every part when the message
+	// was joined from a concatenated message, in sequence order
+	PartIndexes []int `json:"part_indexes,omitempty"`
+	// Data is the raw user data of an 8-bit (binary) message, without any
+	// user data header. Message holds the same bytes as a string.
+	Data []byte `json:"data,omitempty"`
+	// Port is the destination application port from the user data header,
+	// or 0 when the message was not addressed to a port
+	Port uint16 `json:"port,omitempty"`
+	// Class is the message class, ClassFlash for a flash message that was
+	// meant to be displayed rather than stored, or "" when the message has
+	// none. It is known in PDU mode, and in text mode for +CMT deliveries
+	// and +CMGR reads when the modem shows header details (AT+CSDH=1).
+	Class MessageClass `json:"class,omitempty"`
 }
 
 func readUntilAny(r *bufio.Reader, delimiters []byte) (string, byte, error) {
@@ -50,29 +171,51 @@ func readUntilAny(r *bufio.Reader, delimiters []byte) (string, byte, error) {
 	}
 }
 
-func NewSMSHandler(portName string, baudRate int) (*SMSHandler, error) {
-	mode := &serial.Mode{
-		BaudRate: baudRate,
-		Parity:   serial.NoParity,
-		DataBits: 8,
-		StopBits: serial.OneStopBit}
+// NewSMSHandler opens the serial port at baudRate, initializes the modem and
+// returns a ready handler. Options tune optional behavior; see the With*
+// functions. It is Open with WithBaudRate(baudRate).
+func NewSMSHandler(portName string, baudRate int, opts ...Option) (*SMSHandler, error) {
+	return Open(portName, append(append([]Option(nil), opts...), WithBaudRate(baudRate))...)
+}
+
+// Open opens the serial port, initializes the modem and returns a ready
+// handler. Every setting, including the baud rate (WithBaudRate, default
+// 115200), is an option, so new settings never change its signature.
+func Open(portName string, opts ...Option) (*SMSHandler, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
-	port, err := serial.Open(portName, mode)
+	port, err := openPort(portName, cfg.baudRate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open serial port: %v", err)
+		return nil, err
 	}
+	port = withTrace(port, cfg.trace)
 
 	handler := &SMSHandler{
+		portName:   portName,
+		baudRate:   cfg.baudRate,
 		port:       port,
-		reader:     bufio.NewReader(port),
+		reader:     newPortReader(port, cfg.readBufferSize),
 		pauseChan:  make(chan bool),
 		resumeChan: make(chan bool),
+		cfg:        cfg,
+		metrics:    cfg.metrics,
+	}
+	if cfg.dedupEnabled {
+		handler.dedup = newDeduplicator(cfg.dedupWindow)
+	}
+	handler.stats.reset(handler.clock().Now())
+
+	if cfg.skipInit {
+		return handler, nil
 	}
 
 	// Initialize Modem
 	if err := handler.initModem(); err != nil {
 		if closeErr := port.Close(); closeErr != nil {
-			log.Printf("Error closing port after init failure: %v", closeErr)
+			handler.logger().Printf("Error closing port after init failure: %v", closeErr)
 		}
 		return nil, fmt.Errorf("failed to instantiate modem: %v", err)
 	}
@@ -80,129 +223,430 @@ func NewSMSHandler(portName string, baudRate int) (*SMSHandler, error) {
 	return handler, nil
 }
 
-// Close connection
+// newPortReader wraps the port in a buffered reader of the configured size,
+// or bufio's default size when none is set
+func newPortReader(port serial.Port, size int) *bufio.Reader {
+	if size <= 0 {
+		return bufio.NewReader(port)
+	}
+	return bufio.NewReaderSize(port, size)
+}
+
+// Close closes the connection. A command waiting for the modem returns
+// ErrClosed right away and the listener stops, rather than each running
+// into its timeout.
 func (s *SMSHandler) Close() error {
-	return s.port.Close()
+	s.lifecycleMu.Lock()
+	if !s.closed {
+		s.closed = true
+		s.closes++
+		if s.closedCh != nil {
+			close(s.closedCh)
+		}
+	}
+	s.lifecycleMu.Unlock()
+	s.closeSubscriptions()
+	return s.currentPort().Close()
 }
 
-// pauseListener temporarily pauses the SMS listener
-func (s *SMSHandler) pauseListener() {
-	if s.listening {
-		s.pauseChan <- true
+// pauseListener temporarily pauses the SMS listener and returns the func
+// that resumes it. Nothing is paused when the listener has stopped or stops
+// before taking the request.
+func (s *SMSHandler) pauseListener() (resume func()) {
+	run := s.currentListener()
+	if run == nil {
+		return func() {}
+	}
+
+	select {
+	case s.pauseChan <- true:
 		// Wait for confirmation that listener is paused
 		<-s.resumeChan
+		return func() { s.resumeChan <- true }
+	case <-run.done:
+	case <-s.closeSignal():
+	}
+	return func() {}
+}
+
+// exclusiveKey marks the context of the send holding exclusiveMu
+type exclusiveKey struct{}
+
+// beginExclusive claims the modem for a sequence of commands, such as a
+// send that switches settings, sends and restores them, so that nothing
+// else runs in between. Commands in the sequence pass the returned context;
+// end releases the modem.
+func (s *SMSHandler) beginExclusive() (ctx context.Context, end func()) {
+	s.exclusiveMu.Lock()
+	resume := s.pauseListener()
+	return context.WithValue(context.Background(), exclusiveKey{}, true), func() {
+		resume()
+		s.exclusiveMu.Unlock()
 	}
 }
 
-// resumeListener resumes the SMS listener
-func (s *SMSHandler) resumeListener() {
-	if s.listening {
-		s.resumeChan <- true
+// claimModem pauses the listener for one command and waits out any
+// exclusive sequence, unless ctx belongs to that sequence
+func (s *SMSHandler) claimModem(ctx context.Context) (release func()) {
+	if ctx.Value(exclusiveKey{}) != nil {
+		return func() {}
+	}
+	s.exclusiveMu.Lock()
+	resume := s.pauseListener()
+	return func() {
+		resume()
+		s.exclusiveMu.Unlock()
 	}
 }
 
+// commandGracePeriod is how much longer a command that has started to
+// answer gets after the command timeout to send its final result
+const commandGracePeriod = 2 * time.Second
+
 // sendATCommand sends an AT command and waits for response
 func (s *SMSHandler) sendATCommand(command string) (string, error) {
-	s.pauseListener()
-	defer s.resumeListener()
+	return s.sendATCommandContext(context.Background(), command)
+}
 
-	// Clear any pending data in the buffer
-	for s.reader.Buffered() > 0 {
-		_, _ = s.reader.ReadByte()
+// sendATCommandContext sends an AT command and waits for its response, giving
+// up when ctx is done. The outcome is recorded for Status. A cancelled
+// command's reader keeps draining the rest of the response in the background
+// and holds readerMu until it finishes or the port goes quiet, so the next
+// command or the listener never sees stale output. A command the modem never
+// answers fails with ErrNoResponse; one whose final result is still missing
+// after commandGracePeriod more fails with ErrIncompleteResponse and returns
+// what arrived.
+func (s *SMSHandler) sendATCommandContext(ctx context.Context, command string) (string, error) {
+	response, err := s.runATCommand(ctx, command)
+	s.recordCommand(response, err)
+	return response, err
+}
+
+// runATCommand does the work of sendATCommandContext
+func (s *SMSHandler) runATCommand(ctx context.Context, command string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	release := s.claimModem(ctx)
+	defer release()
+
+	// Wait for any reader left behind by a cancelled command
+	s.readerMu.Lock()
+	s.recoverComposition()
+
+	// Clear any pending data in the buffer, keeping notifications for the
+	// listener
+	s.setAsideBuffered()
+
+	// Bound each read so an abandoned reader cannot block forever
+	if err := s.port.SetReadTimeout(s.pollInterval()); err != nil {
+		s.logger().Printf("Error setting read timeout: %v", err)
 	}
 
 	// Send command
-	_, err := s.port.Write([]byte(command + "\r\n"))
+	_, err := s.port.Write([]byte(command + s.commandTerminator()))
 	if err != nil {
+		s.readerMu.Unlock()
 		return "", fmt.Errorf("failed to write command: %v", err)
 	}
 
 	// Read response with timeout
+	var responseMu sync.Mutex
 	response := ""
-	timeout := time.After(10 * time.Second)
-	done := make(chan bool)
+	timeout := s.clock().After(s.commandTimeout())
+	closed := s.closeSignal()
+	// done carries nil once the response is complete, or the read error
+	// that cut it short
+	done := make(chan error, 1)
+	// abandoned is closed once the caller stops waiting for the response
+	abandoned := make(chan struct{})
+	defer close(abandoned)
 
 	go func() {
+		defer s.readerMu.Unlock()
+
 		consecutiveEmpty := 0
+		afterHeader, inBody := false, false
+		// cmtHeader is a +CMT header that arrived mid-command, waiting
+		// for its body line
+		cmtHeader := ""
+		defer func() {
+			if cmtHeader != "" {
+				s.queueInterleavedURCs([]interleavedURC{{line: cmtHeader}})
+			}
+		}()
+		// partial holds a line cut short by a read that timed out
+		partial := ""
 		for {
-			line, err := s.reader.ReadString('\n')
+			raw, err := s.reader.ReadString('\n')
+			if errors.Is(err, io.ErrNoProgress) {
+				// The port's reads timed out with nothing new. The
+				// command timeout decides when to give up; once the
+				// caller has, the modem going quiet ends the drain.
+				select {
+				case <-abandoned:
+					done <- nil
+				default:
+					partial += raw
+					continue
+				}
+				break
+			}
+			if errors.Is(err, io.EOF) {
+				// The port has nothing more to send
+				done <- nil
+				break
+			}
 			if err != nil {
-				done <- true
+				done <- fmt.Errorf("failed to read response: %v", err)
 				break
 			}
+			raw, partial = partial+raw, ""
 
-			line = strings.TrimSpace(line)
+			line := strings.TrimSpace(raw)
 
 			// Skip echo of the command itself
 			if line == command {
 				continue
 			}
 
-			// Skip empty lines but track them
+			// Power-on notifications can interleave with the first commands
+			if isStartupURC(command, line) {
+				s.logger().Debugf("discarding startup message %q", line)
+				consecutiveEmpty = 0
+				continue
+			}
+
+			// The line right after a message header is the body, so an
+			// empty one is an empty body rather than framing
+			if line == "" && afterHeader {
+				responseMu.Lock()
+				response += s.bodyText(raw) + "\n"
+				responseMu.Unlock()
+				afterHeader = false
+				continue
+			}
+
+			// Skip empty lines but track them. A message dump can have
+			// blank lines between messages, so once one has started only
+			// its final result or the timeout ends it.
 			if line == "" {
 				consecutiveEmpty++
-				if consecutiveEmpty > 3 {
+				if consecutiveEmpty > 3 && !inBody {
 					// Too many empty lines, might be stuck
-					done <- true
+					done <- nil
 					break
 				}
 				continue
 			}
 			consecutiveEmpty = 0
 
-			response += line + "\n"
+			// A message arriving mid-command is not part of the response;
+			// it is queued for the listener instead of being lost
+			if cmtHeader != "" {
+				s.queueInterleavedURCs([]interleavedURC{{line: cmtHeader, body: line}})
+				cmtHeader = ""
+				continue
+			}
+			if strings.HasPrefix(line, "+CMTI:") {
+				s.queueInterleavedURCs([]interleavedURC{{line: line}})
+				continue
+			}
+			if strings.HasPrefix(line, "+CMT:") {
+				cmtHeader = line
+				continue
+			}
 
-			// Check for terminal responses
-			if strings.Contains(line, "OK") || strings.Contains(line, "ERROR") || strings.Contains(line, "+CME ERROR") {
-				done <- true
+			// Message bodies keep their whitespace with WithPreserveWhitespace
+			entry := line
+			if inBody && !strings.HasPrefix(line, "+CMGL:") {
+				entry = s.bodyText(raw)
+			}
+
+			responseMu.Lock()
+			response += entry + "\n"
+			responseMu.Unlock()
+
+			// The line after a message header is the body, even when it
+			// reads "OK"; any other final result line ends the response
+			if !afterHeader && isFinalResult(line) {
+				done <- nil
 				break
 			}
+			afterHeader = strings.HasPrefix(line, "+CMGL:") || strings.HasPrefix(line, "+CMGR:")
+			inBody = inBody || afterHeader
 		}
 	}()
 
 	select {
-	case <-done:
-		return strings.TrimSpace(response), nil
+	case err := <-done:
+		responseMu.Lock()
+		defer responseMu.Unlock()
+		return strings.TrimSpace(response), err
 	case <-timeout:
-		// Try to get whatever we have so far
-		return strings.TrimSpace(response), fmt.Errorf("command timeout")
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-closed:
+		return "", ErrClosed
+	}
+
+	responseMu.Lock()
+	answered := response != ""
+	responseMu.Unlock()
+	if !answered {
+		s.metricsRecorder().IncModemError(ErrorClassTimeout)
+		return "", fmt.Errorf("command timeout: %w", ErrNoResponse)
+	}
+
+	// The modem is answering, just slowly; give it a little longer to
+	// finish before giving up on the final result
+	var readErr error
+	select {
+	case readErr = <-done:
+	case <-s.clock().After(commandGracePeriod):
+		responseMu.Lock()
+		defer responseMu.Unlock()
+		s.metricsRecorder().IncModemError(ErrorClassTimeout)
+		return strings.TrimSpace(response), fmt.Errorf("command timeout: %w", ErrIncompleteResponse)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-closed:
+		return "", ErrClosed
 	}
+	responseMu.Lock()
+	defer responseMu.Unlock()
+	return strings.TrimSpace(response), readErr
+}
+
+// sendATCommandExpectOK sends an AT command and treats an ERROR reply as a
+// failure rather than a successful response
+func (s *SMSHandler) sendATCommandExpectOK(command string) (string, error) {
+	return s.sendATCommandExpectOKContext(context.Background(), command)
+}
+
+// sendATCommandExpectOKContext is sendATCommandExpectOK giving up when ctx
+// is done
+func (s *SMSHandler) sendATCommandExpectOKContext(ctx context.Context, command string) (string, error) {
+	response, err := s.sendATCommandContext(ctx, command)
+	if err != nil {
+		return response, err
+	}
+	if modemErr := parseModemError(response); modemErr != nil {
+		s.metricsRecorder().IncModemError(modemErr.class())
+		return response, fmt.Errorf("modem returned error: %w", modemErr)
+	}
+	return response, nil
 }
 
 // initModem initializes the modem with basic AT commands
 func (s *SMSHandler) initModem() error {
-	// Test AT communication
-	if _, err := s.sendATCommand("AT"); err != nil {
-		return fmt.Errorf("AT test failed: %v", err)
+	// Let a freshly powered-on modem finish reporting its startup state
+	if s.cfg.startupWait > 0 {
+		s.awaitStartup(s.cfg.startupWait)
+	}
+
+	// Test AT communication, giving a modem that was just plugged in time
+	// to wake up; only this probe is retried
+	if err := s.probeReady(); err != nil {
+		return err
+	}
+
+	// Unlock the SIM before anything that needs it (WithPIN)
+	if s.cfg.pin != "" {
+		if err := s.unlockSIM(s.cfg.pin); err != nil {
+			return err
+		}
+	}
+
+	// Report errors with a code or text rather than a bare ERROR, so
+	// ModemError can classify them
+	cmee := fmt.Sprintf("AT+CMEE=%d", s.errorReporting())
+	if _, err := s.sendATCommandExpectOK(cmee); err != nil {
+		s.logger().Printf("Could not set error reporting with %s: %v", cmee, err)
 	}
 
-	// Set text mode for SMS
-	if _, err := s.sendATCommand("AT+CMGF=1"); err != nil {
+	// Set the SMS message format
+	if s.cfg.mode == ModePDU {
+		if _, err := s.sendATCommand("AT+CMGF=0"); err != nil {
+			return fmt.Errorf("failed to set SMS PDU mode: %v", err)
+		}
+	} else if _, err := s.sendATCommand("AT+CMGF=1"); err != nil {
 		return fmt.Errorf("failed to set SMS text mode: %v", err)
 	}
+	if err := s.verifyMessageFormat(); err != nil {
+		return err
+	}
+	if s.cfg.showTextHeaders && s.cfg.mode != ModePDU {
+		if _, err := s.sendATCommandExpectOK("AT+CSDH=1"); err != nil {
+			s.logger().Printf("Could not enable text-mode header details: %v", err)
+		}
+	}
 
 	// Set character set to GSM
 	if _, err := s.sendATCommand("AT+CSCS=\"GSM\""); err != nil {
 		return fmt.Errorf("failed to set character set: %v", err)
 	}
+	if err := s.verifyCharset("GSM"); err != nil {
+		return err
+	}
+	s.charset = "GSM"
+
+	// Pick up model-specific settings; unknown models use the generic path
+	if _, err := s.DetectModemQuirks(); err != nil {
+		s.logger().Printf("Could not detect modem model, using generic settings: %v", err)
+	}
+
+	// Configure SMS storage location (SIM card unless the model prefers otherwise)
+	storage := s.modemQuirks().Storage
+	if storage == "" {
+		storage = "SM"
+	}
+	if err := s.selectStorage([]string{storage, storage, storage}); err != nil {
+		var mismatch *StorageMismatchError
+		if errors.As(err, &mismatch) {
+			return fmt.Errorf("failed to set SMS storage: %w", err)
+		}
+		s.logger().Printf("Could not confirm SMS storage %s: %v", storage, err)
+	}
+	s.storage = storage
 
-	// Configure SMS storage location (SIM card)
-	if _, err := s.sendATCommand("AT+CPMS=\"SM\",\"SM\",\"SM\""); err != nil {
-		return fmt.Errorf("failed to set SMS storage: %v", err)
+	if err := s.enableNotifications(); err != nil {
+		return err
+	}
+
+	// Vendor-specific setup supplied by the caller
+	return s.runInitCommands()
+}
+
+// enableNotifications turns on new-message indications, preferring the CNMI
+// setting from the quirk profile before trying the generic settings
+func (s *SMSHandler) enableNotifications() error {
+	quirks := s.modemQuirks()
+	if quirks.CNMI != "" {
+		if _, err := s.sendATCommandExpectOK("AT+CNMI=" + quirks.CNMI); err == nil {
+			s.cnmi = quirks.CNMI
+			return nil
+		}
+		s.logger().Printf("Quirk CNMI setting %q rejected, falling back to generic settings", quirks.CNMI)
 	}
 
 	// Enable SMS delivery notifications - try different settings for compatibility
-	_, err := s.sendATCommand("AT+CNMI=1,2,0,1,0")
+	cnmi := "1,2,0,1,0"
+	_, err := s.sendATCommand("AT+CNMI=" + cnmi)
 	if err != nil {
-		_, err = s.sendATCommand("AT+CNMI=2,1,0,2,0")
+		cnmi = "2,1,0,2,0"
+		_, err = s.sendATCommand("AT+CNMI=" + cnmi)
 		if err != nil {
-			_, err = s.sendATCommand("AT+CNMI=1,1,0,1,0")
+			cnmi = "1,1,0,1,0"
+			_, err = s.sendATCommand("AT+CNMI=" + cnmi)
 			if err != nil {
 				return fmt.Errorf("failed to enable SMS notifications: %v", err)
 			}
 		}
 	}
 
+	s.cnmi = cnmi
 	return nil
 }
 
@@ -217,57 +661,145 @@ func (s *SMSHandler) GetSignalStrength() (string, error) {
 
 // ReadSMS reads all SMS messages
 func (s *SMSHandler) ReadSMS() ([]SMS, error) {
-	response, err := s.sendATCommand("AT+CMGL=\"ALL\"")
+	return s.ReadSMSByStatus(StatusAll)
+}
+
+// ReadNewSMS reads only unread SMS messages
+func (s *SMSHandler) ReadNewSMS() ([]SMS, error) {
+	return s.ReadSMSByStatus(StatusUnread)
+}
+
+// ReadSMSByStatus reads the messages in the given status, or every message
+// for StatusAll
+func (s *SMSHandler) ReadSMSByStatus(status MessageStatus) ([]SMS, error) {
+	return s.ReadSMSContext(context.Background(), status)
+}
+
+// ReadSMSFromSender reads the messages in the given status sent by number.
+// Both sides are normalized with NormalizePhoneNumber and compared without
+// a leading '+', so "+1234567890" matches "1234567890".
+func (s *SMSHandler) ReadSMSFromSender(number string, status MessageStatus) ([]SMS, error) {
+	messages, err := s.ReadSMSByStatus(status)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read SMS: %v", err)
+		return nil, err
+	}
+
+	var matched []SMS
+	for _, sms := range messages {
+		if sameNumber(sms.Sender, number) {
+			matched = append(matched, sms)
+		}
 	}
+	return matched, nil
+}
 
-	return s.parseSMSList(response), nil
+// ReadSMSContext is ReadSMSByStatus with cancellation. Listing a large
+// mailbox can take several seconds; when ctx is done the call returns
+// ctx.Err() and the rest of the modem's response is drained in the
+// background before the next command runs.
+func (s *SMSHandler) ReadSMSContext(ctx context.Context, status MessageStatus) ([]SMS, error) {
+	messages, _, err := s.readSMSList(ctx, status)
+	return messages, err
 }
 
-// ReadNewSMS reads only unread SMS messages
-func (s *SMSHandler) ReadNewSMS() ([]SMS, error) {
-	response, err := s.sendATCommand("AT+CMGL=\"REC UNREAD\"")
+// ReadSMSRaw reads all messages like ReadSMS and also returns the raw
+// AT+CMGL response they were parsed from, for diagnosing modem-specific
+// formatting. On a failed command the raw string holds whatever the modem
+// sent before the failure.
+func (s *SMSHandler) ReadSMSRaw() ([]SMS, string, error) {
+	return s.readSMSList(context.Background(), StatusAll)
+}
+
+// readSMSList lists the messages in the given status, returning the raw
+// response alongside the parsed messages
+func (s *SMSHandler) readSMSList(ctx context.Context, status MessageStatus) ([]SMS, string, error) {
+	if !status.valid() {
+		return nil, "", fmt.Errorf("invalid message status %q", status)
+	}
+
+	cmd := fmt.Sprintf("AT+CMGL=\"%s\"", status)
+	if s.cfg.mode == ModePDU {
+		cmd = fmt.Sprintf("AT+CMGL=%d", status.pduCode())
+	}
+
+	response, err := s.sendATCommandContext(ctx, cmd)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read new SMS: %v", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, response, ctxErr
+		}
+		return nil, response, fmt.Errorf("failed to read SMS: %v", err)
+	}
+	if modemErr := finalResultError(response); modemErr != nil {
+		s.metricsRecorder().IncModemError(modemErr.class())
+		return nil, response, fmt.Errorf("failed to read SMS: %w", modemErr)
 	}
 
-	return s.parseSMSList(response), nil
+	if s.cfg.mode == ModePDU {
+		return s.parsePDUList(response), response, nil
+	}
+	return s.parseSMSList(response), response, nil
 }
 
-// parseSMSList parses the response from AT+CMGL command
+// parseSMSList parses the response from AT+CMGL command. Each message's
+// body runs from the line after its header to the next header, and the
+// final OK is recognized by position, as the response's last line, so a
+// body that reads "OK" is kept intact.
 func (s *SMSHandler) parseSMSList(response string) []SMS {
 	var messages []SMS
-	lines := strings.Split(response, "\n")
+	lines := strings.Split(strings.TrimSpace(response), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); isFinalResult(last) {
+		lines = lines[:len(lines)-1]
+	}
 
 	for i := 0; i < len(lines); i++ {
 		line := strings.TrimSpace(lines[i])
-		if strings.HasPrefix(line, "+CMGL:") {
-			// Parse header line: +CMGL: index,status,sender,date
-			parts := strings.Split(line, ",")
-			if len(parts) >= 4 {
-				var sms SMS
-				if _, err := fmt.Sscanf(parts[0], "+CMGL: %d", &sms.Index); err != nil {
-					log.Printf("Error parsing SMS index: %v", err)
-					continue
-				}
-				sms.Status = strings.Trim(parts[1], "\"")
-				sms.Sender = strings.Trim(parts[2], "\"")
-				sms.Date = strings.Trim(parts[3], "\"")
-
-				// Next line should contain the message
-				if i+1 < len(lines) {
-					sms.Message = strings.TrimSpace(lines[i+1])
-					i++ // Skip the message line in next iteration
-				}
-				messages = append(messages, sms)
-			}
+		if !strings.HasPrefix(line, "+CMGL:") {
+			continue
+		}
+
+		// Parse header line: +CMGL: index,status,sender,[name],date
+		sms, err := parseCMGLHeader(line)
+		if err != nil {
+			s.logger().Printf("Error parsing SMS header: %v", err)
+			continue
+		}
+
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "+CMGL:") {
+			end++
 		}
+		sms.Message = s.decodeTextBody(s.joinBody(lines[i+1:end]), -1)
+		i = end - 1
+		messages = append(messages, sms)
 	}
 
 	return messages
 }
 
+// joinBody joins the lines of a multi-line message body
+func (s *SMSHandler) joinBody(lines []string) string {
+	body := make([]string, 0, len(lines))
+	for _, line := range lines {
+		body = append(body, s.bodyText(line))
+	}
+	if s.cfg.preserveWhitespace {
+		// Blank lines before the next header or OK are framing
+		for len(body) > 0 && body[len(body)-1] == "" {
+			body = body[:len(body)-1]
+		}
+	}
+	return strings.Join(body, "\n")
+}
+
+// bodyText returns a received body line without its line ending, trimmed
+// unless WithPreserveWhitespace is set
+func (s *SMSHandler) bodyText(line string) string {
+	if s.cfg.preserveWhitespace {
+		return strings.TrimRight(line, "\r\n")
+	}
+	return strings.TrimSpace(line)
+}
+
 // DeleteSMS deletes an SMS message by index
 func (s *SMSHandler) DeleteSMS(index int) error {
 	cmd := fmt.Sprintf("AT+CMGD=%d", index)
@@ -278,55 +810,119 @@ func (s *SMSHandler) DeleteSMS(index int) error {
 	return nil
 }
 
-// ListenForIncomingSMS listens for incoming SMS notifications
+// recoverListener reports a panic in the listener goroutine. The panic is
+// swallowed unless WithListenerPanics was given, in which case it is
+// re-raised after logging so the process crashes with the full trace.
+func (s *SMSHandler) recoverListener() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	s.logger().Printf("SMS listener recovered from panic: %v\n%s", r, debug.Stack())
+	if s.cfg.listenerPanics {
+		panic(r)
+	}
+}
+
+// ListenForIncomingSMS listens for incoming SMS notifications. A listener
+// that is already running is stopped first, so only one reads the port.
+// The callback runs on its own goroutine, one message at a time in arrival
+// order, so it may send messages itself, for example with Reply.
 func (s *SMSHandler) ListenForIncomingSMS(callback func(SMS)) {
-	s.listening = true
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Printf("SMS listener recovered from panic: %v\n", r)
+	for {
+		s.listenMu.Lock()
+		if s.listener == nil {
+			s.startListener(callback)
+			s.listenMu.Unlock()
+			return
+		}
+		s.listenMu.Unlock()
+		_, _ = s.stopListener(context.Background())
+	}
+}
+
+// listen runs the listener goroutine for run. After a fatal read error it
+// hands over to superviseReconnect once run has exited.
+func (s *SMSHandler) listen(run *listenerRun) {
+	if err := s.readIncoming(run); err != nil {
+		s.superviseReconnect(run.callback, err)
+	}
+}
+
+// readIncoming reads notifications until run is stopped or the handler is
+// closed. It returns the read error when the port failed and
+// WithAutoReconnect is set.
+func (s *SMSHandler) readIncoming(run *listenerRun) (fatal error) {
+	defer s.listenerExited(run)
+	defer s.recoverListener()
+
+	callback := run.deliver
+	closed := s.closeSignal()
+	for {
+		select {
+		case <-run.stop:
+			return nil
+		case <-closed:
+			return nil
+		case <-s.pauseChan:
+			// Listener paused, confirm and wait for resume
+			s.resumeChan <- true
+			<-s.resumeChan
+		default:
+			// Notifications that arrived during a send come first
+			for _, urc := range s.takeInterleavedURCs() {
+				s.replayURC(urc, callback, func(line string) { s.readStored(run, line) })
+			}
+
+			// Check if there's data available to read
+			if err := s.currentPort().SetReadTimeout(s.pollInterval()); err != nil {
+				s.logger().Printf("Error setting read timeout: %v", err)
+				continue
 			}
-		}()
 
-		for s.listening {
-			select {
-			case <-s.pauseChan:
-				// Listener paused, confirm and wait for resume
-				s.resumeChan <- true
-				<-s.resumeChan
-			default:
-				// Check if there's data available to read
-				if err := s.port.SetReadTimeout(100 * time.Millisecond); err != nil {
-					log.Printf("Error setting read timeout: %v", err)
+			// Read line by line to properly handle multi-line messages
+			s.readerMu.Lock()
+			line, err := s.reader.ReadString('\n')
+			s.readerMu.Unlock()
+			if err != nil && s.isClosed() {
+				// Close stops the listener
+				return nil
+			}
+			if isFatalReadError(err) && s.cfg.reconnect != nil {
+				// Stop this listener; a new one starts once reconnected
+				return err
+			}
+			if err == nil {
+				line = strings.TrimSpace(line)
+				if line == "" {
 					continue
 				}
 
-				// Read line by line to properly handle multi-line messages
-				line, err := s.reader.ReadString('\n')
-				if err == nil {
-					line = strings.TrimSpace(line)
-					if line == "" {
-						continue
-					}
+				// Filter out AT command responses and other non-SMS lines
+				if s.isATResponse(line) {
+					continue
+				}
 
-					// Filter out AT command responses and other non-SMS lines
-					if s.isATResponse(line) {
-						continue
-					}
+				// Check for direct SMS delivery: +CMT: "sender","","date"
+				if strings.HasPrefix(line, "+CMT:") {
+					s.handleCMTMessage(line, callback)
+				}
 
-					// Check for direct SMS delivery: +CMT: "sender","","date"
-					if strings.HasPrefix(line, "+CMT:") {
-						s.handleCMTMessage(line, callback)
-					}
+				// Also check for stored message notifications: +CMTI: "SM",index
+				if strings.HasPrefix(line, "+CMTI:") {
+					s.readStored(run, line)
+					continue
+				}
 
-					// Also check for stored message notifications: +CMTI: "SM",index
-					if strings.HasPrefix(line, "+CMTI:") {
-						s.handleCMTIMessage(line, callback)
-					}
+				// Anything else is an unsolicited result for the URC callback
+				if !strings.HasPrefix(line, "+CMT:") {
+					s.recordUnhandled(line)
+					s.dispatchURC(line)
 				}
 			}
 		}
-	}()
+	}
 }
 
 // isATResponse checks if a line is an AT command or response that should be filtered out
@@ -355,25 +951,17 @@ func (s *SMSHandler) isATResponse(line string) bool {
 
 // handleCMTMessage handles direct SMS delivery notifications
 func (s *SMSHandler) handleCMTMessage(line string, callback func(SMS)) {
-	// Parse CMT header: +CMT: "+11234567890","","25/07/21,21:07:17-28"
-	parts := strings.Split(line, ",")
-	if len(parts) < 3 {
+	// In PDU mode the header is just +CMT: [<alpha>],<length>
+	if s.cfg.mode == ModePDU {
+		s.handleCMTPDU(callback)
 		return
 	}
 
-	var sms SMS
-
-	// Extract sender from first part, removing "+CMT: " prefix safely
-	senderPart := parts[0]
-	if len(senderPart) > 6 { // "+CMT: " is 6 characters
-		sms.Sender = strings.Trim(senderPart[6:], "\"")
-	} else {
-		return // Invalid format
-	}
-
-	// Extract date from last part
-	if len(parts) >= 3 {
-		sms.Date = strings.Trim(parts[2], "\"")
+	// Parse CMT header: +CMT: "+11234567890","","25/07/21,21:07:17-28"
+	sms, details, err := parseCMTHeader(line)
+	if err != nil {
+		s.recordUnhandled(line)
+		return
 	}
 
 	// Now read the actual message content that follows the header
@@ -381,31 +969,36 @@ func (s *SMSHandler) handleCMTMessage(line string, callback func(SMS)) {
 	s.readerMu.Lock()
 	defer s.readerMu.Unlock()
 
+	// With AT+CSDH=1 the header ends with the body length, which lets us
+	// finish as soon as the whole body has arrived
+	expectedLength := details.length
+
 	// Read the message content
 	messageLines := []string{}
-	timeout := time.After(2 * time.Second)
+	timeout := s.clock().After(s.receiveTimeout())
 
 	for {
 		select {
 		case <-timeout:
 			// If we timeout, use what we have
 			if len(messageLines) > 0 {
-				sms.Message = strings.Join(messageLines, "\n")
-				callback(sms)
+				sms.Message = s.decodeTextBody(strings.Join(messageLines, "\n"), details.dcs)
+				s.deliver(sms, callback)
 			}
 			return
 		default:
 			// Try to read a line
-			if err := s.port.SetReadTimeout(100 * time.Millisecond); err != nil {
-				log.Printf("Error setting read timeout in handleCMTMessage: %v", err)
+			if err := s.port.SetReadTimeout(s.pollInterval()); err != nil {
+				s.logger().Printf("Error setting read timeout in handleCMTMessage: %v", err)
 				continue
 			}
-			line, err := s.reader.ReadString('\n')
+			raw, err := s.reader.ReadString('\n')
 			if err == nil {
-				line = strings.TrimSpace(line)
+				line := strings.TrimSpace(raw)
+				body := s.bodyText(raw)
 
 				// Skip empty lines at the beginning
-				if line == "" && len(messageLines) == 0 {
+				if body == "" && len(messageLines) == 0 {
 					continue
 				}
 
@@ -415,19 +1008,24 @@ func (s *SMSHandler) handleCMTMessage(line string, callback func(SMS)) {
 					strings.HasPrefix(line, "AT+") {
 					// We've hit the next command/notification, so we're done
 					if len(messageLines) > 0 {
-						sms.Message = strings.Join(messageLines, "\n")
-						callback(sms)
+						sms.Message = s.decodeTextBody(strings.Join(messageLines, "\n"), details.dcs)
+						s.deliver(sms, callback)
 					}
 					return
 				}
 
 				// This is part of the message
-				if line != "" {
-					messageLines = append(messageLines, line)
+				if body != "" {
+					messageLines = append(messageLines, body)
+					if expectedLength > 0 && utf8.RuneCountInString(strings.Join(messageLines, "\n")) >= expectedLength {
+						sms.Message = s.decodeTextBody(strings.Join(messageLines, "\n"), details.dcs)
+						s.deliver(sms, callback)
+						return
+					}
 				} else if len(messageLines) > 0 {
 					// Empty line after we've started collecting message - we're done
-					sms.Message = strings.Join(messageLines, "\n")
-					callback(sms)
+					sms.Message = s.decodeTextBody(strings.Join(messageLines, "\n"), details.dcs)
+					s.deliver(sms, callback)
 					return
 				}
 			}
@@ -437,147 +1035,561 @@ func (s *SMSHandler) handleCMTMessage(line string, callback func(SMS)) {
 
 // handleCMTIMessage handles stored message notifications
 func (s *SMSHandler) handleCMTIMessage(line string, callback func(SMS)) {
-	parts := strings.Split(line, ",")
-	if len(parts) >= 2 {
-		var index int
-		if _, err := fmt.Sscanf(parts[1], "%d", &index); err != nil {
-			log.Printf("Error parsing SMS index from CMTI: %v", err)
-			return
-		}
+	index, err := parseCMTIIndex(line)
+	if err != nil {
+		s.logger().Printf("Skipping SMS notification: %v", err)
+		s.recordUnhandled(line)
+		return
+	}
 
-		// Read the specific SMS message
-		sms, err := s.readSMSByIndex(index)
-		if err == nil {
-			callback(sms)
-		}
+	// Read the specific SMS message
+	sms, info, err := s.readMessageByIndex(index)
+	if err == nil {
+		s.assemble(sms, info, callback)
+		s.discardFlash(sms, index)
+		s.keepRoom()
 	}
 }
 
 // readSMSByIndex reads a specific SMS message by index
 func (s *SMSHandler) readSMSByIndex(index int) (SMS, error) {
+	sms, _, err := s.readMessageByIndex(index)
+	return sms, err
+}
+
+// readMessageByIndex reads a message by index, also returning its
+// concatenation header in PDU mode
+func (s *SMSHandler) readMessageByIndex(index int) (SMS, concatInfo, error) {
 	cmd := fmt.Sprintf("AT+CMGR=%d", index)
 	response, err := s.sendATCommand(cmd)
 	if err != nil {
-		return SMS{}, fmt.Errorf("failed to read SMS: %v", err)
+		return SMS{}, concatInfo{}, fmt.Errorf("failed to read SMS: %v", err)
+	}
+	if modemErr := finalResultError(response); modemErr != nil {
+		if modemErr.Kind == "CMS" && modemErr.Code == cmsInvalidIndex {
+			return SMS{}, concatInfo{}, errEmptySlot
+		}
+		return SMS{}, concatInfo{}, fmt.Errorf("failed to read SMS: %w", modemErr)
+	}
+	if !strings.Contains(response, "+CMGR:") {
+		return SMS{}, concatInfo{}, errEmptySlot
+	}
+
+	if s.cfg.mode == ModePDU {
+		return parsePDURead(response, index)
 	}
 
 	lines := strings.Split(response, "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); len(lines) > 1 && isFinalResult(last) {
+		lines = lines[:len(lines)-1]
+	}
 	for i, line := range lines {
 		line = strings.TrimSpace(line)
 		if strings.HasPrefix(line, "+CMGR:") {
-			// Parse header line: +CMGR: status,sender,date
-			parts := strings.Split(line, ",")
-			if len(parts) >= 3 {
-				var sms SMS
-				sms.Index = index
-				sms.Status = strings.Trim(parts[0][7:], "\"") // Remove "+CMGR: "
-				sms.Sender = strings.Trim(parts[1], "\"")
-				sms.Date = strings.Trim(parts[2], "\"")
-
-				// Next line should contain the message
-				if i+1 < len(lines) {
-					sms.Message = strings.TrimSpace(lines[i+1])
-				}
-				return sms, nil
+			// Parse header line: +CMGR: status,sender,[name],date
+			sms, details, err := parseCMGRHeader(line)
+			if err != nil {
+				return SMS{}, concatInfo{}, fmt.Errorf("failed to parse SMS: %v", err)
 			}
+			sms.Index = index
+
+			// Every line up to the final result is the message
+			sms.Message = s.decodeTextBody(s.joinBody(lines[i+1:]), details.dcs)
+			return sms, concatInfo{}, nil
 		}
 	}
 
-	return SMS{}, fmt.Errorf("failed to parse SMS")
+	return SMS{}, concatInfo{}, fmt.Errorf("failed to parse SMS")
 }
 
-func (s *SMSHandler) SendSMS(phoneNumber, message string) error {
-	s.pauseListener()
-	defer s.resumeListener()
+// SendSMS sends a text message to the given phone number. Send options
+// adjust how this particular message is sent. Use SendSMSRef to also get
+// the message reference.
+//
+// The message always goes out from the SIM's own number. The SMS-SUBMIT a
+// modem hands to the network has no originating address field, so a
+// branded alphanumeric sender ID has to be set by an SMS gateway or
+// provider API instead; WithSenderID fails with ErrNotSupported.
+func (s *SMSHandler) SendSMS(phoneNumber, message string, opts ...SendOption) error {
+	_, err := s.SendSMSRef(phoneNumber, message, opts...)
+	return err
+}
 
-	// Clear any pending data in the buffer
-	for s.reader.Buffered() > 0 {
-		_, _ = s.reader.ReadByte()
+// SendSMSRef sends a text message like SendSMS and returns the message
+// reference from the modem's +CMGS: reply, which delivery reports quote to
+// identify the message. The reference is -1 if the modem accepted the
+// message without reporting a readable one.
+//
+// In PDU mode a message too long for one SMS is sent as a concatenated
+// message, one part after another, and the first part's reference is
+// returned. If a part fails, the error is a *MultipartSendError listing the
+// references of the parts already sent. In text mode such a message is
+// rejected with a *MessageTooLongError. Text-mode messages with characters
+// the GSM character set cannot carry at the prompt, such as € or [, are
+// sent in UCS2, which limits them to 70 characters.
+func (s *SMSHandler) SendSMSRef(phoneNumber, message string, opts ...SendOption) (int, error) {
+	if err := s.beginSend(); err != nil {
+		return -1, err
 	}
+	defer s.endSend()
 
-	// Small delay to ensure modem is ready
-	time.Sleep(100 * time.Millisecond)
+	return s.sendSMS(phoneNumber, message, opts...)
+}
+
+// sendSMS sends a message for SendSMSRef or the send queue; the caller has
+// already registered the send with beginSend
+func (s *SMSHandler) sendSMS(phoneNumber, message string, opts ...SendOption) (int, error) {
+	o := applySendOptions(opts)
+	if o.senderID != "" {
+		if err := validateSenderID(o.senderID); err != nil {
+			return -1, err
+		}
+		return -1, fmt.Errorf("sender ID %q cannot be set when sending through a modem, which always sends from the SIM's own number: %w", o.senderID, ErrNotSupported)
+	}
+	enc, err := resolveEncoding(message, o.encoding)
+	if err != nil {
+		return -1, err
+	}
+	if enc == EncodingUCS2 && o.encoding != "" && s.cfg.mode != ModePDU {
+		return -1, fmt.Errorf("forcing UCS2 requires PDU mode: %w", ErrNotSupported)
+	}
+	// In the GSM character set, text mode has no way to write extension or
+	// non-ASCII characters, so such messages are sent in UCS2 instead
+	textUCS2 := s.cfg.mode != ModePDU && o.encoding == "" &&
+		strings.EqualFold(s.charset, "GSM") && needsUCS2Text(message)
+	if textUCS2 {
+		enc = EncodingUCS2
+	}
+	_, segments := segmentCountFor(message, enc)
+	if limit := s.maxSegments(); segments > limit {
+		return -1, &SegmentLimitError{Segments: segments, MaxSegments: limit}
+	}
+	if s.cfg.mode == ModePDU {
+		if segments > 1 {
+			ref, err := s.sendMultipart(phoneNumber, message, enc, o)
+			if err == nil {
+				s.checkSentStorage(phoneNumber, message)
+			}
+			return ref, err
+		}
+	} else if err := checkSingleSegment(message, enc); err != nil {
+		return -1, err
+	}
+	if s.cfg.mode != ModePDU {
+		if err := checkTextBody(message); err != nil {
+			return -1, err
+		}
+	}
 
-	// Start SMS composition
-	cmd := fmt.Sprintf("AT+CMGS=\"%s\"", phoneNumber)
+	// The UCS2 character set applies to the number as well as the body
+	address, body := phoneNumber, message
+	if textUCS2 {
+		address, body = encodeHexUCS2(phoneNumber), encodeHexUCS2(message)
+	}
+	cmd := fmt.Sprintf("AT+CMGS=\"%s\"", address)
+	if toda := o.addressType.resolve(phoneNumber); toda != 0 {
+		cmd += fmt.Sprintf(",%d", toda)
+	}
+	if s.cfg.mode == ModePDU {
+		pdu, length, err := encodeSubmitPDU(phoneNumber, message, o.addressType, enc, o.relativeValidity())
+		if err != nil {
+			return -1, fmt.Errorf("failed to encode PDU: %v", err)
+		}
+		cmd, body = fmt.Sprintf("AT+CMGS=%d", length), pdu
+	}
 	// fmt.Printf("Sending command: %s\n", cmd)
 
-	// Send the AT+CMGS command with just CR
-	_, err := s.port.Write([]byte(cmd + "\r"))
+	// Settings switched for this message stay in force, with nothing else
+	// sent, until they are restored
+	ctx := context.Background()
+	validity := s.cfg.mode != ModePDU && o.validity > 0
+	if validity || textUCS2 {
+		var end func()
+		ctx, end = s.beginExclusive()
+		defer end()
+	}
+	if validity {
+		restore, err := s.applyValidityPeriod(ctx, o.validity)
+		if err != nil {
+			return -1, err
+		}
+		defer restore()
+	}
+	if textUCS2 {
+		restore, err := s.applyTextUCS2(ctx)
+		if err != nil {
+			return -1, err
+		}
+		defer restore()
+	}
+
+	s.waitForSendSlot()
+
+	progress := o.progressFor(1, 1)
+	response, err := s.composeMessage(ctx, cmd, body, "+CMGS:", 30*time.Second, progress)
+	if err != nil {
+		s.metricsRecorder().IncSendError(errorClass(err))
+		return -1, err
+	}
+	s.metricsRecorder().IncSent()
+	progress.report(StagePartSent)
+	s.checkSentStorage(phoneNumber, message)
+
+	ref, err := parseResultNumber(response, "+CMGS:")
+	if err != nil {
+		s.logger().Printf("Sent SMS without a readable message reference: %v", err)
+		return -1, nil
+	}
+	return ref, nil
+}
+
+// Characters that end a message body at the '>' prompt
+const (
+	// ComposeSubmit (Ctrl+Z) sends or stores the message
+	ComposeSubmit = "\x1A"
+	// ComposeCancel (ESC) aborts the composition without sending
+	ComposeCancel = "\x1B"
+)
+
+// ErrCompositionCancelled is returned by sends when the handler is
+// configured with WithComposeTerminator(ComposeCancel)
+var ErrCompositionCancelled = errors.New("message composition cancelled with ESC")
+
+// UnsafeTextError is returned when a message cannot be sent in text mode
+// because it contains a control character the modem would act on: Ctrl+Z
+// ends the body early and sends a truncated message, ESC cancels it, and
+// other control characters are handled inconsistently between modems.
+// Double quotes, newlines and carriage returns are safe in the body. Send
+// such messages in PDU mode (WithMode(ModePDU)), where they are encoded.
+type UnsafeTextError struct {
+	// Char is the offending character
+	Char rune
+	// Offset is its byte offset in the message
+	Offset int
+}
+
+func (e *UnsafeTextError) Error() string {
+	return fmt.Sprintf("message contains control character %U at offset %d, which cannot be sent in text mode; use PDU mode", e.Char, e.Offset)
+}
+
+// checkTextBody rejects message bodies that would be cut short or
+// misinterpreted when written at the text-mode '>' prompt
+func checkTextBody(message string) error {
+	for i, r := range message {
+		if (r < 0x20 && r != '\n' && r != '\r') || r == 0x7F {
+			return &UnsafeTextError{Char: r, Offset: i}
+		}
+	}
+	return nil
+}
+
+// hasPrompt reports whether buf contains the SMS input prompt, "> " at the
+// start of a line. A bare '>' is not enough: it can appear in an echoed
+// command or an unsolicited result, so it must start a line. Modems that
+// send '>' without the trailing space are handled by hasBarePrompt.
+func hasPrompt(buf []byte) bool {
+	for i := 0; i+1 < len(buf); i++ {
+		if buf[i] == '>' && buf[i+1] == ' ' && atLineStart(buf, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBarePrompt reports whether buf ends with a '>' at the start of a line.
+// Some modems send just that and wait for input; composeMessage accepts it
+// as the prompt once nothing more arrives.
+func hasBarePrompt(buf []byte) bool {
+	n := len(buf)
+	return n > 0 && buf[n-1] == '>' && atLineStart(buf, n-1)
+}
+
+// atLineStart reports whether buf[i] is the first byte of a line
+func atLineStart(buf []byte, i int) bool {
+	return i == 0 || buf[i-1] == '\n' || buf[i-1] == '\r'
+}
+
+// waitForSendSlot blocks until the configured minimum interval since the
+// previous send has passed, then claims the slot for the caller
+func (s *SMSHandler) waitForSendSlot() {
+	if s.cfg.minSendInterval <= 0 {
+		return
+	}
+
+	s.sendGateMu.Lock()
+	defer s.sendGateMu.Unlock()
+
+	if wait := s.cfg.minSendInterval - s.since(s.lastSend); wait > 0 {
+		s.clock().Sleep(wait)
+	}
+	s.lastSend = s.clock().Now()
+}
+
+// composeMessage runs a prompt-based command such as AT+CMGS or AT+CMGW: it
+// sends the command, waits for the '>' prompt, writes the body terminated by
+// Ctrl+Z and waits for a response containing resultPrefix. The accumulated
+// response is returned on success. progress, which may be nil, is told as
+// each stage is reached.
+func (s *SMSHandler) composeMessage(ctx context.Context, cmd, message, resultPrefix string, responseTimeout time.Duration, progress composeProgress) (string, error) {
+	release := s.claimModem(ctx)
+	defer release()
+
+	// The send owns the reader until it returns. Everything is read through
+	// s.reader, never the port directly, so nothing the reader has already
+	// buffered is skipped or split from what follows.
+	s.readerMu.Lock()
+	defer s.readerMu.Unlock()
+	s.recoverComposition()
+
+	// Whatever is left in the buffer predates the command; keep its
+	// notifications for the listener rather than mistake it for the reply
+	s.setAsideBuffered()
+
+	// Small delay to ensure modem is ready (WithComposeDelays)
+	s.clock().Sleep(s.cfg.commandDelay)
+
+	// Command name for error messages, e.g. "AT+CMGS"
+	name := cmd
+	if i := strings.Index(cmd, "="); i >= 0 {
+		name = cmd[:i]
+	}
+
+	// Send the command with just CR unless configured otherwise
+	_, err := s.port.Write([]byte(cmd + s.commandLineEnding()))
 	if err != nil {
-		return fmt.Errorf("failed to write AT+CMGS command: %v", err)
+		return "", classify(ErrorClassWrite, fmt.Errorf("failed to write %s command: %v", name, err))
 	}
+	// Until the modem answers, it may be sitting at the prompt
+	s.composeOpen = true
 
 	// Wait for response and '>' prompt
 	promptBuffer := make([]byte, 0, 256)
 	promptReceived := false
-	startTime := time.Now()
+	startTime := s.clock().Now()
+	buf := make([]byte, 1)
 
-	for !promptReceived && time.Since(startTime) < 10*time.Second {
+	for !promptReceived && s.since(startTime) < 10*time.Second {
 		// Set a short read timeout
 		if err := s.port.SetReadTimeout(100 * time.Millisecond); err != nil {
-			log.Printf("Error setting read timeout while waiting for prompt: %v", err)
+			s.logger().Printf("Error setting read timeout while waiting for prompt: %v", err)
 		}
 
-		buf := make([]byte, 1)
-		n, err := s.port.Read(buf)
+		n, err := s.reader.Read(buf)
+		if err != nil && s.isClosed() {
+			return "", ErrClosed
+		}
+		if n == 0 && hasBarePrompt(promptBuffer) {
+			// A '>' with nothing after it; the modem is waiting for input
+			promptReceived = true
+		}
+		if n == 0 && !promptReceived {
+			s.clock().Sleep(composeIdleWait)
+		}
 		if err == nil && n > 0 {
 			promptBuffer = append(promptBuffer, buf[0])
 			// fmt.Printf("Read: %d ('%c') | Buffer: %q\n", buf[0], buf[0], string(promptBuffer))
 
 			// Check if we've received the '>' prompt
-			if bytes.Contains(promptBuffer, []byte(">")) {
+			if hasPrompt(promptBuffer) {
 				promptReceived = true
 				// fmt.Println("Prompt received!")
 			}
+
+			// The modem may reject the command (e.g. a bad number) instead
+			// of prompting; report that right away rather than timing out
+			if buf[0] == '\n' {
+				if modemErr := parseModemError(string(promptBuffer)); modemErr != nil {
+					s.composeOpen = false
+					return "", fmt.Errorf("%s rejected: %w", name, modemErr)
+				}
+				if hasBusyResult(string(promptBuffer)) {
+					s.composeOpen = false
+					return "", classify(ErrorClassBusy, fmt.Errorf("%s: %w", name, ErrModemBusy))
+				}
+			}
 		}
 	}
 
 	if !promptReceived {
-		return fmt.Errorf("timeout waiting for SMS prompt, got: %q", string(promptBuffer))
+		return "", classify(ErrorClassPromptTimeout, fmt.Errorf("timeout waiting for SMS prompt, got: %q (% x)", string(promptBuffer), promptBuffer))
 	}
 
-	// Small delay after prompt
-	time.Sleep(100 * time.Millisecond)
+	progress.report(StagePromptReceived)
+
+	// Small delay after prompt (WithComposeDelays)
+	s.clock().Sleep(s.cfg.promptDelay)
 
 	// fmt.Printf("Sending message: %s\n", message)
 
-	// Send message content followed by Ctrl+Z
-	fullMessage := message + "\x1A" // \x1A is Ctrl+Z
-	_, err = s.port.Write([]byte(fullMessage))
-	if err != nil {
-		return fmt.Errorf("failed to send message: %v", err)
+	// Send message content followed by the terminator, normally Ctrl+Z
+	terminator := s.composeTerminator()
+	fullMessage := message + terminator
+	if err := s.writeBody([]byte(fullMessage)); err != nil {
+		// Don't leave the modem in composition mode swallowing commands
+		s.logCancelError(s.abortComposition())
+		return "", classify(ErrorClassWrite, fmt.Errorf("failed to send message: %v", err))
+	}
+
+	if terminator == ComposeCancel {
+		s.awaitCancelAck()
+		s.composeOpen = false
+		return "", ErrCompositionCancelled
 	}
+	progress.report(StageBodyWritten)
+	progress.report(StageAwaitingConfirmation)
 
 	// fmt.Println("Message sent with Ctrl+Z, waiting for response...")
 
 	// Read response
-	responseBuffer := make([]byte, 0, 1024)
-	startTime = time.Now()
+	// URCs arriving in the middle of the response are left for the listener
+	response := &sendResponse{resultPrefix: resultPrefix, buf: make([]byte, 0, 1024)}
+	defer func() { s.queueInterleavedURCs(response.urcs) }()
+	startTime = s.clock().Now()
+
+	buf = make([]byte, 128)
+	var okAt time.Time
+	for s.since(startTime) < responseTimeout {
+		// A bare OK with no result line after it still means success
+		if !okAt.IsZero() && s.since(okAt) >= s.okOnlyGrace() {
+			s.composeOpen = false
+			return response.String(), nil
+		}
 
-	for time.Since(startTime) < 30*time.Second {
 		if err := s.port.SetReadTimeout(100 * time.Millisecond); err != nil {
-			log.Printf("Error setting read timeout while waiting for SMS response: %v", err)
+			s.logger().Printf("Error setting read timeout while waiting for SMS response: %v", err)
 		}
 
-		buf := make([]byte, 128)
-		n, err := s.port.Read(buf)
-		if err == nil && n > 0 {
-			responseBuffer = append(responseBuffer, buf[:n]...)
-			response := string(responseBuffer)
-			// fmt.Printf("Response so far: %q\n", response)
-
-			// Check for completion
-			if strings.Contains(response, "+CMGS:") || strings.Contains(response, "OK") {
-				if strings.Contains(response, "+CMGS:") {
-					return nil
-				}
+		n, err := s.reader.Read(buf)
+		if err != nil && s.isClosed() {
+			return response.String(), ErrClosed
+		}
+		if n == 0 {
+			s.clock().Sleep(composeIdleWait)
+			continue
+		}
+		if err == nil {
+			response.feed(buf[:n])
+			if response.ok && okAt.IsZero() {
+				okAt = s.clock().Now()
+			}
+			if response.done || response.modemErr != nil || response.busy {
+				s.composeOpen = false
+			}
+
+			// Finished once the result line is complete
+			if response.done {
+				return response.String(), nil
+			}
+			if response.modemErr != nil {
+				return response.String(), fmt.Errorf("SMS failed: %w", response.modemErr)
 			}
-			if strings.Contains(response, "ERROR") || strings.Contains(response, "+CMS ERROR") {
-				return fmt.Errorf("SMS failed: %s", response)
+			if response.busy {
+				return response.String(), classify(ErrorClassBusy, fmt.Errorf("SMS failed: %w", ErrModemBusy))
 			}
 		}
 	}
 
-	return fmt.Errorf("SMS timeout - no valid response received")
+	// The terminator may have been lost; make sure composition is closed
+	s.logCancelError(s.abortComposition())
+	return response.String(), classify(ErrorClassTimeout, fmt.Errorf("SMS timeout - no valid response received"))
+}
+
+// composeIdleWait is how long a send pauses after a read that returned
+// nothing. Serial ports often return at once rather than waiting out the
+// read timeout, and without the pause the wait for the prompt or the
+// result would keep a core busy.
+const composeIdleWait = 10 * time.Millisecond
+
+// writeBody writes a message body at the prompt, in pieces of at most
+// WithWriteChunkSize bytes when that is set
+func (s *SMSHandler) writeBody(body []byte) error {
+	size := s.cfg.writeChunkSize
+	if size <= 0 {
+		_, err := s.port.Write(body)
+		return err
+	}
+
+	for len(body) > 0 {
+		n := size
+		if n > len(body) {
+			n = len(body)
+		}
+		if _, err := s.port.Write(body[:n]); err != nil {
+			return err
+		}
+		body = body[n:]
+		if len(body) > 0 {
+			s.clock().Sleep(s.cfg.writeChunkDelay)
+		}
+	}
+	return nil
+}
+
+// awaitCancelAck consumes the OK some modems send after an ESC-terminated
+// composition so it does not leak into the next command
+func (s *SMSHandler) awaitCancelAck() {
+	var buf []byte
+	deadline := s.clock().Now().Add(time.Second)
+	for s.clock().Now().Before(deadline) && !bytes.Contains(buf, []byte("OK")) {
+		if err := s.port.SetReadTimeout(100 * time.Millisecond); err != nil {
+			s.logger().Printf("Error setting read timeout after cancelling composition: %v", err)
+		}
+		chunk := make([]byte, 64)
+		n, err := s.reader.Read(chunk)
+		if err != nil || n == 0 {
+			s.clock().Sleep(10 * time.Millisecond)
+			continue
+		}
+		buf = append(buf, chunk[:n]...)
+	}
+}
+
+// CancelComposition sends ESC to abort a message composition left open at
+// the '>' prompt, for example after an interrupted send. Until it is
+// cancelled, the modem treats every following AT command as message text.
+// Sending it when no composition is open is harmless. It waits for a send
+// in progress to finish rather than aborting it.
+func (s *SMSHandler) CancelComposition() error {
+	release := s.claimModem(context.Background())
+	defer release()
+
+	s.readerMu.Lock()
+	defer s.readerMu.Unlock()
+
+	if err := s.abortComposition(); err != nil {
+		return fmt.Errorf("failed to cancel composition: %v", err)
+	}
+	s.awaitCancelAck()
+	return nil
+}
+
+// abortComposition is CancelComposition for use inside a send that already
+// owns the port
+func (s *SMSHandler) abortComposition() error {
+	if _, err := s.port.Write([]byte(ComposeCancel)); err != nil {
+		return err
+	}
+	s.composeOpen = false
+	return nil
+}
+
+// logCancelError logs a failed abortComposition inside a send, which goes
+// on to report its own error
+func (s *SMSHandler) logCancelError(err error) {
+	if err != nil {
+		s.logger().Printf("Error cancelling SMS composition: %v", err)
+	}
+}
+
+// recoverComposition cancels a composition an earlier send may have left
+// open, for example after a prompt timeout or a Close mid-send, so the next
+// command is not swallowed as message text. The caller holds readerMu.
+func (s *SMSHandler) recoverComposition() {
+	if !s.composeOpen {
+		return
+	}
+	s.logger().Printf("Cancelling SMS composition left open by an earlier send")
+	s.logCancelError(s.abortComposition())
+	s.awaitCancelAck()
+	s.composeOpen = false
 }
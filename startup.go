@@ -0,0 +1,129 @@
+package smshandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultReadyAttempts and DefaultReadyDelay are how often and how far apart
+// init probes the modem with AT when no WithReadyProbe option is supplied
+const (
+	DefaultReadyAttempts = 3
+	DefaultReadyDelay    = time.Second
+)
+
+// ErrModemNotResponding is returned, wrapped, when the modem does not answer
+// the readiness probe at the start of init
+var ErrModemNotResponding = errors.New("modem not responding")
+
+// startupURCs are the unsolicited results modems print after power-on, as
+// line prefixes. They can arrive in the middle of the first commands'
+// responses and are never part of them.
+var startupURCs = []string{
+	"RDY",
+	"+CFUN:",
+	"+CPIN: READY",
+	"+PBREADY",
+	"PB DONE",
+	"CALL READY",
+	"SMS READY",
+	"SMS DONE",
+	"+QIND:",
+}
+
+// isStartupURC reports whether line, read while waiting for command's
+// response, is a power-on notification. A line naming the command itself,
+// such as +CPIN: READY in reply to AT+CPIN?, is the response.
+func isStartupURC(command, line string) bool {
+	line = strings.ToUpper(strings.TrimSpace(line))
+	if name := resultName(line); name != "" && strings.HasPrefix(strings.ToUpper(command), "AT"+name) {
+		return false
+	}
+	for _, urc := range startupURCs {
+		if strings.HasPrefix(line, urc) {
+			return true
+		}
+	}
+	return false
+}
+
+// resultName returns the "+NAME" part of a "+NAME: ..." line, or "" for
+// lines without one
+func resultName(line string) string {
+	if !strings.HasPrefix(line, "+") {
+		return ""
+	}
+	if i := strings.IndexByte(line, ':'); i > 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// isSMSReady reports whether line announces that the SMS subsystem is up,
+// as "SMS Ready" or Quectel's "+QIND: SMS DONE"
+func isSMSReady(line string) bool {
+	line = strings.ToUpper(strings.TrimSpace(line))
+	return line == "SMS READY" || strings.HasSuffix(line, "SMS DONE")
+}
+
+// awaitStartup discards startup notifications until the modem reports SMS
+// Ready or maxWait passes. Modems that were already running never send it,
+// so running out of time is not an error.
+func (s *SMSHandler) awaitStartup(maxWait time.Duration) {
+	s.readerMu.Lock()
+	defer s.readerMu.Unlock()
+
+	if err := s.port.SetReadTimeout(s.pollInterval()); err != nil {
+		s.logger().Printf("Error setting read timeout: %v", err)
+	}
+
+	var pending []byte
+	buf := make([]byte, 256)
+	start := s.clock().Now()
+	for s.since(start) < maxWait {
+		n, err := s.reader.Read(buf)
+		if err != nil {
+			s.clock().Sleep(s.pollInterval())
+			continue
+		}
+		pending = append(pending, buf[:n]...)
+
+		for {
+			i := strings.IndexByte(string(pending), '\n')
+			if i < 0 {
+				break
+			}
+			line := strings.TrimSpace(string(pending[:i]))
+			pending = pending[i+1:]
+			if line == "" {
+				continue
+			}
+			s.logger().Debugf("startup: %q", line)
+			if isSMSReady(line) {
+				return
+			}
+		}
+	}
+	s.logger().Debugf("no SMS Ready within %v, continuing init", maxWait)
+}
+
+// probeReady sends AT until the modem answers it without an error, up to the
+// WithReadyProbe limit, waiting between attempts for a modem that is still
+// waking up
+func (s *SMSHandler) probeReady() error {
+	attempts := s.readyAttempts()
+	var err error
+	for i := 1; i <= attempts; i++ {
+		var response string
+		if response, err = s.sendATCommandExpectOK("AT"); err == nil {
+			return nil
+		}
+		s.logger().Debugf("AT probe %d/%d: %q, %v", i, attempts, response, err)
+		if i < attempts {
+			s.clock().Sleep(s.readyDelay())
+		}
+	}
+	return fmt.Errorf("%w after %d attempts: %v", ErrModemNotResponding, attempts, err)
+}
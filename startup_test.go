@@ -0,0 +1,140 @@
+package smshandler
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsStartupURC(t *testing.T) {
+	tests := []struct {
+		command, line string
+		want          bool
+	}{
+		{"AT", "RDY", true},
+		{"AT", "SMS Ready", true},
+		{"AT+CMGF=1", "+CPIN: READY", true},
+		{"AT+CPIN?", "+CPIN: READY", false},
+		{"AT+CFUN?", "+CFUN: 1", false},
+		{"AT", "OK", false},
+		{"AT+CSQ", "+CSQ: 20,99", false},
+	}
+
+	for _, tt := range tests {
+		if got := isStartupURC(tt.command, tt.line); got != tt.want {
+			t.Errorf("isStartupURC(%q, %q): got %v, want %v", tt.command, tt.line, got, tt.want)
+		}
+	}
+}
+
+// A modem that has just powered on interleaves its startup notifications
+// with the response to the first command
+func TestSendATCommandSkipsStartupURCs(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.SimulateIncoming("\r\nRDY\r\n\r\n+CFUN: 1\r\n\r\n+CPIN: READY\r\n")
+	mockPort.AddResponse("AT", "\r\nSMS Ready\r\nOK\r\n")
+
+	response, err := handler.sendATCommand("AT")
+	if err != nil {
+		t.Fatalf("sendATCommand failed: %v", err)
+	}
+	if response != "OK" {
+		t.Errorf("response: got %q, want %q", response, "OK")
+	}
+}
+
+func TestInitModemWaitsForSMSReady(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	clk := newFakeClock()
+	handler.clk = clk
+	handler.cfg.startupWait = 30 * time.Second
+	mockPort.SimulateIncoming("\r\nRDY\r\n\r\n+CPIN: READY\r\n\r\nCall Ready\r\n\r\nSMS Ready\r\n")
+	mockPort.AddResponse("AT+CMGF?", "+CMGF: 1\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSCS?", "+CSCS: \"GSM\"\r\nOK\r\n")
+
+	start := clk.Now()
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+	if waited := clk.Now().Sub(start); waited >= 30*time.Second {
+		t.Errorf("init waited the full %v despite SMS Ready", waited)
+	}
+	if !strings.HasPrefix(mockPort.GetWrittenData(), "AT\r\n") {
+		t.Errorf("first command not AT: %q", mockPort.GetWrittenData())
+	}
+}
+
+func TestAwaitStartupGivesUp(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	clk := newFakeClock()
+	handler.clk = clk
+
+	start := clk.Now()
+	handler.awaitStartup(5 * time.Second)
+	if waited := clk.Now().Sub(start); waited < 5*time.Second {
+		t.Errorf("returned after %v without SMS Ready", waited)
+	}
+}
+
+// wakingPort answers AT with ERROR until it has been probed wakeAfter times,
+// like a modem that is still booting
+type wakingPort struct {
+	*MockSerialPort
+	probes    int
+	wakeAfter int
+}
+
+func (p *wakingPort) Write(b []byte) (int, error) {
+	if strings.TrimSpace(string(b)) == "AT" {
+		p.probes++
+		if p.probes >= p.wakeAfter {
+			p.AddResponse("AT", "OK\r\n")
+		} else {
+			p.AddResponse("AT", "ERROR\r\n")
+		}
+	}
+	return p.MockSerialPort.Write(b)
+}
+
+func TestProbeReady(t *testing.T) {
+	tests := []struct {
+		wakeAfter int
+		wantErr   bool
+	}{
+		{1, false},
+		{3, false},
+		{4, true},
+	}
+
+	for _, tt := range tests {
+		port := &wakingPort{MockSerialPort: NewMockSerialPort(), wakeAfter: tt.wakeAfter}
+		handler := newMockHandler(port.MockSerialPort)
+		handler.port = port
+		handler.reader = bufio.NewReader(port)
+		clk := newFakeClock()
+		handler.clk = clk
+		start := clk.Now()
+
+		err := handler.probeReady()
+		if tt.wantErr {
+			if !errors.Is(err, ErrModemNotResponding) || !strings.Contains(err.Error(), "after 3 attempts") {
+				t.Errorf("wake after %d: got %v, want not responding after 3 attempts", tt.wakeAfter, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("wake after %d: probeReady failed: %v", tt.wakeAfter, err)
+		}
+		if port.probes != tt.wakeAfter {
+			t.Errorf("wake after %d: probed %d times", tt.wakeAfter, port.probes)
+		}
+		if waited := clk.Now().Sub(start); waited != time.Duration(tt.wakeAfter-1)*DefaultReadyDelay {
+			t.Errorf("wake after %d: waited %v", tt.wakeAfter, waited)
+		}
+	}
+}
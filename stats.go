@@ -0,0 +1,89 @@
+package smshandler
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats are counts a handler keeps for itself, whether or not a
+// MetricsRecorder is configured. They count the same events as the
+// recorder and live only in memory.
+type Stats struct {
+	// Sent is the number of messages the modem accepted for sending
+	Sent uint64
+	// Received is the number of incoming messages delivered, after
+	// de-duplication
+	Received uint64
+	// SendFailed is the number of sends that failed
+	SendFailed uint64
+	// ModemErrors is the number of commands that timed out or got an
+	// error reply
+	ModemErrors uint64
+	// Since is when counting started: when the handler was opened or
+	// ResetStats was last called
+	Since time.Time
+}
+
+// statsRecorder counts metrics events for Stats
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+func (r *statsRecorder) IncSent()             { r.add(&r.stats.Sent) }
+func (r *statsRecorder) IncReceived()         { r.add(&r.stats.Received) }
+func (r *statsRecorder) IncSendError(string)  { r.add(&r.stats.SendFailed) }
+func (r *statsRecorder) IncModemError(string) { r.add(&r.stats.ModemErrors) }
+
+func (r *statsRecorder) add(counter *uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*counter++
+}
+
+// reset zeroes the counts and restarts them at since
+func (r *statsRecorder) reset(since time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = Stats{Since: since}
+}
+
+// teeMetrics feeds every event to the handler's own counts and to the
+// configured recorder
+type teeMetrics struct {
+	stats *statsRecorder
+	next  MetricsRecorder
+}
+
+func (t teeMetrics) IncSent() {
+	t.stats.IncSent()
+	t.next.IncSent()
+}
+
+func (t teeMetrics) IncReceived() {
+	t.stats.IncReceived()
+	t.next.IncReceived()
+}
+
+func (t teeMetrics) IncSendError(class string) {
+	t.stats.IncSendError(class)
+	t.next.IncSendError(class)
+}
+
+func (t teeMetrics) IncModemError(class string) {
+	t.stats.IncModemError(class)
+	t.next.IncModemError(class)
+}
+
+// Stats returns the counts since the handler was opened or ResetStats
+// was last called
+func (s *SMSHandler) Stats() Stats {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	return s.stats.stats
+}
+
+// ResetStats zeroes the counts returned by Stats
+func (s *SMSHandler) ResetStats() {
+	s.stats.reset(s.clock().Now())
+}
@@ -0,0 +1,88 @@
+package smshandler
+
+import "strings"
+
+// MessageStatus is the storage status of a message, normalized from either
+// the text-mode strings or the numeric PDU-mode codes the modem reports.
+// Only the Status values below can be passed to the handler; String returns
+// the text-mode name.
+type MessageStatus struct {
+	name string
+}
+
+// Message statuses as defined by GSM 07.05
+var (
+	StatusUnread       = MessageStatus{"REC UNREAD"}
+	StatusRead         = MessageStatus{"REC READ"}
+	StatusStoredUnsent = MessageStatus{"STO UNSENT"}
+	StatusStoredSent   = MessageStatus{"STO SENT"}
+
+	// StatusAll selects every message when listing; it never appears on a
+	// message itself
+	StatusAll = MessageStatus{"ALL"}
+)
+
+// String returns the text-mode name of the status, such as "REC UNREAD",
+// or whatever the modem reported for a status it does not define
+func (m MessageStatus) String() string {
+	return m.name
+}
+
+// MarshalText encodes the status as its text-mode name
+func (m MessageStatus) MarshalText() ([]byte, error) {
+	return []byte(m.name), nil
+}
+
+// UnmarshalText decodes a status the way the modem's <stat> field is parsed
+func (m *MessageStatus) UnmarshalText(text []byte) error {
+	*m = parseMessageStatus(string(text))
+	return nil
+}
+
+// numericStatuses maps PDU-mode <stat> codes to their text-mode equivalents
+var numericStatuses = map[string]MessageStatus{
+	"0": StatusUnread,
+	"1": StatusRead,
+	"2": StatusStoredUnsent,
+	"3": StatusStoredSent,
+}
+
+// parseMessageStatus normalizes a <stat> field from +CMGL or +CMGR. Both
+// quoted text ("REC UNREAD") and numeric (0) forms are accepted; anything
+// unrecognized is returned as-is so no information is lost.
+func parseMessageStatus(field string) MessageStatus {
+	field = strings.Trim(strings.TrimSpace(field), "\"")
+	if status, ok := numericStatuses[field]; ok {
+		return status
+	}
+
+	switch status := (MessageStatus{strings.ToUpper(field)}); status {
+	case StatusUnread, StatusRead, StatusStoredUnsent, StatusStoredSent:
+		return status
+	}
+	return MessageStatus{field}
+}
+
+// valid reports whether the status can be used to list messages
+func (m MessageStatus) valid() bool {
+	switch m {
+	case StatusUnread, StatusRead, StatusStoredUnsent, StatusStoredSent, StatusAll:
+		return true
+	}
+	return false
+}
+
+// pduCode returns the numeric <stat> used to list messages in PDU mode
+func (m MessageStatus) pduCode() int {
+	switch m {
+	case StatusUnread:
+		return 0
+	case StatusRead:
+		return 1
+	case StatusStoredUnsent:
+		return 2
+	case StatusStoredSent:
+		return 3
+	}
+	return 4
+}
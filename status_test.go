@@ -0,0 +1,245 @@
+package smshandler
+
+import (
+	"bufio"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMessageStatus(t *testing.T) {
+	tests := []struct {
+		input string
+		want  MessageStatus
+	}{
+		{`"REC UNREAD"`, StatusUnread},
+		{`"REC READ"`, StatusRead},
+		{`"STO UNSENT"`, StatusStoredUnsent},
+		{`"STO SENT"`, StatusStoredSent},
+		{`"rec read"`, StatusRead},
+		{"0", StatusUnread},
+		{" 1", StatusRead},
+		{"2", StatusStoredUnsent},
+		{"3", StatusStoredSent},
+		{`"VENDOR"`, MessageStatus{"VENDOR"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseMessageStatus(tt.input); got != tt.want {
+			t.Errorf("parseMessageStatus(%q): got %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestReadSMSByIndexNumericStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     MessageStatus
+	}{
+		{
+			name:     "Text status",
+			response: "+CMGR: \"REC UNREAD\",\"+1234567890\",\"24/01/15,10:30:45+00\"\r\nHello\r\nOK\r\n",
+			want:     StatusUnread,
+		},
+		{
+			name:     "Numeric status",
+			response: "+CMGR: 0,\"+1234567890\",\"24/01/15,10:30:45+00\"\r\nHello\r\nOK\r\n",
+			want:     StatusUnread,
+		},
+		{
+			name:     "Numeric read status",
+			response: "+CMGR: 1,\"+1234567890\",\"24/01/15,10:30:45+00\"\r\nHello\r\nOK\r\n",
+			want:     StatusRead,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPort := NewMockSerialPort()
+			handler := newMockHandler(mockPort)
+			mockPort.AddResponse("AT+CMGR=2", tt.response)
+
+			sms, err := handler.readSMSByIndex(2)
+			if err != nil {
+				t.Fatalf("readSMSByIndex failed: %v", err)
+			}
+			if sms.Status != tt.want {
+				t.Errorf("Status: got %q, want %q", sms.Status, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSMSListNumericStatus(t *testing.T) {
+	handler := &SMSHandler{}
+	response := "+CMGL: 1,\"REC READ\",\"+1234567890\",\"\",\"24/01/15,10:30:45+00\"\n" +
+		"First\n" +
+		"+CMGL: 2,0,\"+1234567890\",\"\",\"24/01/15,10:31:45+00\"\n" +
+		"Second\n" +
+		"OK"
+
+	messages := handler.parseSMSList(response)
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].Status != StatusRead {
+		t.Errorf("first status: got %q, want %q", messages[0].Status, StatusRead)
+	}
+	if messages[1].Status != StatusUnread {
+		t.Errorf("second status: got %q, want %q", messages[1].Status, StatusUnread)
+	}
+}
+
+// A body that is exactly "OK", or merely contains it, must survive both
+// the command reader and the list parser
+func TestReadSMSBodyReadingOK(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGL="ALL"`,
+		"+CMGL: 1,\"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nOK\r\n"+
+			"+CMGL: 2,\"REC READ\",\"+1234567890\",,\"24/01/15,10:31:00+00\"\r\nLooks OK to me\r\n"+
+			"+CMGL: 3,\"REC READ\",\"+1234567890\",,\"24/01/15,10:32:00+00\"\r\nLast\r\nOK\r\n")
+
+	messages, err := handler.ReadSMS()
+	if err != nil {
+		t.Fatalf("ReadSMS failed: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d: %+v", len(messages), messages)
+	}
+	for i, want := range []string{"OK", "Looks OK to me", "Last"} {
+		if messages[i].Message != want {
+			t.Errorf("message %d: got %q, want %q", i+1, messages[i].Message, want)
+		}
+	}
+}
+
+func TestParseSMSListMultiLineBody(t *testing.T) {
+	handler := &SMSHandler{}
+	response := "+CMGL: 1,\"REC READ\",\"+1234567890\",,\"24/01/15,10:30:45+00\"\n" +
+		"First line\n" +
+		"OK\n" +
+		"OK"
+
+	messages := handler.parseSMSList(response)
+	if len(messages) != 1 || messages[0].Message != "First line\nOK" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+}
+
+func TestReadSMSByStatus(t *testing.T) {
+	tests := []struct {
+		status  MessageStatus
+		command string
+	}{
+		{StatusAll, `AT+CMGL="ALL"`},
+		{StatusUnread, `AT+CMGL="REC UNREAD"`},
+		{StatusRead, `AT+CMGL="REC READ"`},
+		{StatusStoredUnsent, `AT+CMGL="STO UNSENT"`},
+		{StatusStoredSent, `AT+CMGL="STO SENT"`},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		mockPort.AddResponse(tt.command, "+CMGL: 4,\"REC READ\",\"+1234567890\",\"\",\"24/01/15,10:30:45+00\"\r\nHi\r\nOK\r\n")
+
+		messages, err := handler.ReadSMSByStatus(tt.status)
+		if err != nil {
+			t.Fatalf("ReadSMSByStatus(%q) failed: %v", tt.status, err)
+		}
+		if len(messages) != 1 || messages[0].Index != 4 {
+			t.Errorf("ReadSMSByStatus(%q): unexpected result %+v", tt.status, messages)
+		}
+	}
+
+	handler := newMockHandler(NewMockSerialPort())
+	if _, err := handler.ReadSMSByStatus(MessageStatus{"BOGUS"}); err == nil {
+		t.Error("expected error for invalid status")
+	}
+}
+
+// blockingPort holds reads until released, simulating a slow mailbox dump
+type blockingPort struct {
+	*MockSerialPort
+	release chan struct{}
+}
+
+func (b *blockingPort) Read(p []byte) (int, error) {
+	<-b.release
+	return b.MockSerialPort.Read(p)
+}
+
+func TestReadSMSContextCancel(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	port := &blockingPort{MockSerialPort: mockPort, release: make(chan struct{})}
+	handler := newMockHandler(mockPort)
+	handler.port = port
+	handler.reader = bufio.NewReader(port)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := handler.ReadSMSContext(ctx, StatusAll)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+
+	// The tail of the cancelled listing must not leak into the next command
+	mockPort.SimulateIncoming("+CMGL: 1,\"REC READ\",\"+1234567890\",\"\",\"24/01/15,10:30:45+00\"\r\nOld\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSQ", "+CSQ: 20,99\r\nOK\r\n")
+	close(port.release)
+
+	response, err := handler.GetSignalStrength()
+	if err != nil {
+		t.Fatalf("command after cancel failed: %v", err)
+	}
+	if response != "+CSQ: 20,99\nOK" {
+		t.Errorf("unexpected response after cancel: %q", response)
+	}
+}
+
+func TestReadSMSRaw(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	listing := "+CMGL: 1,\"REC READ\",\"+15551234567\",,\"24/01/15,10:30:45+00\"\r\nHello\r\nOK\r\n"
+	mockPort.AddResponse(`AT+CMGL="ALL"`, listing)
+
+	messages, raw, err := handler.ReadSMSRaw()
+	if err != nil {
+		t.Fatalf("ReadSMSRaw failed: %v", err)
+	}
+	if !strings.Contains(raw, `+CMGL: 1,"REC READ"`) || !strings.Contains(raw, "Hello") {
+		t.Errorf("raw response missing listing: %q", raw)
+	}
+	if want := handler.parseSMSList(raw); !reflect.DeepEqual(messages, want) {
+		t.Errorf("parsed messages differ from ReadSMS parsing: got %+v, want %+v", messages, want)
+	}
+	if len(messages) != 1 || messages[0].Message != "Hello" {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
+
+func TestReadSMSFromSender(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGL="ALL"`,
+		"+CMGL: 1,\"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nFrom them\r\n"+
+			"+CMGL: 2,\"REC READ\",\"+1987654321\",,\"24/01/15,10:31:00+00\"\r\nSomeone else\r\n"+
+			"+CMGL: 3,\"REC UNREAD\",\"1234567890\",,\"24/01/15,10:32:00+00\"\r\nAlso them\r\nOK\r\n")
+
+	messages, err := handler.ReadSMSFromSender("+1 (234) 567-890", StatusAll)
+	if err != nil {
+		t.Fatalf("ReadSMSFromSender failed: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Index != 1 || messages[1].Index != 3 {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+}
@@ -0,0 +1,395 @@
+package smshandler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteSMS stores a message addressed to number in modem storage without
+// sending it (AT+CMGW) and returns the storage index it was written to. The
+// stored message can later be sent, or re-sent, with SendStoredSMS.
+func (s *SMSHandler) WriteSMS(number, message string) (int, error) {
+	enc, _ := resolveEncoding(message, "")
+	if err := checkSingleSegment(message, enc); err != nil {
+		return 0, err
+	}
+
+	if s.cfg.mode != ModePDU {
+		if err := checkTextBody(message); err != nil {
+			return 0, err
+		}
+	}
+
+	cmd, body := fmt.Sprintf("AT+CMGW=\"%s\"", number), message
+	if s.cfg.mode == ModePDU {
+		pdu, length, err := encodeSubmitPDU(number, message, AddressTypeAuto, enc, defaultValidityPeriod)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode PDU: %v", err)
+		}
+		cmd, body = fmt.Sprintf("AT+CMGW=%d", length), pdu
+	}
+
+	response, err := s.composeMessage(context.Background(), cmd, body, "+CMGW:", 10*time.Second, nil)
+	if err != nil && isMemoryFull(err) && s.cfg.overwriteWhenFull {
+		if roomErr := s.makeRoom(); roomErr != nil {
+			return 0, fmt.Errorf("failed to write SMS to storage: %v; freeing space failed: %v", err, roomErr)
+		}
+		response, err = s.composeMessage(context.Background(), cmd, body, "+CMGW:", 10*time.Second, nil)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write SMS to storage: %w", err)
+	}
+
+	index, err := parseResultNumber(response, "+CMGW:")
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse storage index: %v", err)
+	}
+	return index, nil
+}
+
+// SendStoredSMS sends a message previously written to storage (AT+CMSS).
+// The stored copy is kept, so the exact same message can be retried.
+func (s *SMSHandler) SendStoredSMS(index int) error {
+	if err := s.beginSend(); err != nil {
+		return err
+	}
+	defer s.endSend()
+
+	s.waitForSendSlot()
+
+	cmd := fmt.Sprintf("AT+CMSS=%d", index)
+	response, err := s.sendATCommandExpectOK(cmd)
+	if err != nil {
+		s.metricsRecorder().IncSendError(responseErrorClass(response))
+		return fmt.Errorf("failed to send stored SMS %d: %v", index, err)
+	}
+	if !strings.Contains(response, "+CMSS:") {
+		s.metricsRecorder().IncSendError(ErrorClassUnknown)
+		return fmt.Errorf("failed to send stored SMS %d: unexpected response %q", index, response)
+	}
+	s.metricsRecorder().IncSent()
+	return nil
+}
+
+// parseResultNumber extracts the integer that follows prefix in a response,
+// as in "+CMGW: 5" or "+CMGS: 123"
+func parseResultNumber(response, prefix string) (int, error) {
+	i := strings.Index(response, prefix)
+	if i < 0 {
+		return 0, fmt.Errorf("no %s line in response %q", prefix, response)
+	}
+
+	var n int
+	if _, err := fmt.Sscanf(strings.TrimSpace(response[i+len(prefix):]), "%d", &n); err != nil {
+		return 0, fmt.Errorf("invalid %s line in response %q: %v", prefix, response, err)
+	}
+	return n, nil
+}
+
+// ExportMailbox reads every stored message for backup or archival, in
+// storage index order. Each SMS keeps its storage index (and, for a joined
+// concatenated message, every part's index in PartIndexes) and marshals to
+// JSON with its parsed timestamp. Encoding is only reported in PDU mode.
+func (s *SMSHandler) ExportMailbox() ([]SMS, error) {
+	messages, err := s.ReadSMS()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export mailbox: %v", err)
+	}
+	sortByIndex(messages)
+	return messages, nil
+}
+
+// StorageMap returns the status of every occupied slot in the read storage,
+// keyed by storage index, without decoding any message bodies. Like
+// ReadSMS, listing marks unread messages as read on most modems.
+func (s *SMSHandler) StorageMap() (map[int]string, error) {
+	cmd := fmt.Sprintf("AT+CMGL=\"%s\"", StatusAll)
+	if s.cfg.mode == ModePDU {
+		cmd = fmt.Sprintf("AT+CMGL=%d", StatusAll.pduCode())
+	}
+
+	response, err := s.sendATCommand(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %v", err)
+	}
+	if modemErr := finalResultError(response); modemErr != nil {
+		s.metricsRecorder().IncModemError(modemErr.class())
+		return nil, fmt.Errorf("failed to list storage: %w", modemErr)
+	}
+	return parseStorageMap(response), nil
+}
+
+// parseStorageMap reads the index and status of each +CMGL header in a
+// listing, skipping the body lines
+func parseStorageMap(response string) map[int]string {
+	slots := make(map[int]string)
+	for _, line := range strings.Split(response, "\n") {
+		command, fields := ParseURC(line)
+		if command != "+CMGL" || len(fields) < 2 {
+			continue
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		slots[index] = parseMessageStatus(fields[1]).String()
+	}
+	return slots
+}
+
+// storageUsage is one memory's entry in an AT+CPMS? response
+type storageUsage struct {
+	storage string
+	used    int
+	total   int
+}
+
+// FreeSlots returns the number of free message slots in the receive storage
+// (the third memory in AT+CPMS), so callers can check there is room before a
+// burst of incoming messages.
+func (s *SMSHandler) FreeSlots() (int, error) {
+	return s.freeSlots(context.Background())
+}
+
+func (s *SMSHandler) freeSlots(ctx context.Context) (int, error) {
+	response, err := s.sendATCommandContext(ctx, "AT+CPMS?")
+	if err == nil {
+		if modemErr := parseModemError(response); modemErr != nil {
+			err = fmt.Errorf("modem returned error: %w", modemErr)
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query storage: %v", err)
+	}
+
+	usage, err := parseCPMS(response)
+	if err != nil {
+		return 0, err
+	}
+
+	// Receive storage is mem3; fall back to the last one reported
+	receive := usage[len(usage)-1]
+	return receive.total - receive.used, nil
+}
+
+// storagePollInterval is how often WaitForStorage polls
+const storagePollInterval = time.Second
+
+// StorageWaitError is returned by WaitForStorage when ctx is done before
+// enough slots are free
+type StorageWaitError struct {
+	// Free is the free slot count last seen, -1 if it was never read
+	Free int
+	// MinFree is the count that was waited for
+	MinFree int
+	// Err is ctx.Err()
+	Err error
+}
+
+func (e *StorageWaitError) Error() string {
+	return fmt.Sprintf("%d of %d wanted storage slots free: %v", e.Free, e.MinFree, e.Err)
+}
+
+func (e *StorageWaitError) Unwrap() error {
+	return e.Err
+}
+
+// WaitForStorage blocks until the receive storage has at least minFree
+// free slots, polling AT+CPMS? every second, so a consumer that processes
+// and deletes messages can hold off fetching more during a burst. When ctx
+// is done first it returns a *StorageWaitError with the last free count,
+// which wraps ctx.Err().
+func (s *SMSHandler) WaitForStorage(ctx context.Context, minFree int) error {
+	free := -1
+	for {
+		n, err := s.freeSlots(ctx)
+		if err == nil {
+			if n >= minFree {
+				return nil
+			}
+			free = n
+		} else if ctx.Err() == nil {
+			s.logger().Debugf("storage poll failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return &StorageWaitError{Free: free, MinFree: minFree, Err: ctx.Err()}
+		case <-s.clock().After(storagePollInterval):
+		}
+	}
+}
+
+// messageStorages are the memory names AT+CPMS accepts
+var messageStorages = map[string]bool{
+	"SM": true, // SIM card
+	"ME": true, // modem memory
+	"MT": true, // SIM and modem memory combined
+	"BM": true, // broadcast messages
+	"SR": true, // status reports
+	"TA": true, // terminal adapter
+}
+
+// ReadSMSFrom reads the messages in the given status from one storage area
+// ("SM", "ME", "MT", ...) without changing where new messages are stored.
+// The read storage is switched for the duration of the call and restored
+// afterwards, including when the read fails.
+func (s *SMSHandler) ReadSMSFrom(storage string, status MessageStatus) ([]SMS, error) {
+	storage = strings.ToUpper(strings.TrimSpace(storage))
+	if !messageStorages[storage] {
+		return nil, fmt.Errorf("invalid message storage %q", storage)
+	}
+
+	response, err := s.sendATCommandExpectOK("AT+CPMS?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage: %v", err)
+	}
+	usage, err := parseCPMS(response)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the read storage (mem1) changes; write and receive storage keep
+	// their current setting
+	previous := make([]string, len(usage))
+	for i, u := range usage {
+		previous[i] = u.storage
+	}
+	requested := append([]string{storage}, previous[1:]...)
+
+	// Restore even when selecting fails, as the modem may have applied
+	// part of it
+	defer func() {
+		if err := s.selectStorage(previous); err != nil {
+			s.logger().Printf("Failed to restore storage %s: %v", previous[0], err)
+		}
+	}()
+	if err := s.selectStorage(requested); err != nil {
+		return nil, fmt.Errorf("failed to select storage %s: %w", storage, err)
+	}
+
+	return s.ReadSMSByStatus(status)
+}
+
+// StorageMismatchError is returned when the modem reports different message
+// storage than was just selected, as some modems silently ignore a storage
+// they do not support
+type StorageMismatchError struct {
+	// Requested and Active are the read, write and receive storages asked
+	// for and the ones AT+CPMS? reported afterwards
+	Requested []string
+	Active    []string
+}
+
+func (e *StorageMismatchError) Error() string {
+	return fmt.Sprintf("modem storage is %s, not the requested %s",
+		strings.Join(e.Active, ","), strings.Join(e.Requested, ","))
+}
+
+// selectStorage sets the message storages with AT+CPMS and reads them back
+// with AT+CPMS? to confirm the modem applied them. The confirmed storages
+// are kept for Config.
+func (s *SMSHandler) selectStorage(storages []string) error {
+	if _, err := s.sendATCommandExpectOK(cpmsCommand(storages)); err != nil {
+		return err
+	}
+
+	response, err := s.sendATCommandExpectOK("AT+CPMS?")
+	if err != nil {
+		return fmt.Errorf("failed to confirm storage: %v", err)
+	}
+	usage, err := parseCPMS(response)
+	if err != nil {
+		return fmt.Errorf("failed to confirm storage: %v", err)
+	}
+
+	active := make([]string, len(usage))
+	for i, u := range usage {
+		active[i] = u.storage
+	}
+	s.activeStorage = active
+
+	for i, want := range storages {
+		if i >= len(active) || !strings.EqualFold(active[i], want) {
+			return &StorageMismatchError{Requested: storages, Active: active}
+		}
+	}
+	return nil
+}
+
+// cpmsCommand builds AT+CPMS setting the given memories in order
+func cpmsCommand(storages []string) string {
+	quoted := make([]string, len(storages))
+	for i, storage := range storages {
+		quoted[i] = fmt.Sprintf("%q", storage)
+	}
+	return "AT+CPMS=" + strings.Join(quoted, ",")
+}
+
+// parseCPMS parses an AT+CPMS? response of the form
+// +CPMS: "SM",5,30,"SM",5,30,"SM",5,30
+func parseCPMS(response string) ([]storageUsage, error) {
+	fields, ok := resultFields(response, "+CPMS:")
+	if !ok {
+		return nil, fmt.Errorf("no +CPMS line in response: %q", response)
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("modem did not report storage totals: %q", response)
+	}
+
+	var usage []storageUsage
+	for i := 0; i+2 < len(fields); i += 3 {
+		u := storageUsage{storage: fields[i]}
+		if _, err := fmt.Sscanf(fields[i+1], "%d", &u.used); err != nil {
+			return nil, fmt.Errorf("modem did not report storage totals: %q", response)
+		}
+		if _, err := fmt.Sscanf(fields[i+2], "%d", &u.total); err != nil {
+			return nil, fmt.Errorf("modem did not report storage totals: %q", response)
+		}
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// DeleteSMSOlderThan deletes every stored message whose timestamp is more
+// than d in the past and returns how many were deleted. Messages whose
+// timestamp could not be parsed are logged and kept. Deletion runs from the
+// highest index down, so modems that compact storage after a delete do not
+// shift the indexes still to be deleted.
+func (s *SMSHandler) DeleteSMSOlderThan(d time.Duration) (int, error) {
+	messages, err := s.ReadSMS()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := s.clock().Now().Add(-d)
+	var indexes []int
+	for _, sms := range messages {
+		if sms.Timestamp.IsZero() {
+			s.logger().Printf("Keeping SMS %d: unparseable timestamp %q", sms.Index, sms.Date)
+			continue
+		}
+		if !sms.Timestamp.Before(cutoff) {
+			continue
+		}
+		if len(sms.PartIndexes) > 0 {
+			indexes = append(indexes, sms.PartIndexes...)
+		} else {
+			indexes = append(indexes, sms.Index)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+
+	deleted := 0
+	for _, index := range indexes {
+		if _, err := s.sendATCommandExpectOK(fmt.Sprintf("AT+CMGD=%d", index)); err != nil {
+			return deleted, fmt.Errorf("failed to delete SMS %d: %v", index, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
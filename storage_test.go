@@ -0,0 +1,378 @@
+package smshandler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSMS(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n> ")
+		time.Sleep(150 * time.Millisecond)
+		mockPort.SimulateIncoming("\r\n+CMGW: 7\r\nOK\r\n")
+	}()
+
+	index, err := handler.WriteSMS("+1234567890", "Draft message")
+	if err != nil {
+		t.Fatalf("WriteSMS failed: %v", err)
+	}
+	if index != 7 {
+		t.Errorf("index: got %d, want 7", index)
+	}
+
+	written := mockPort.GetWrittenData()
+	if !strings.Contains(written, `AT+CMGW="+1234567890"`) {
+		t.Error("AT+CMGW command not sent")
+	}
+	if !strings.Contains(written, "Draft message\x1A") {
+		t.Error("message body not terminated with Ctrl+Z")
+	}
+}
+
+func TestSendStoredSMS(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CMSS=7", "+CMSS: 42\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMSS=8", "+CMS ERROR: 321\r\n")
+
+	if err := handler.SendStoredSMS(7); err != nil {
+		t.Errorf("SendStoredSMS failed: %v", err)
+	}
+	if err := handler.SendStoredSMS(8); err == nil {
+		t.Error("expected error for invalid index")
+	}
+}
+
+func TestParseResultNumber(t *testing.T) {
+	n, err := parseResultNumber("\r\n+CMGW: 12\r\n\r\nOK\r\n", "+CMGW:")
+	if err != nil || n != 12 {
+		t.Errorf("got %d, %v; want 12", n, err)
+	}
+	if _, err := parseResultNumber("OK", "+CMGW:"); err == nil {
+		t.Error("expected error for missing prefix")
+	}
+}
+
+func TestMinSendInterval(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.minSendInterval = 150 * time.Millisecond
+	mockPort.AddResponse("AT+CMSS=1", "+CMSS: 1\r\nOK\r\n")
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := handler.SendStoredSMS(1); err != nil {
+			t.Fatalf("SendStoredSMS failed: %v", err)
+		}
+	}
+
+	// The first send goes out immediately, the next two wait for a slot
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("sends were not paced: 3 sends took %v", elapsed)
+	}
+}
+
+func TestFreeSlots(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     int
+		wantErr  bool
+	}{
+		{"Three memories", "+CPMS: \"SM\",5,30,\"SM\",5,30,\"ME\",12,50\r\nOK\r\n", 38, false},
+		{"Single memory", "+CPMS: \"SM\",29,30\r\nOK\r\n", 1, false},
+		{"No totals", "+CPMS: \"SM\",\"SM\",\"SM\"\r\nOK\r\n", 0, true},
+		{"Error", "+CMS ERROR: 302\r\n", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPort := NewMockSerialPort()
+			handler := newMockHandler(mockPort)
+			mockPort.AddResponse("AT+CPMS?", tt.response)
+
+			free, err := handler.FreeSlots()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got %d", free)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FreeSlots failed: %v", err)
+			}
+			if free != tt.want {
+				t.Errorf("got %d, want %d", free, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteSMSOlderThan(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	recent := time.Now().UTC().Format("06/01/02,15:04:05") + "+00"
+	mockPort.AddResponse(`AT+CMGL="ALL"`,
+		"+CMGL: 1,\"REC READ\",\"+15551234567\",,\"20/01/15,10:30:45+00\"\r\nOld\r\n"+
+			"+CMGL: 2,\"REC READ\",\"+15551234567\",,\""+recent+"\"\r\nNew\r\n"+
+			"+CMGL: 3,\"REC READ\",\"+15551234567\",,\"garbage\"\r\nUnknown\r\n"+
+			"+CMGL: 4,\"REC READ\",\"+15551234567\",,\"21/06/01,08:00:00+00\"\r\nAlso old\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMGD=1", "OK\r\n")
+	mockPort.AddResponse("AT+CMGD=4", "OK\r\n")
+
+	deleted, err := handler.DeleteSMSOlderThan(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("DeleteSMSOlderThan failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted: got %d, want 2", deleted)
+	}
+
+	written := mockPort.GetWrittenData()
+	first, second := strings.Index(written, "AT+CMGD=4"), strings.Index(written, "AT+CMGD=1")
+	if first < 0 || second < 0 || first > second {
+		t.Errorf("expected deletes of 4 then 1, got %q", written)
+	}
+	if strings.Contains(written, "AT+CMGD=2") || strings.Contains(written, "AT+CMGD=3") {
+		t.Errorf("recent or unparseable message deleted: %q", written)
+	}
+}
+
+// cpmsPort answers AT+CPMS? with the storages last set by AT+CPMS, except
+// that storages in ignore are silently not applied, as on modems that do
+// not support them
+type cpmsPort struct {
+	*MockSerialPort
+	storages []string
+	ignore   map[string]bool
+}
+
+func newCPMSPort(ignore ...string) (*cpmsPort, *SMSHandler) {
+	port := &cpmsPort{MockSerialPort: NewMockSerialPort(), storages: []string{"SM", "SM", "SM"}, ignore: map[string]bool{}}
+	for _, storage := range ignore {
+		port.ignore[storage] = true
+	}
+	handler := newMockHandler(port.MockSerialPort)
+	handler.port = port
+	handler.reader = bufio.NewReader(port)
+	return port, handler
+}
+
+func (p *cpmsPort) Write(b []byte) (int, error) {
+	cmd := strings.TrimSpace(string(b))
+	if strings.HasPrefix(cmd, "AT+CPMS=\"") {
+		requested := strings.Split(strings.ReplaceAll(strings.TrimPrefix(cmd, "AT+CPMS="), `"`, ""), ",")
+		if !p.ignore[requested[0]] {
+			p.storages = requested
+		}
+	}
+	if cmd == "AT+CPMS?" {
+		var fields []string
+		for _, storage := range p.storages {
+			fields = append(fields, fmt.Sprintf("%q,1,30", storage))
+		}
+		p.AddResponse(cmd, "+CPMS: "+strings.Join(fields, ",")+"\r\nOK\r\n")
+	}
+	return p.MockSerialPort.Write(b)
+}
+
+func TestReadSMSFrom(t *testing.T) {
+	mockPort, handler := newCPMSPort()
+	mockPort.AddResponse(`AT+CPMS="ME","SM","SM"`, "+CPMS: 2,100,1,30,1,30\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CPMS="SM","SM","SM"`, "+CPMS: 1,30,1,30,1,30\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CMGL="ALL"`,
+		"+CMGL: 3,\"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nFrom memory\r\nOK\r\n")
+
+	messages, err := handler.ReadSMSFrom("me", StatusAll)
+	if err != nil {
+		t.Fatalf("ReadSMSFrom failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Message != "From memory" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+
+	written := mockPort.GetWrittenData()
+	selected := strings.Index(written, `AT+CPMS="ME","SM","SM"`)
+	read := strings.Index(written, `AT+CMGL="ALL"`)
+	restored := strings.Index(written, `AT+CPMS="SM","SM","SM"`)
+	if selected < 0 || read < selected || restored < read {
+		t.Errorf("expected select, read, restore in order, got %q", written)
+	}
+}
+
+func TestReadSMSFromRestoresOnFailure(t *testing.T) {
+	mockPort, handler := newCPMSPort()
+	mockPort.AddResponse(`AT+CPMS="ME","SM","SM"`, "+CPMS: 2,100,1,30,1,30\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CMGL="ALL"`, "+CMS ERROR: 500\r\n")
+	mockPort.AddResponse(`AT+CPMS="SM","SM","SM"`, "+CPMS: 1,30,1,30,1,30\r\nOK\r\n")
+
+	if _, err := handler.ReadSMSFrom("ME", StatusAll); err == nil {
+		t.Fatal("expected the failed read to return an error")
+	}
+	if !strings.Contains(mockPort.GetWrittenData(), `AT+CPMS="SM","SM","SM"`) {
+		t.Error("storage not restored after a failed read")
+	}
+}
+
+func TestReadSMSFromIgnoredStorage(t *testing.T) {
+	mockPort, handler := newCPMSPort("ME")
+	mockPort.AddResponse(`AT+CPMS="ME","SM","SM"`, "+CPMS: 1,30,1,30,1,30\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CPMS="SM","SM","SM"`, "+CPMS: 1,30,1,30,1,30\r\nOK\r\n")
+
+	_, err := handler.ReadSMSFrom("ME", StatusAll)
+	var mismatch *StorageMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected a StorageMismatchError, got %v", err)
+	}
+	if !reflect.DeepEqual(mismatch.Active, []string{"SM", "SM", "SM"}) {
+		t.Errorf("active storage: got %v", mismatch.Active)
+	}
+	if strings.Contains(mockPort.GetWrittenData(), "AT+CMGL") {
+		t.Error("read the wrong storage after the mismatch")
+	}
+	if active := handler.Config().ActiveStorage; !reflect.DeepEqual(active, []string{"SM", "SM", "SM"}) {
+		t.Errorf("Config().ActiveStorage: got %v", active)
+	}
+}
+
+func TestInitStorageConfirmed(t *testing.T) {
+	mockPort, handler := newCPMSPort()
+	mockPort.AddResponse(`AT+CPMS="SM","SM","SM"`, "+CPMS: 1,30,1,30,1,30\r\nOK\r\n")
+	if err := handler.initModem(); err != nil {
+		t.Fatalf("initModem failed: %v", err)
+	}
+	if active := handler.Config().ActiveStorage; !reflect.DeepEqual(active, []string{"SM", "SM", "SM"}) {
+		t.Errorf("Config().ActiveStorage: got %v", active)
+	}
+
+	RegisterQuirkProfile("STORAGE-TEST", QuirkProfile{Storage: "ME"})
+	mockPort, handler = newCPMSPort("ME")
+	mockPort.AddResponse("AT+CGMM", "STORAGE-TEST\r\nOK\r\n")
+	var mismatch *StorageMismatchError
+	if err := handler.initModem(); !errors.As(err, &mismatch) {
+		t.Errorf("expected init to fail with a StorageMismatchError, got %v", err)
+	}
+}
+
+func TestReadSMSFromInvalidStorage(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	if _, err := handler.ReadSMSFrom("XX", StatusAll); err == nil {
+		t.Error("expected an error for an unknown storage name")
+	}
+}
+
+func TestExportMailbox(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGL="ALL"`,
+		"+CMGL: 5,\"REC READ\",\"+1234567890\",\"Alice\",\"24/01/15,10:30:00+00\"\r\nSecond\r\n"+
+			"+CMGL: 2,\"REC UNREAD\",\"+1987654321\",,\"24/01/14,09:00:00+00\"\r\nFirst\r\nOK\r\n")
+
+	messages, err := handler.ExportMailbox()
+	if err != nil {
+		t.Fatalf("ExportMailbox failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].Index != 2 || messages[1].Index != 5 {
+		t.Errorf("messages not in index order: %d, %d", messages[0].Index, messages[1].Index)
+	}
+	if messages[1].SenderName != "Alice" || messages[1].Timestamp.IsZero() {
+		t.Errorf("fields not parsed: %+v", messages[1])
+	}
+}
+
+func TestStorageMap(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse(`AT+CMGL="ALL"`, "+CMGL: 1,\"REC READ\",\"+1234567890\",,\"24/01/15,10:30:00+00\"\r\nHello\r\n"+
+		"+CMGL: 4,\"REC UNREAD\",\"+1987654321\",,\"24/01/16,09:00:00+00\"\r\n+CMGL: not a header\r\n"+
+		"+CMGL: 7,\"STO UNSENT\",\"+1555555555\",,\r\nDraft\r\nOK\r\n")
+
+	slots, err := handler.StorageMap()
+	if err != nil {
+		t.Fatalf("StorageMap failed: %v", err)
+	}
+
+	want := map[int]string{1: "REC READ", 4: "REC UNREAD", 7: "STO UNSENT"}
+	if len(slots) != len(want) {
+		t.Fatalf("got %v, want %v", slots, want)
+	}
+	for index, status := range want {
+		if slots[index] != status {
+			t.Errorf("slot %d: got %q, want %q", index, slots[index], status)
+		}
+	}
+}
+
+func TestStorageMapPDUMode(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.mode = ModePDU
+	mockPort.AddResponse("AT+CMGL=4", "+CMGL: 2,1,,24\r\n0791\r\n+CMGL: 3,0,,24\r\n0791\r\nOK\r\n")
+
+	slots, err := handler.StorageMap()
+	if err != nil {
+		t.Fatalf("StorageMap failed: %v", err)
+	}
+	if slots[2] != "REC READ" || slots[3] != "REC UNREAD" || len(slots) != 2 {
+		t.Errorf("got %v", slots)
+	}
+}
+
+func TestWaitForStorage(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	clk := newFakeClock()
+	handler.clk = clk
+	mockPort.AddResponse("AT+CPMS?", "+CPMS: \"SM\",30,30,\"SM\",30,30,\"SM\",29,30\r\nOK\r\n")
+
+	done := make(chan error, 1)
+	go func() { done <- handler.WaitForStorage(context.Background(), 5) }()
+
+	// Wait for the first poll to find too little room, then free some
+	for !strings.Contains(mockPort.GetWrittenData(), "AT+CPMS?") || clk.timers() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	mockPort.AddResponse("AT+CPMS?", "+CPMS: \"SM\",20,30,\"SM\",20,30,\"SM\",20,30\r\nOK\r\n")
+	clk.Advance(storagePollInterval)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitForStorage failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WaitForStorage did not return once space was free")
+	}
+}
+
+func TestWaitForStorageTimeout(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.AddResponse("AT+CPMS?", "+CPMS: \"SM\",28,30,\"SM\",28,30,\"SM\",28,30\r\nOK\r\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := handler.WaitForStorage(ctx, 5)
+
+	var waitErr *StorageWaitError
+	if !errors.As(err, &waitErr) || waitErr.Free != 2 || waitErr.MinFree != 5 {
+		t.Fatalf("expected a StorageWaitError with 2 free, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error does not wrap the deadline: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package smshandler
+
+import "sync"
+
+// SubscriberBuffer is how many messages a Subscribe channel holds before
+// further messages for that subscriber are dropped
+const SubscriberBuffer = 32
+
+// subscription is one Subscribe channel
+type subscription struct {
+	mu     sync.Mutex
+	ch     chan SMS
+	closed bool
+	remove func()
+}
+
+// send passes sms on without blocking, dropping it when the subscriber's
+// buffer is full so a slow subscriber cannot hold up the others
+func (sub *subscription) send(sms SMS) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.closed {
+		return true
+	}
+	select {
+	case sub.ch <- sms:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sub *subscription) close() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if !sub.closed {
+		sub.closed = true
+		close(sub.ch)
+	}
+}
+
+// Subscribe returns a channel that receives every incoming message, and an
+// id for Unsubscribe. Any number of subscribers can be registered; one
+// listener reads the port and hands each message to all of them, as well as
+// to any ListenForIncomingSMS callback. The listener is started if it is not
+// already running; a later ListenForIncomingSMS call replaces it, and the
+// subscribers keep receiving.
+//
+// Each channel buffers SubscriberBuffer messages. When a subscriber falls
+// that far behind, further messages for it are dropped and logged rather
+// than delaying the other subscribers.
+func (s *SMSHandler) Subscribe() (int, <-chan SMS) {
+	return s.subscribe(nil)
+}
+
+// subscribe registers a subscriber for the messages match accepts, or for
+// every message when match is nil
+func (s *SMSHandler) subscribe(match func(SMS) bool) (int, <-chan SMS) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	if s.subscriptions == nil {
+		s.subscriptions = make(map[int]*subscription)
+	}
+	s.nextSubscriptionID++
+	id := s.nextSubscriptionID
+
+	sub := &subscription{ch: make(chan SMS, SubscriberBuffer)}
+	sub.remove = s.addIncomingHook(func(sms SMS) {
+		if match != nil && !match(sms) {
+			return
+		}
+		if !sub.send(sms) {
+			s.logger().Printf("Subscriber %d is not keeping up, dropped SMS from %s", id, sms.Sender)
+		}
+	})
+	s.subscriptions[id] = sub
+
+	s.startListenerIfIdle()
+	return id, sub.ch
+}
+
+// Unsubscribe stops delivery to the subscriber and closes its channel. The
+// listener keeps running. Unknown ids are ignored.
+func (s *SMSHandler) Unsubscribe(id int) {
+	s.subsMu.Lock()
+	sub, ok := s.subscriptions[id]
+	delete(s.subscriptions, id)
+	s.subsMu.Unlock()
+
+	if ok {
+		sub.remove()
+		sub.close()
+	}
+}
+
+// closeSubscriptions closes every subscriber channel when the handler is
+// closed, so consumers ranging over them finish
+func (s *SMSHandler) closeSubscriptions() {
+	s.subsMu.Lock()
+	subs := s.subscriptions
+	s.subscriptions = nil
+	s.subsMu.Unlock()
+
+	for _, sub := range subs {
+		sub.remove()
+		sub.close()
+	}
+}
@@ -0,0 +1,75 @@
+package smshandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeFanOut(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	logID, logCh := handler.Subscribe()
+	appID, appCh := handler.Subscribe()
+	defer handler.Close()
+	if logID == appID {
+		t.Fatalf("subscribers share id %d", logID)
+	}
+	if !handler.isListening() {
+		t.Fatal("Subscribe did not start the listener")
+	}
+
+	mockPort.SimulateIncoming("+CMT: \"+1234567890\",\"\",\"24/01/15,10:30:45+00\"\r\nHello\r\n\r\n")
+	for name, ch := range map[string]<-chan SMS{"log": logCh, "app": appCh} {
+		select {
+		case sms := <-ch:
+			if sms.Message != "Hello" || sms.Sender != "+1234567890" {
+				t.Errorf("%s subscriber: unexpected message %+v", name, sms)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s subscriber: timed out", name)
+		}
+	}
+
+	handler.Unsubscribe(logID)
+	if _, ok := <-logCh; ok {
+		t.Error("channel still open after Unsubscribe")
+	}
+	handler.Unsubscribe(logID) // unknown ids are ignored
+
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, ok := <-appCh; ok {
+		t.Error("channel still open after Close")
+	}
+}
+
+// A subscriber that never reads loses messages once its buffer is full,
+// without holding up the others
+func TestSubscribeSlowSubscriber(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	logger := &recordingLogger{}
+	handler.cfg.logger = logger
+	defer handler.Close()
+
+	_, slow := handler.Subscribe()
+	_, fast := handler.Subscribe()
+
+	received := 0
+	for i := 0; i < SubscriberBuffer+5; i++ {
+		handler.deliver(SMS{Sender: "+1234567890", Message: "Spam", Index: i}, func(SMS) {})
+		<-fast
+		received++
+	}
+
+	if received != SubscriberBuffer+5 {
+		t.Errorf("fast subscriber got %d messages", received)
+	}
+	if len(slow) != SubscriberBuffer {
+		t.Errorf("slow subscriber buffered %d messages, want %d", len(slow), SubscriberBuffer)
+	}
+	if len(logger.warns) != 5 {
+		t.Errorf("expected 5 drop warnings, got %q", logger.warns)
+	}
+}
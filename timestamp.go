@@ -0,0 +1,36 @@
+package smshandler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseSMSTimestamp parses a modem timestamp in the GSM 07.05 format
+// "yy/MM/dd,hh:mm:ss±zz", where zz is the UTC offset in quarter hours
+func parseSMSTimestamp(date string) (time.Time, error) {
+	date = strings.Trim(strings.TrimSpace(date), "\"")
+	if len(date) < len("yy/MM/dd,hh:mm:ss") {
+		return time.Time{}, fmt.Errorf("invalid SMS timestamp %q", date)
+	}
+
+	var year, month, day, hour, minute, second int
+	if _, err := fmt.Sscanf(date[:17], "%2d/%2d/%2d,%2d:%2d:%2d", &year, &month, &day, &hour, &minute, &second); err != nil {
+		return time.Time{}, fmt.Errorf("invalid SMS timestamp %q: %v", date, err)
+	}
+
+	offset := 0
+	if zone := date[17:]; zone != "" {
+		var quarters int
+		if _, err := fmt.Sscanf(zone, "%d", &quarters); err != nil {
+			return time.Time{}, fmt.Errorf("invalid SMS timestamp zone %q: %v", date, err)
+		}
+		offset = quarters * 15 * 60
+	}
+
+	t := time.Date(2000+year, time.Month(month), day, hour, minute, second, 0, time.FixedZone("", offset))
+	if t.Month() != time.Month(month) || t.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid SMS timestamp %q: date out of range", date)
+	}
+	return t, nil
+}
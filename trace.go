@@ -0,0 +1,50 @@
+package smshandler
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"go.bug.st/serial"
+)
+
+// tracingPort copies every byte read from and written to the wrapped port
+// to a trace writer, one quoted chunk per line: ">> " for bytes sent to the
+// modem and "<< " for bytes received from it
+type tracingPort struct {
+	serial.Port
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// withTrace wraps port so its traffic is echoed to w. A nil w returns the
+// port unchanged, so tracing costs nothing when it is off.
+func withTrace(port serial.Port, w io.Writer) serial.Port {
+	if w == nil {
+		return port
+	}
+	return &tracingPort{Port: port, w: w}
+}
+
+func (t *tracingPort) Read(p []byte) (int, error) {
+	n, err := t.Port.Read(p)
+	if n > 0 {
+		t.trace("<<", p[:n])
+	}
+	return n, err
+}
+
+func (t *tracingPort) Write(p []byte) (int, error) {
+	n, err := t.Port.Write(p)
+	if n > 0 {
+		t.trace(">>", p[:n])
+	}
+	return n, err
+}
+
+func (t *tracingPort) trace(tag string, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// A failing trace writer must not break modem traffic
+	_, _ = fmt.Fprintf(t.w, "%s %q\n", tag, data)
+}
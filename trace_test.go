@@ -0,0 +1,33 @@
+package smshandler
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestWithTrace(t *testing.T) {
+	var trace bytes.Buffer
+	mockPort := NewMockSerialPort()
+	mockPort.AddResponse("AT", "OK\r\n")
+
+	handler := newMockHandler(mockPort)
+	handler.port = withTrace(mockPort, &trace)
+	handler.reader = bufio.NewReader(handler.port)
+
+	if _, err := handler.sendATCommand("AT"); err != nil {
+		t.Fatalf("sendATCommand failed: %v", err)
+	}
+
+	want := ">> \"AT\\r\\n\"\n<< \"OK\\r\\n\"\n"
+	if trace.String() != want {
+		t.Errorf("trace: got %q, want %q", trace.String(), want)
+	}
+}
+
+func TestWithTraceNil(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	if port := withTrace(mockPort, nil); port != mockPort {
+		t.Error("nil trace writer should leave the port unwrapped")
+	}
+}
@@ -0,0 +1,105 @@
+package smshandler
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// decodeTextBody turns a text-mode body the modem sent as hex UCS2, such as
+// "00480069", into UTF-8. dcs is the data coding scheme from the header, or
+// -1 when the header did not include it. To avoid mangling a message that
+// merely looks like hex, the body is only decoded when the context says it
+// is UCS2: the UCS2 character set is selected, the DCS says UCS2, or, with
+// no DCS to go on, the modem's quirk profile sets UCS2Hex.
+func (s *SMSHandler) decodeTextBody(body string, dcs int) string {
+	switch {
+	case strings.EqualFold(s.charset, "UCS2"):
+	case dcs >= 0:
+		if dcs > 0xFF || dcsEncoding(byte(dcs)) != EncodingUCS2 {
+			return body
+		}
+	case !s.modemQuirks().UCS2Hex:
+		return body
+	}
+
+	if text, ok := decodeHexUCS2(body); ok {
+		return text
+	}
+	return body
+}
+
+// decodeHexUCS2 decodes hex-encoded big-endian UCS2 text. It fails unless
+// the input is a whole number of hex UTF-16 units that decode to printable
+// text.
+func decodeHexUCS2(s string) (string, bool) {
+	if s == "" || len(s)%4 != 0 {
+		return "", false
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return "", false
+	}
+
+	text := decodeUCS2(b)
+	for _, r := range text {
+		if r == unicode.ReplacementChar || (unicode.IsControl(r) && r != '\n' && r != '\r') {
+			return "", false
+		}
+	}
+	return text, true
+}
+
+// needsUCS2Text reports whether a text-mode message cannot be written at
+// the prompt in the GSM character set. Extension characters such as € or [
+// need an escape byte, which the modem takes as cancelling the message, and
+// non-ASCII characters arrive as their UTF-8 bytes, so either way the
+// recipient would see garbage.
+func needsUCS2Text(message string) bool {
+	for _, r := range message {
+		if _, ext := gsm7Extension[r]; ext || r > unicode.MaxASCII {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeHexUCS2 encodes text as the uppercase hex UCS2 the UCS2 character
+// set expects, the inverse of decodeHexUCS2
+func encodeHexUCS2(s string) string {
+	return strings.ToUpper(hex.EncodeToString(encodeUCS2(s)))
+}
+
+// applyTextUCS2 switches the modem to the UCS2 character set and data
+// coding scheme for one text-mode send and returns a function that restores
+// the GSM character set and the previous AT+CSMP settings. ctx is the send's
+// exclusive claim on the modem; see beginExclusive.
+func (s *SMSHandler) applyTextUCS2(ctx context.Context) (restore func(), err error) {
+	previous, err := s.getTextModeParams(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to switch to UCS2: %v", err)
+	}
+
+	params := previous
+	params.DCS = 8
+	if err := s.setTextModeParams(ctx, params); err != nil {
+		return nil, fmt.Errorf("failed to switch to UCS2: %v", err)
+	}
+	if _, err := s.sendATCommandExpectOKContext(ctx, `AT+CSCS="UCS2"`); err != nil {
+		if err := s.setTextModeParams(ctx, previous); err != nil {
+			s.logger().Printf("Failed to restore text mode parameters: %v", err)
+		}
+		return nil, fmt.Errorf("failed to switch to UCS2: %v", err)
+	}
+
+	return func() {
+		if _, err := s.sendATCommandExpectOKContext(ctx, `AT+CSCS="GSM"`); err != nil {
+			s.logger().Printf("Failed to restore the GSM character set: %v", err)
+		}
+		if err := s.setTextModeParams(ctx, previous); err != nil {
+			s.logger().Printf("Failed to restore text mode parameters: %v", err)
+		}
+	}, nil
+}
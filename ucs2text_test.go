@@ -0,0 +1,169 @@
+package smshandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// "Hey 😀" as hex UCS2, the way text mode shows a UCS2 body
+const ucs2HexBody = "0048006500790020D83DDE00"
+
+func TestDecodeTextBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset string
+		quirk   bool
+		body    string
+		dcs     int
+		want    string
+	}{
+		{"UCS2 DCS", "GSM", false, ucs2HexBody, 8, "Hey 😀"},
+		{"GSM DCS keeps hex-looking text", "GSM", false, "00410042", 0, "00410042"},
+		{"no DCS keeps hex-looking text", "GSM", false, "00410042", -1, "00410042"},
+		{"no DCS with UCS2Hex quirk", "GSM", true, "00480069", -1, "Hi"},
+		{"UCS2 charset", "UCS2", false, "00480069", -1, "Hi"},
+		{"UCS2 DCS but not hex", "GSM", false, "Hello", 8, "Hello"},
+		{"odd length", "UCS2", false, "004800", -1, "004800"},
+		{"control characters", "UCS2", false, "00010002", -1, "00010002"},
+	}
+
+	for _, tt := range tests {
+		handler := newMockHandler(NewMockSerialPort())
+		handler.charset = tt.charset
+		handler.quirks.UCS2Hex = tt.quirk
+
+		if got := handler.decodeTextBody(tt.body, tt.dcs); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCMTDecodesUCS2Body(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	mockPort.SimulateIncoming(ucs2HexBody + "\r\n\r\n")
+
+	var received []SMS
+	handler.handleCMTMessage(`+CMT: "+1234567890",,"24/01/15,10:30:45+00",145,4,0,8,"+1555000",145,12`, func(sms SMS) {
+		received = append(received, sms)
+	})
+
+	if len(received) != 1 || received[0].Message != "Hey 😀" {
+		t.Errorf("delivered %+v", received)
+	}
+}
+
+func TestSendSMSEuroSwitchesToUCS2(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.charset = "GSM"
+	mockPort.AddResponse("AT+CSMP?", "+CSMP: 17,167,0,0\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSMP=17,167,0,8", "OK\r\n")
+	mockPort.AddResponse(`AT+CSCS="UCS2"`, "OK\r\n")
+	mockPort.AddResponse(`AT+CMGS="002B0031003200330034003500360037003800390030",145`, "\r\n> ")
+	mockPort.AddResponse("20AC0031003000200028005B0031005D0029\x1A", "\r\n+CMGS: 5\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CSCS="GSM"`, "OK\r\n")
+	mockPort.AddResponse("AT+CSMP=17,167,0,0", "OK\r\n")
+
+	ref, err := handler.SendSMSRef("+1234567890", "€10 ([1])")
+	if err != nil {
+		t.Fatalf("SendSMSRef failed: %v", err)
+	}
+	if ref != 5 {
+		t.Errorf("reference = %d, want 5", ref)
+	}
+
+	written := mockPort.GetWrittenData()
+	order := []string{"AT+CSMP=17,167,0,8", `AT+CSCS="UCS2"`, "AT+CMGS=", `AT+CSCS="GSM"`, "AT+CSMP=17,167,0,0"}
+	last := -1
+	for _, cmd := range order {
+		i := strings.Index(written, cmd)
+		if i <= last {
+			t.Fatalf("%q missing or out of order in %q", cmd, written)
+		}
+		last = i
+	}
+}
+
+func TestSendSMSPlainTextStaysGSM(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.charset = "GSM"
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Price: 10 EUR\x1A", "\r\n+CMGS: 6\r\nOK\r\n")
+
+	if err := handler.SendSMS("+1234567890", "Price: 10 EUR"); err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+	if written := mockPort.GetWrittenData(); strings.Contains(written, "CSCS") {
+		t.Errorf("character set changed for plain text: %q", written)
+	}
+}
+
+func TestSendSMSExtensionCharactersLength(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.charset = "GSM"
+
+	// 71 characters fit one GSM segment but not the UCS2 the € forces
+	err := handler.SendSMS("+1234567890", "€"+strings.Repeat("a", 70))
+	var tooLong *MessageTooLongError
+	if !errors.As(err, &tooLong) || tooLong.Encoding != EncodingUCS2 {
+		t.Errorf("expected a UCS2 MessageTooLongError, got %v", err)
+	}
+	if written := mockPort.GetWrittenData(); written != "" {
+		t.Errorf("nothing should be written, got %q", written)
+	}
+}
+
+// hookPort calls onWrite with each write before passing it on
+type hookPort struct {
+	*MockSerialPort
+	onWrite func(string)
+}
+
+func (h *hookPort) Write(p []byte) (int, error) {
+	h.onWrite(string(p))
+	return h.MockSerialPort.Write(p)
+}
+
+// A command from another goroutine waits until the send has restored the
+// settings it switched
+func TestSendSMSUCS2HoldsModem(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.charset = "GSM"
+	mockPort.AddResponse("AT+CSMP?", "+CSMP: 17,167,0,0\r\nOK\r\n")
+	mockPort.AddResponse("AT+CSMP=17,167,0,8", "OK\r\n")
+	mockPort.AddResponse(`AT+CSCS="UCS2"`, "OK\r\n")
+	mockPort.AddResponse(`AT+CMGS="002B0031003200330034003500360037003800390030",145`, "\r\n> ")
+	mockPort.AddResponse("20AC0031\x1A", "\r\n+CMGS: 5\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CSCS="GSM"`, "OK\r\n")
+	mockPort.AddResponse("AT+CSMP=17,167,0,0", "OK\r\n")
+	mockPort.AddResponse("AT+CSQ", "+CSQ: 20,99\r\nOK\r\n")
+
+	other := make(chan error, 1)
+	handler.port = &hookPort{MockSerialPort: mockPort, onWrite: func(cmd string) {
+		if strings.HasPrefix(cmd, `AT+CSCS="UCS2"`) {
+			go func() {
+				_, err := handler.GetSignalStrength()
+				other <- err
+			}()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}}
+
+	if err := handler.SendSMS("+1234567890", "€1"); err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+	if err := <-other; err != nil {
+		t.Fatalf("GetSignalStrength failed: %v", err)
+	}
+
+	written := mockPort.GetWrittenData()
+	if strings.Index(written, "AT+CSQ") < strings.Index(written, "AT+CSMP=17,167,0,0") {
+		t.Errorf("command ran while the send had UCS2 selected: %q", written)
+	}
+}
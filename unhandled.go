@@ -0,0 +1,67 @@
+package smshandler
+
+import "sync"
+
+// DefaultUnhandledLines is how many unhandled listener lines are kept when
+// no WithUnhandledLines option is supplied
+const DefaultUnhandledLines = 20
+
+// lineLog keeps the most recent lines up to a fixed capacity
+type lineLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *lineLog) add(line string, capacity int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, line)
+	if over := len(l.lines) - capacity; over > 0 {
+		l.lines = append([]string(nil), l.lines[over:]...)
+	}
+}
+
+func (l *lineLog) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func (l *lineLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = nil
+}
+
+// recordUnhandled keeps a listener line the handler did not act on itself
+func (s *SMSHandler) recordUnhandled(line string) {
+	if n := s.unhandledCapacity(); n > 0 {
+		s.unhandled.add(line, n)
+	}
+}
+
+// unhandledCapacity returns how many unhandled lines to keep, 0 to keep none
+func (s *SMSHandler) unhandledCapacity() int {
+	switch {
+	case s.cfg.unhandledLines == 0:
+		return DefaultUnhandledLines
+	case s.cfg.unhandledLines < 0:
+		return 0
+	}
+	return s.cfg.unhandledLines
+}
+
+// UnhandledLines returns the most recent lines the listener received but
+// did not handle itself, oldest first: unsolicited results other than SMS
+// notifications (which also go to the OnURC callback, if any) and SMS
+// notifications it could not parse. They help find modem output the
+// library does not understand yet without a full serial trace.
+func (s *SMSHandler) UnhandledLines() []string {
+	return s.unhandled.snapshot()
+}
+
+// ClearUnhandledLines empties the buffer returned by UnhandledLines
+func (s *SMSHandler) ClearUnhandledLines() {
+	s.unhandled.clear()
+}
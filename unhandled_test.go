@@ -0,0 +1,67 @@
+package smshandler
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUnhandledLinesFromListener(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	urcs := make(chan string, 4)
+	handler.OnURC(func(line string) { urcs <- line })
+
+	mockPort.SimulateIncoming("OK\r\n^SYSSTART\r\n+CSQ: 20,99\r\n+CMT: garbage\r\n+QIND: \"csq\",20\r\n")
+	handler.ListenForIncomingSMS(func(SMS) {})
+	defer handler.Close()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-urcs:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for the listener")
+		}
+	}
+
+	want := []string{"^SYSSTART", "+CMT: garbage", `+QIND: "csq",20`}
+	deadline := time.Now().Add(2 * time.Second)
+	for !reflect.DeepEqual(handler.UnhandledLines(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("unhandled lines: got %q, want %q", handler.UnhandledLines(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	handler.ClearUnhandledLines()
+	if lines := handler.UnhandledLines(); len(lines) != 0 {
+		t.Errorf("lines left after clear: %q", lines)
+	}
+}
+
+func TestUnhandledLinesCapacity(t *testing.T) {
+	handler := newMockHandler(NewMockSerialPort())
+	for i := 0; i < DefaultUnhandledLines+5; i++ {
+		handler.recordUnhandled(strconv.Itoa(i))
+	}
+	lines := handler.UnhandledLines()
+	if len(lines) != DefaultUnhandledLines || lines[0] != "5" {
+		t.Errorf("expected the last %d lines, got %q", DefaultUnhandledLines, lines)
+	}
+
+	WithUnhandledLines(2)(&handler.cfg)
+	handler.recordUnhandled("a")
+	handler.recordUnhandled("b")
+	if lines := handler.UnhandledLines(); !reflect.DeepEqual(lines, []string{"a", "b"}) {
+		t.Errorf("capacity 2: got %q", lines)
+	}
+
+	WithUnhandledLines(-1)(&handler.cfg)
+	handler.ClearUnhandledLines()
+	handler.recordUnhandled("c")
+	if lines := handler.UnhandledLines(); len(lines) != 0 {
+		t.Errorf("disabled buffer kept %q", lines)
+	}
+}
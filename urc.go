@@ -0,0 +1,27 @@
+package smshandler
+
+// OnURC registers a callback for unsolicited result codes that are not SMS
+// notifications, such as +CREG: network registration changes or +CUSD:
+// USSD replies. It runs on the listener goroutine, so it should return
+// quickly. Passing nil removes the callback.
+//
+// The listener still consumes some lines itself: +CMT: and +CMTI: (and the
+// message body that follows +CMT:) go to the SMS callback, and command
+// echoes (AT...), OK, ERROR and the responses +CMGF:, +CSCS:, +CPMS:,
+// +CNMI: and +CSQ: are dropped as leftovers of earlier commands.
+func (s *SMSHandler) OnURC(fn func(line string)) {
+	s.urcMu.Lock()
+	defer s.urcMu.Unlock()
+	s.urcHandler = fn
+}
+
+// dispatchURC hands an unsolicited line to the registered callback, if any
+func (s *SMSHandler) dispatchURC(line string) {
+	s.urcMu.RLock()
+	fn := s.urcHandler
+	s.urcMu.RUnlock()
+
+	if fn != nil {
+		fn(line)
+	}
+}
@@ -0,0 +1,29 @@
+package smshandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOnURC(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+
+	urcs := make(chan string, 4)
+	handler.OnURC(func(line string) { urcs <- line })
+
+	mockPort.SimulateIncoming("OK\r\n+CREG: 5\r\nAT+CSQ\r\n+CUSD: 0,\"Balance 5.00\",15\r\n")
+	handler.ListenForIncomingSMS(func(SMS) {})
+	defer handler.Close()
+
+	for _, want := range []string{"+CREG: 5", `+CUSD: 0,"Balance 5.00",15`} {
+		select {
+		case got := <-urcs:
+			if got != want {
+				t.Errorf("got URC %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for URC %q", want)
+		}
+	}
+}
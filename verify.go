@@ -0,0 +1,51 @@
+package smshandler
+
+import "fmt"
+
+// checkSentStorage looks for a just-sent message in the sent-messages
+// storage when WithSentVerification or WithCleanupSentMessages is set. A
+// message that cannot be found is logged as unconfirmed for verification;
+// a found one is deleted for cleanup, the most recent copy when several
+// match.
+func (s *SMSHandler) checkSentStorage(number, message string) {
+	if !s.cfg.verifySent && !s.cfg.cleanupSent {
+		return
+	}
+
+	sent, err := s.ReadSMSByStatus(StatusStoredSent)
+	if err != nil {
+		if s.cfg.verifySent {
+			s.logger().Printf("Could not verify SMS to %s was sent: %v", number, err)
+		} else {
+			s.logger().Printf("Could not clean up sent SMS to %s: %v", number, err)
+		}
+		return
+	}
+
+	var match *SMS
+	for i := range sent {
+		if sameNumber(sent[i].Sender, number) && sent[i].Message == message &&
+			(match == nil || sent[i].Index > match.Index) {
+			match = &sent[i]
+		}
+	}
+	if match == nil {
+		if s.cfg.verifySent {
+			s.logger().Printf("Could not verify SMS to %s was sent: not found in sent storage", number)
+		}
+		return
+	}
+	if !s.cfg.cleanupSent {
+		return
+	}
+
+	indexes := match.PartIndexes
+	if len(indexes) == 0 {
+		indexes = []int{match.Index}
+	}
+	for _, index := range indexes {
+		if _, err := s.sendATCommandExpectOK(fmt.Sprintf("AT+CMGD=%d", index)); err != nil {
+			s.logger().Printf("Could not delete sent SMS %d: %v", index, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package smshandler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSentVerification(t *testing.T) {
+	tests := []struct {
+		name    string
+		listing string
+		warning string
+	}{
+		{"found", "+CMGL: 4,\"STO SENT\",\"+1234567890\",,\r\nHello\r\nOK\r\n", ""},
+		{"missing", "+CMGL: 4,\"STO SENT\",\"+1234567890\",,\r\nOther\r\nOK\r\n", "not found in sent storage"},
+		{"read fails", "+CMS ERROR: 302\r\n", "Could not verify"},
+	}
+
+	for _, tt := range tests {
+		mockPort := NewMockSerialPort()
+		handler := newMockHandler(mockPort)
+		handler.cfg.verifySent = true
+		logger := &recordingLogger{}
+		handler.cfg.logger = logger
+		mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+		mockPort.AddResponse("Hello\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+		mockPort.AddResponse(`AT+CMGL="STO SENT"`, tt.listing)
+
+		if err := handler.SendSMS("+1234567890", "Hello"); err != nil {
+			t.Errorf("%s: SendSMS failed: %v", tt.name, err)
+			continue
+		}
+
+		warned := strings.Join(logger.warns, "\n")
+		if tt.warning == "" && warned != "" {
+			t.Errorf("%s: unexpected warning %q", tt.name, warned)
+		}
+		if tt.warning != "" && !strings.Contains(warned, tt.warning) {
+			t.Errorf("%s: warnings %q, want %q", tt.name, warned, tt.warning)
+		}
+	}
+}
+
+func TestCleanupSentMessages(t *testing.T) {
+	mockPort := NewMockSerialPort()
+	handler := newMockHandler(mockPort)
+	handler.cfg.cleanupSent = true
+	mockPort.AddResponse(`AT+CMGS="+1234567890",145`, "\r\n> ")
+	mockPort.AddResponse("Hello\x1A", "\r\n+CMGS: 1\r\nOK\r\n")
+	mockPort.AddResponse(`AT+CMGL="STO SENT"`, "+CMGL: 2,\"STO SENT\",\"+1234567890\",,\r\nHello\r\n"+
+		"+CMGL: 4,\"STO SENT\",\"+1987654321\",,\r\nHello\r\n"+
+		"+CMGL: 6,\"STO SENT\",\"+1234567890\",,\r\nHello\r\nOK\r\n")
+	mockPort.AddResponse("AT+CMGD=6", "OK\r\n")
+
+	if err := handler.SendSMS("+1234567890", "Hello"); err != nil {
+		t.Fatalf("SendSMS failed: %v", err)
+	}
+
+	written := mockPort.GetWrittenData()
+	if !strings.Contains(written, "AT+CMGD=6") {
+		t.Errorf("most recent sent copy not deleted: %q", written)
+	}
+	if strings.Count(written, "AT+CMGD") != 1 {
+		t.Errorf("expected exactly one delete: %q", written)
+	}
+}